@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// Where the login profile in use was chosen
+const (
+	profileSourceFlag    = "--profile flag"
+	profileSourceEnv     = "ENVAULT_PROFILE"
+	profileSourceProject = "project pin"
+	profileSourceActive  = "active profile"
+)
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage logged-in accounts",
+	Long: `Manage the EnvVault accounts this machine is logged in to.
+
+Each account is stored under a profile name. The profile used by a command
+is, in order: the --profile flag, ENVAULT_PROFILE, the account pinned by
+the project, then the active profile chosen with 'envault account switch'.
+
+Subcommands:
+  list    List logged-in accounts
+  switch  Choose the active account
+  pin     Pin the current project to an account
+  unpin   Remove the project's account pin
+
+Examples:
+  envault login --profile acme
+  envault account list
+  envault account switch acme
+  envault account pin acme`,
+}
+
+var accountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List logged-in accounts",
+	Args:  cobra.NoArgs,
+	RunE:  runAccountList,
+}
+
+var accountSwitchCmd = &cobra.Command{
+	Use:   "switch PROFILE",
+	Short: "Choose the account used by default",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAccountSwitch,
+}
+
+var accountPinCmd = &cobra.Command{
+	Use:   "pin PROFILE",
+	Short: "Pin the current project to an account",
+	Long: `Pin the current project to an account.
+
+Sync and team commands run in this project then use the pinned account's
+credentials, whatever the active profile is. The pin is stored in the
+project's .envault file, so teammates who name their profiles the same way
+can commit it.
+
+Examples:
+  envault account pin acme`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAccountPin,
+}
+
+var accountUnpinCmd = &cobra.Command{
+	Use:   "unpin",
+	Short: "Remove the current project's account pin",
+	Args:  cobra.NoArgs,
+	RunE:  runAccountUnpin,
+}
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountSwitchCmd)
+	accountCmd.AddCommand(accountPinCmd)
+	accountCmd.AddCommand(accountUnpinCmd)
+}
+
+// resolveProfile returns the login profile to use for a project, which may
+// be nil outside a project, and where it was chosen
+func resolveProfile(project *utils.ProjectContext) (string, string) {
+	if profileFlag != "" {
+		return profileFlag, profileSourceFlag
+	}
+	if value := os.Getenv("ENVAULT_PROFILE"); value != "" {
+		return value, profileSourceEnv
+	}
+	if project != nil && project.Account != "" {
+		return project.Account, profileSourceProject
+	}
+	return auth.ActiveProfile(), profileSourceActive
+}
+
+// currentProfile resolves the login profile for the current directory
+func currentProfile() (string, string, error) {
+	project, _ := utils.LoadProjectContext()
+	name, source := resolveProfile(project)
+	if err := auth.ValidateProfileName(name); err != nil {
+		return "", "", fmt.Errorf("%v (from %s)", err, source)
+	}
+	return name, source, nil
+}
+
+// envToken returns the token from ENVAULT_TOKEN, which CI and other
+// non-interactive callers use instead of logging in
+func envToken() string {
+	return strings.TrimSpace(os.Getenv("ENVAULT_TOKEN"))
+}
+
+// requireLogin checks that there are credentials for the project's API
+// remote: ENVAULT_TOKEN or a session for the resolved profile
+func requireLogin(project *utils.ProjectContext) error {
+	if envToken() != "" {
+		return nil
+	}
+
+	account, _ := resolveProfile(project)
+	if !auth.IsLoggedIn(account) {
+		return fmt.Errorf("Not logged in\nRun '%s' first, or set ENVAULT_TOKEN", auth.LoginCommand(account))
+	}
+
+	return nil
+}
+
+// profileSuffix names a non-default profile in messages
+func profileSuffix(name string) string {
+	if name == auth.DefaultProfile {
+		return ""
+	}
+	return fmt.Sprintf(" (profile %s)", name)
+}
+
+func runAccountList(cmd *cobra.Command, args []string) error {
+	profiles, err := auth.ListProfiles()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	current, _, _ := currentProfile()
+
+	type accountInfo struct {
+		Profile   string     `json:"profile"`
+		Email     string     `json:"email,omitempty"`
+		UserID    string     `json:"user_id,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		Current   bool       `json:"current"`
+		Status    string     `json:"status"`
+	}
+
+	var accounts []accountInfo
+	for _, name := range profiles {
+		info := accountInfo{Profile: name, Current: name == current, Status: "ok"}
+
+		session, err := auth.LoadSession(name)
+		if err != nil {
+			info.Status = err.Error()
+		} else {
+			info.Email = session.Email
+			info.UserID = session.UserID
+			if !session.ExpiresAt.IsZero() {
+				expires := session.ExpiresAt
+				info.ExpiresAt = &expires
+			}
+			if session.Expired() && session.RefreshToken == "" {
+				info.Status = "expired"
+			}
+		}
+
+		accounts = append(accounts, info)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(accounts)
+	}
+
+	if len(accounts) == 0 {
+		fmt.Println("Not logged in to any account")
+		if !quiet {
+			fmt.Println("\nLog in with: envault login [--profile NAME]")
+		}
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "Profile", "Account", "Status"})
+	table.SetBorder(false)
+	table.SetColumnSeparator("")
+	table.SetHeaderLine(false)
+
+	for _, account := range accounts {
+		marker := ""
+		if account.Current {
+			marker = "*"
+		}
+		email := account.Email
+		if email == "" {
+			email = account.UserID
+		}
+		table.Append([]string{marker, account.Profile, email, account.Status})
+	}
+	table.Render()
+
+	return nil
+}
+
+func runAccountSwitch(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	name := args[0]
+
+	session, err := auth.LoadSession(name)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := auth.SetActiveProfile(name); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	green.Printf("✓ Switched to %s%s\n", sessionDisplayName(session), profileSuffix(name))
+
+	// An explicit choice elsewhere still wins over the active profile
+	if !quiet {
+		project, _ := utils.LoadProjectContext()
+		if current, source := resolveProfile(project); current != name {
+			yellow.Printf("  This directory still uses profile %s (%s)\n", current, source)
+		}
+	}
+
+	return nil
+}
+
+func runAccountPin(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	name := args[0]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := auth.ValidateProfileName(name); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := utils.SetProjectValue("account", name); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	green.Printf("✓ Pinned %s to profile %s\n", ctx.ProjectName, name)
+
+	if !auth.IsLoggedIn(name) && !quiet {
+		yellow.Printf("  Not logged in to this profile yet; run '%s'\n", auth.LoginCommand(name))
+	}
+
+	return nil
+}
+
+func runAccountUnpin(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if ctx.Account == "" {
+		fmt.Println("Project is not pinned to an account")
+		return nil
+	}
+
+	if err := utils.SetProjectValue("account", ""); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	green.Printf("✓ Unpinned %s from profile %s\n", ctx.ProjectName, ctx.Account)
+
+	return nil
+}
@@ -193,3 +193,17 @@ func auditLimitText(limit, actual int) string {
 	}
 	return "last " + strconv.Itoa(limit)
 }
+
+// logAudit records an action in the local audit log, best effort
+func logAudit(projectID, action string, metadata map[string]interface{}) {
+	db, err := openDB()
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	data, _ := json.Marshal(metadata)
+	if err := db.CreateAuditLog(projectID, action, string(data)); err != nil {
+		color.New(color.FgYellow).Printf("Warning: Failed to create audit log: %v\n", err)
+	}
+}
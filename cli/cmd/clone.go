@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/backend"
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloneURL        string
+	cloneAPIKey     string
+	cloneRemoteName string
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone PROJECT [dir]",
+	Short: "Clone a team project from EnvVault cloud or envault-server",
+	Long: `Clone a project you are a member of into a directory.
+
+PROJECT is the project's name or ID as shown by 'envault projects --remote'.
+The local project keeps the server's project ID, the directory is linked
+to it with a .envault file and the latest secrets are pulled.
+
+The directory defaults to the project's name, with characters other than
+letters, digits, '-', '_' and '.' replaced by dashes, and is created if
+needed.
+Projects are looked up on the current project's default remote, or on
+EnvVault cloud (ENVAULT_API_URL/ENVAULT_API_KEY) outside a project. Use
+--url to clone from another envault-server.
+
+Examples:
+  envault clone my-app
+  envault clone my-app ~/src/my-app/config
+  envault clone 7d1c4a2e-... . --url https://envault.internal.example.com --api-key KEY
+  ENVAULT_TOKEN=envm_... envault clone 7d1c4a2e-... .   # In CI with a machine token`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runClone,
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+
+	cloneCmd.Flags().StringVar(&cloneURL, "url", "", "EnvVault cloud or envault-server URL to clone from")
+	cloneCmd.Flags().StringVar(&cloneAPIKey, "api-key", "", "API key for the server")
+	cloneCmd.Flags().StringVar(&cloneRemoteName, "remote-name", "origin", "Name of the remote to add")
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+	cyan := color.New(color.FgCyan)
+
+	if !remoteNamePattern.MatchString(cloneRemoteName) {
+		return fmt.Errorf("Error: invalid remote name %q (use letters, digits, '-' and '_')", cloneRemoteName)
+	}
+
+	// Find the server to clone from
+	rawURL, creds, err := remoteProjectsEndpoint(cloneURL, cloneAPIKey)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	client, err := newRemoteProjectsClient(rawURL, creds)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Look the project up by name or ID
+	remoteProject, err := findRemoteProject(cmd.Context(), client, args[0])
+	if err != nil {
+		return err
+	}
+
+	// Work out where to clone it. Any member who can push may rename the
+	// project, so the server's name is only used as a single directory
+	// below the current one.
+	var dir string
+	if len(args) > 1 {
+		dir = args[1]
+	} else if dir = cloneDirName(remoteProject.Name); dir == "" {
+		return fmt.Errorf("Error: project %s has no name on the server that can be used as a directory; pass a directory to clone it into", remoteProject.ID)
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".envault")); err == nil {
+		return fmt.Errorf("Error: %s is already linked to an envault project", dir)
+	}
+
+	projectName := cleanProjectName(remoteProject.Name)
+	if projectName == "" {
+		projectName = filepath.Base(dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Everything below works on the cloned directory
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("failed to enter %s: %w", dir, err)
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	// Create the local project with the server's ID, or reuse an earlier
+	// clone of it
+	project, err := db.GetProject(remoteProject.ID)
+	if err != nil {
+		ownerID := remoteProject.OwnerID
+		if ownerID == "" {
+			ownerID = "local"
+		}
+
+		project, err = db.CreateProjectWithID(remoteProject.ID, projectName, remoteProject.Description, ownerID)
+		if err != nil {
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+	} else if !quiet {
+		cyan.Printf("Project %s already exists locally; linking it\n", project.Name)
+		projectName = project.Name
+	}
+
+	if err := db.SetProjectSyncEnabled(project.ID, true); err != nil {
+		return fmt.Errorf("failed to enable sync: %w", err)
+	}
+
+	// Create the environments the server knows about; the pull fills them
+	if environments, err := client.GetEnvironments(cmd.Context(), project.ID); err != nil {
+		if debug {
+			utils.Warn("Could not list the project's environments: %v", err)
+		}
+	} else {
+		for _, environment := range environments {
+			if _, err := db.GetEnvironment(project.ID, environment.Name); err == nil {
+				continue
+			}
+			if _, err := db.CreateEnvironment(project.ID, environment.Name); err != nil {
+				return fmt.Errorf("failed to create environment %s: %w", environment.Name, err)
+			}
+		}
+	}
+
+	// Link the directory
+	if !creds.IsEmpty() {
+		if err := auth.SaveRemoteCredentials(project.ID, cloneRemoteName, creds); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+	}
+
+	if err := utils.WriteProjectContext(&utils.ProjectContext{
+		ProjectID:     project.ID,
+		ProjectName:   cleanProjectName(projectName),
+		Remotes:       map[string]string{cloneRemoteName: rawURL},
+		DefaultRemote: cloneRemoteName,
+	}); err != nil {
+		return fmt.Errorf("failed to create .envault file: %w", err)
+	}
+
+	if err := addToGitignore(".envault"); err != nil {
+		yellow.Println("Warning: Could not update .gitignore")
+	}
+
+	logAudit(project.ID, "project_cloned", map[string]interface{}{
+		"project_name": projectName,
+		"remote":       cloneRemoteName,
+	})
+
+	green.Printf("✓ Cloned %s into %s\n", projectName, dir)
+
+	// Initial pull
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	remote, err := openSyncRemote(ctx, "")
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	cryptoSvc, err := crypto.New()
+	if err != nil {
+		return fmt.Errorf("failed to initialize crypto: %w", err)
+	}
+
+	if !quiet {
+		cyan.Printf("↓ Pulling from %s...\n", remote.Name())
+	}
+
+	pulled, err := pullProject(cmd.Context(), remote, db, cryptoSvc, project.ID)
+	if err != nil {
+		yellow.Println("The project is linked but its secrets could not be pulled; run 'envault sync' to retry")
+		if backend.IsUnavailable(err) {
+			return reportOffline(db, project.ID, nil, err)
+		}
+		return err
+	}
+
+	if pulled == nil {
+		yellow.Println("Nothing has been pushed to this project yet")
+	} else {
+		green.Printf("✓ Pulled version %d (%d secrets)\n", pulled.Version, pulled.Secrets)
+	}
+
+	if !quiet {
+		fmt.Println()
+		fmt.Println("Next steps:")
+		fmt.Printf("  cd %s\n", dir)
+		fmt.Println("  View variables:   envault list")
+		fmt.Println("  Run with env:     envault run npm start")
+	}
+
+	return nil
+}
+
+// cloneDirName turns a project name from the server into a directory name
+// without separators, so that a name such as "../../x" cannot place the
+// clone outside the current directory. Runs of other characters become a
+// dash.
+func cloneDirName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	// Leading dots would make "." and ".." or a hidden directory
+	return strings.Trim(b.String(), "-.")
+}
+
+// cleanProjectName drops the control characters, such as line breaks, of a
+// project name from the server, so that it is safe to print and to store
+// in the .envault file
+func cleanProjectName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// remoteProjectsEndpoint returns the API remote to list or clone projects
+// from and the credentials to store for it: rawURL if given, otherwise the
+// current project's default remote, otherwise EnvVault cloud
+func remoteProjectsEndpoint(rawURL, apiKey string) (string, *auth.RemoteCredentials, error) {
+	creds := &auth.RemoteCredentials{}
+
+	if rawURL == "" {
+		if project, err := utils.LoadProjectContext(); err == nil {
+			name, projectURL, err := resolveRemote(project, "")
+			if kind, _ := backend.Kind(projectURL); err == nil && name != "" && kind == backend.KindSupabase {
+				rawURL = projectURL
+				if stored, _ := loadRemoteCredentials(project.ProjectID, name); stored != nil {
+					creds.APIKey = stored.APIKey
+				}
+			}
+		}
+	}
+
+	if rawURL == "" {
+		rawURL = backend.KindSupabase
+	}
+
+	if kind, err := backend.Kind(rawURL); err != nil {
+		return "", nil, err
+	} else if kind != backend.KindSupabase {
+		return "", nil, fmt.Errorf("%s is a %s backend; only EnvVault cloud and envault-server list projects", rawURL, kind)
+	}
+
+	if apiKey != "" {
+		creds.APIKey = apiKey
+	}
+
+	return rawURL, creds, nil
+}
+
+// newRemoteProjectsClient creates an authenticated client for an endpoint
+// from remoteProjectsEndpoint
+func newRemoteProjectsClient(rawURL string, creds *auth.RemoteCredentials) (*api.Client, error) {
+	baseURL, apiKey := apiEndpoint(rawURL, creds)
+	if apiKey == "" {
+		return nil, fmt.Errorf("no API key configured\nPass --api-key, run this inside a project with an EnvVault remote or set ENVAULT_API_KEY")
+	}
+
+	project, _ := utils.LoadProjectContext()
+	return newAccountEndpointClient(project, baseURL, apiKey)
+}
+
+// findRemoteProject finds a project on the server by ID or by name. Machine
+// tokens may not be able to list projects, so an ID is trusted as is when
+// listing fails.
+func findRemoteProject(ctx context.Context, client *api.Client, ref string) (*api.Project, error) {
+	projects, err := client.GetProjects(ctx)
+	if err != nil {
+		if _, parseErr := uuid.Parse(ref); parseErr == nil {
+			if debug {
+				utils.Warn("Could not list projects: %v", err)
+			}
+			return &api.Project{ID: ref}, nil
+		}
+		return nil, apiError("failed to list projects", err)
+	}
+
+	var matches []api.Project
+	for _, project := range projects {
+		if project.ID == ref {
+			return &project, nil
+		}
+		if strings.EqualFold(project.Name, ref) {
+			matches = append(matches, project)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		// Tokens from ENVAULT_TOKEN may not be able to list the project
+		if _, parseErr := uuid.Parse(ref); parseErr == nil && envToken() != "" {
+			return &api.Project{ID: ref}, nil
+		}
+		return nil, fmt.Errorf("Error: project %q not found (see 'envault projects --remote')", ref)
+	case 1:
+		return &matches[0], nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, project := range matches {
+		ids[i] = project.ID
+	}
+	return nil, fmt.Errorf("Error: %d projects are named %q; clone one by ID: %s", len(matches), ref, strings.Join(ids, ", "))
+}
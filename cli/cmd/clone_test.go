@@ -0,0 +1,40 @@
+package cmd
+
+import "testing"
+
+func TestCloneDirName(t *testing.T) {
+	tests := map[string]string{
+		"my-app":                   "my-app",
+		"My App":                   "My-App",
+		"Frontend / Backend":       "Frontend-Backend",
+		"../../x":                  "x",
+		"..":                       "",
+		".hidden":                  "hidden",
+		`C:\Windows\System32`:      "C-Windows-System32",
+		"app\ndefault_remote=evil": "app-default_remote-evil",
+		"café.v2":                  "café.v2",
+		"":                         "",
+	}
+
+	for name, want := range tests {
+		if got := cloneDirName(name); got != want {
+			t.Errorf("cloneDirName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCleanProjectName(t *testing.T) {
+	tests := map[string]string{
+		"My App":                        "My App",
+		"  padded  ":                    "padded",
+		"app\ndefault_remote=evil":      "app default_remote=evil",
+		"app\r\nremote.origin=file:///": "app remote.origin=file:///",
+		"red\x1b[31mtext":               "red [31mtext",
+	}
+
+	for name, want := range tests {
+		if got := cleanProjectName(name); got != want {
+			t.Errorf("cleanProjectName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -29,11 +29,21 @@ Configuration includes:
   • Project settings (.envault)
   • Default environment
   • API endpoints
+  • Network settings (proxy, CA bundle, client certificate, pins)
+
+Network settings:
+  network.proxy        Proxy URL (default: HTTPS_PROXY/NO_PROXY)
+  network.no_proxy     Hosts that bypass network.proxy
+  network.ca_bundle    PEM file of extra trusted CAs
+  network.client_cert  PEM client certificate for mutual TLS
+  network.client_key   PEM key for network.client_cert
+  network.pins         Pinned API public keys (sha256/BASE64, comma separated)
 
 Examples:
   envault config show
   envault config set default_environment production
-  envault config get default_environment`,
+  envault config get default_environment
+  envault config set network.ca_bundle ~/certs/corp-root.pem`,
 }
 
 var configShowCmd = &cobra.Command{
@@ -142,11 +152,21 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	}
 
 	envVars := map[string]string{
-		"ENVAULT_ENV":       os.Getenv("ENVAULT_ENV"),
-		"ENVAULT_API_URL":   os.Getenv("ENVAULT_API_URL"),
-		"ENVAULT_API_KEY":   maskIfSet(os.Getenv("ENVAULT_API_KEY")),
-		"ENVAULT_DEBUG":     os.Getenv("ENVAULT_DEBUG"),
-		"ENVAULT_NO_COLOR":  os.Getenv("ENVAULT_NO_COLOR"),
+		"ENVAULT_ENV":          os.Getenv("ENVAULT_ENV"),
+		"ENVAULT_API_URL":      os.Getenv("ENVAULT_API_URL"),
+		"ENVAULT_API_KEY":      maskIfSet(os.Getenv("ENVAULT_API_KEY")),
+		"ENVAULT_DEBUG":        os.Getenv("ENVAULT_DEBUG"),
+		"ENVAULT_PROFILE":      os.Getenv("ENVAULT_PROFILE"),
+		"ENVAULT_TOKEN":        maskIfSet(os.Getenv("ENVAULT_TOKEN")),
+		"ENVAULT_NO_COLOR":     os.Getenv("ENVAULT_NO_COLOR"),
+		"ENVAULT_PROXY":        os.Getenv("ENVAULT_PROXY"),
+		"ENVAULT_NO_PROXY":     os.Getenv("ENVAULT_NO_PROXY"),
+		"ENVAULT_CA_BUNDLE":    os.Getenv("ENVAULT_CA_BUNDLE"),
+		"ENVAULT_CLIENT_CERT":  os.Getenv("ENVAULT_CLIENT_CERT"),
+		"ENVAULT_CLIENT_KEY":   os.Getenv("ENVAULT_CLIENT_KEY"),
+		"ENVAULT_PINNED_CERTS": os.Getenv("ENVAULT_PINNED_CERTS"),
+		"HTTPS_PROXY":          maskIfSet(firstEnv("HTTPS_PROXY", "https_proxy")),
+		"NO_PROXY":             firstEnv("NO_PROXY", "no_proxy"),
 	}
 
 	hasEnvVars := false
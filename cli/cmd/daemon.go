@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/daemon"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultSyncInterval is how often the watcher polls for remote changes
+	DefaultSyncInterval = 30 * time.Second
+	// MinSyncInterval keeps the watcher well inside the backend rate limits
+	MinSyncInterval = 5 * time.Second
+	// MaxSyncBackoff caps the wait between attempts while the backend is down
+	MaxSyncBackoff = 5 * time.Minute
+	// localCheckInterval is how often the outbox is checked for new writes
+	localCheckInterval = 2 * time.Second
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Manage the background sync watcher",
+	Long: `Inspect and control the sync watcher started with 'envault sync --watch'.
+
+The watcher polls for remote changes on an interval and pushes local
+changes shortly after they are made. Each project has at most one
+watcher, tracked by a PID file and a local status socket in
+~/.envault/run.
+
+Subcommands:
+  status    Show the watcher status for the current project
+  stop      Stop the watcher for the current project
+
+Examples:
+  envault sync --watch &
+  envault daemon status
+  envault daemon stop`,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the sync watcher status",
+	RunE:  runDaemonStatus,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the sync watcher",
+	RunE:  runDaemonStop,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+}
+
+// resolveSyncInterval returns the watch interval from the flag, the
+// sync_interval config setting, or the default
+func resolveSyncInterval() (time.Duration, error) {
+	interval := syncInterval
+	if interval == 0 && viper.IsSet("sync_interval") {
+		interval = viper.GetDuration("sync_interval")
+	}
+	if interval == 0 {
+		interval = DefaultSyncInterval
+	}
+
+	if interval < MinSyncInterval {
+		return 0, fmt.Errorf("sync interval must be at least %s", MinSyncInterval)
+	}
+
+	return interval, nil
+}
+
+// syncBackoff returns the wait before the next attempt after the given
+// number of consecutive failures, with ±20% jitter
+func syncBackoff(interval time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 0; i < failures && wait < MaxSyncBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxSyncBackoff {
+		wait = MaxSyncBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(wait)/5*2+1)) - wait/5
+	return wait + jitter
+}
+
+// isFatalSyncError reports whether the watcher should give up instead of
+// backing off and retrying
+func isFatalSyncError(err error) bool {
+	return api.IsUnauthorized(err) || api.IsForbidden(err)
+}
+
+// runSyncWatch keeps the project in sync until interrupted
+func runSyncWatch(cmd *cobra.Command, remote *syncRemote, db *storage.DB, cryptoSvc *crypto.Service, project *utils.ProjectContext) error {
+	cyan := color.New(color.FgCyan)
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	interval, err := resolveSyncInterval()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	if err := cfg.EnsureDirectories(); err != nil {
+		return fmt.Errorf("failed to ensure directories: %w", err)
+	}
+
+	pidPath, socketPath := daemon.Paths(cfg.RunDir, project.ProjectID)
+	if err := daemon.AcquirePIDFile(pidPath); err != nil {
+		return err
+	}
+	defer daemon.ReleasePIDFile(pidPath)
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	state := daemon.NewState(project.ProjectID, project.ProjectName, interval)
+
+	server, err := daemon.Listen(socketPath, state, cancel)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	// Writes made while watching must be queued so they get pushed
+	if err := db.SetProjectSyncEnabled(project.ProjectID, true); err != nil {
+		return err
+	}
+
+	logf := func(c *color.Color, format string, args ...interface{}) {
+		if quiet && c != yellow {
+			return
+		}
+		c.Printf("[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	}
+
+	logf(cyan, "Watching %s on %s every %s (Ctrl-C to stop)", project.ProjectName, remote.Name(), interval)
+
+	// pushBlocked is set while the role in the project does not allow pushing
+	pushBlocked := false
+
+	// cycle pulls remote changes (unless pushOnly) and pushes queued writes
+	cycle := func(pushOnly bool) error {
+		state.Update(func(s *daemon.Status) { s.State = daemon.StateSyncing })
+
+		if !pushOnly {
+			pulled, err := pullProject(ctx, remote, db, cryptoSvc, project.ProjectID)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			state.Update(func(s *daemon.Status) { s.LastPullAt = &now })
+
+			if pulled != nil {
+				state.Update(func(s *daemon.Status) { s.Version = pulled.Version })
+				logf(green, "↓ Pulled version %d (%d secrets)", pulled.Version, pulled.Secrets)
+			}
+		}
+
+		pending, err := db.CountOutbox(project.ProjectID)
+		if err != nil {
+			return err
+		}
+
+		// Viewers' changes stay queued; warn once, and let the poll timer
+		// check again in case the role changes
+		if pending > 0 {
+			if err := checkPushAllowed(ctx, remote, project, pending); err != nil {
+				if !pushBlocked {
+					logf(yellow, "⚠ %v", err)
+				}
+				pushBlocked = true
+			} else {
+				pushBlocked = false
+			}
+		}
+
+		if pending > 0 && !pushBlocked {
+			pushed, err := pushProject(ctx, remote, db, cryptoSvc, project.ProjectID)
+			if err != nil {
+				return err
+			}
+
+			if pushed != nil {
+				now := time.Now()
+				state.Update(func(s *daemon.Status) {
+					s.LastPushAt = &now
+					s.Version = pushed.Version
+				})
+				logf(green, "↑ Pushed version %d (%d changes)", pushed.Version, pushed.Flushed)
+			}
+
+			pending, _ = db.CountOutbox(project.ProjectID)
+		}
+
+		state.Update(func(s *daemon.Status) {
+			s.Pending = pending
+			s.State = daemon.StateIdle
+			s.Failures = 0
+			s.LastError = ""
+		})
+
+		return nil
+	}
+
+	failures := 0
+	pollTimer := time.NewTimer(0)
+	defer pollTimer.Stop()
+	localTicker := time.NewTicker(localCheckInterval)
+	defer localTicker.Stop()
+
+	schedule := func(err error) error {
+		wait := interval
+		if err != nil {
+			if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				return nil
+			}
+			if isFatalSyncError(err) {
+				return err
+			}
+
+			failures++
+			wait = syncBackoff(interval, failures)
+			logf(yellow, "⚠ Sync failed (attempt %d), retrying in %s: %v", failures, wait.Round(time.Second), err)
+
+			state.Update(func(s *daemon.Status) {
+				s.State = daemon.StateBackoff
+				s.Failures = failures
+				s.LastError = err.Error()
+			})
+		} else {
+			failures = 0
+		}
+
+		next := time.Now().Add(wait)
+		state.Update(func(s *daemon.Status) { s.NextPollAt = &next })
+
+		if !pollTimer.Stop() {
+			select {
+			case <-pollTimer.C:
+			default:
+			}
+		}
+		pollTimer.Reset(wait)
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logf(cyan, "Stopping sync watcher")
+			return nil
+
+		case <-pollTimer.C:
+			if err := schedule(cycle(false)); err != nil {
+				return apiError("sync watcher stopped", err)
+			}
+
+		case <-localTicker.C:
+			// While backing off or unable to push, the poll timer decides
+			// when to try again
+			if failures > 0 || pushBlocked {
+				continue
+			}
+
+			pending, err := db.CountOutbox(project.ProjectID)
+			if err != nil || pending == 0 {
+				continue
+			}
+
+			if err := cycle(true); err != nil {
+				if err := schedule(err); err != nil {
+					return apiError("sync watcher stopped", err)
+				}
+			}
+		}
+	}
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	cyan := color.New(color.FgCyan)
+	yellow := color.New(color.FgYellow)
+
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	pidPath, socketPath := daemon.Paths(cfg.RunDir, ctx.ProjectID)
+	if _, err := daemon.ReadPIDFile(pidPath); err != nil {
+		yellow.Println("Sync watcher is not running")
+		fmt.Println("Start it with: envault sync --watch")
+		return nil
+	}
+
+	status, err := daemon.Query(socketPath, "status")
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	cyan.Printf("Sync watcher for %s\n", status.Project)
+	fmt.Printf("  PID:         %d\n", status.PID)
+	fmt.Printf("  State:       %s\n", status.State)
+	fmt.Printf("  Interval:    %s\n", status.Interval)
+	fmt.Printf("  Running for: %s\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("  Version:     %d\n", status.Version)
+	fmt.Printf("  Pending:     %d changes\n", status.Pending)
+	if status.LastPullAt != nil {
+		fmt.Printf("  Last pull:   %s\n", status.LastPullAt.Format("15:04:05"))
+	}
+	if status.LastPushAt != nil {
+		fmt.Printf("  Last push:   %s\n", status.LastPushAt.Format("15:04:05"))
+	}
+	if status.NextPollAt != nil {
+		fmt.Printf("  Next poll:   %s\n", status.NextPollAt.Format("15:04:05"))
+	}
+	if status.LastError != "" {
+		yellow.Printf("  Last error:  %s (%d consecutive failures)\n", status.LastError, status.Failures)
+	}
+
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	pidPath, socketPath := daemon.Paths(cfg.RunDir, ctx.ProjectID)
+	pid, err := daemon.ReadPIDFile(pidPath)
+	if err != nil {
+		yellow.Println("Sync watcher is not running")
+		return nil
+	}
+
+	if _, err := daemon.Query(socketPath, "stop"); err != nil {
+		return fmt.Errorf("failed to stop sync watcher (pid %d): %w", pid, err)
+	}
+
+	green.Printf("✓ Stopped sync watcher (pid %d)\n", pid)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
@@ -135,6 +136,11 @@ func runEnvCreate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "creating environments"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -159,6 +165,11 @@ func runEnvCreate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create environment: %w", err)
 	}
 
+	// Queue the change for the next sync
+	if err := queueSyncChange(db, ctx.ProjectID, models.OutboxEnvironmentCreated, envName, "", nil); err != nil {
+		yellow.Printf("Warning: Failed to queue change for sync: %v\n", err)
+	}
+
 	// Create audit log
 	metadata := fmt.Sprintf(`{"environment":"%s"}`, envName)
 	if err := db.CreateAuditLog(ctx.ProjectID, "environment_created", metadata); err != nil {
@@ -212,6 +223,11 @@ func runEnvDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "deleting environments"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -253,6 +269,11 @@ func runEnvDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to delete environment: %w", err)
 	}
 
+	// Queue the change for the next sync
+	if err := queueSyncChange(db, ctx.ProjectID, models.OutboxEnvironmentDeleted, envName, "", nil); err != nil {
+		yellow.Printf("Warning: Failed to queue change for sync: %v\n", err)
+	}
+
 	// Create audit log
 	metadata := fmt.Sprintf(`{"environment":"%s","secrets_deleted":%d}`, envName, len(secrets))
 	if err := db.CreateAuditLog(ctx.ProjectID, "environment_deleted", metadata); err != nil {
@@ -278,6 +299,11 @@ func runEnvCopy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "copying environments"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -360,6 +386,10 @@ func copyEnvironmentVariables(db *storage.DB, projectID, sourceEnvName, targetEn
 		if _, err := db.CreateSecret(targetEnv.ID, secret.Key, encrypted, secret.Description); err != nil {
 			return fmt.Errorf("failed to copy %s: %w", secret.Key, err)
 		}
+
+		if err := queueSyncChange(db, projectID, models.OutboxSecretSet, targetEnvName, secret.Key, encrypted); err != nil {
+			return fmt.Errorf("failed to queue %s for sync: %w", secret.Key, err)
+		}
 	}
 
 	return nil
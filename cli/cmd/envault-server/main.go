@@ -0,0 +1,363 @@
+// Command envault-server is a self-hostable EnvVault sync server. It serves
+// the same RPC API as EnvVault cloud from a single SQLite file, so teams can
+// keep their encrypted blobs on their own infrastructure.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/server"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Version is set during build
+	Version = "dev"
+)
+
+var (
+	dbPath      string
+	listenAddr  string
+	apiKey      string
+	tlsCert     string
+	tlsKey      string
+	tokenName   string
+	tokenExpiry string
+	jsonOutput  bool
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "envault-server",
+	Short: "Self-hosted EnvVault sync server",
+	Long: `envault-server stores encrypted EnvVault blobs and team membership in a
+single SQLite database. It implements the same API as EnvVault cloud, so
+the CLI only needs to be pointed at it:
+
+  envault remote add office https://envault.internal.example.com
+
+The server never sees plaintext secrets: blobs are encrypted by the CLI
+before upload.
+
+Examples:
+  envault-server user add alice@example.com
+  envault-server token create alice@example.com --name laptop
+  envault-server serve --addr :8080 --db /var/lib/envault/server.db`,
+	Version:       Version,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the HTTP server",
+	Long: `Run the HTTP server until interrupted.
+
+If --api-key (or ENVAULT_SERVER_API_KEY) is set, clients must send it as
+their ENVAULT_API_KEY. Serve over TLS with --tls-cert/--tls-key or put the
+server behind a TLS-terminating reverse proxy.
+
+Examples:
+  envault-server serve
+  envault-server serve --addr 127.0.0.1:8080
+  envault-server serve --tls-cert server.crt --tls-key server.key`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user accounts",
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add EMAIL",
+	Short: "Create a user account",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUserAdd,
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List user accounts",
+	Args:  cobra.NoArgs,
+	RunE:  runUserList,
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage CLI tokens",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create EMAIL",
+	Short: "Issue a CLI token for a user",
+	Long: `Issue a CLI token for a user. The token is printed once and cannot be
+recovered; the user signs in with 'envault login --token TOKEN'.
+
+Examples:
+  envault-server token create alice@example.com --name laptop
+  envault-server token create ci@example.com --name github-actions --expires 30d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokenCreate,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list EMAIL",
+	Short: "List a user's CLI tokens",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTokenList,
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke TOKEN_ID",
+	Short: "Revoke a CLI token",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTokenRevoke,
+}
+
+func init() {
+	defaultDB := os.Getenv("ENVAULT_SERVER_DB")
+	if defaultDB == "" {
+		defaultDB = "envault-server.db"
+	}
+
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", defaultDB, "Path to the SQLite database (env ENVAULT_SERVER_DB)")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+
+	serveCmd.Flags().StringVar(&listenAddr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&apiKey, "api-key", os.Getenv("ENVAULT_SERVER_API_KEY"), "Require this API key from clients (env ENVAULT_SERVER_API_KEY)")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file")
+
+	tokenCreateCmd.Flags().StringVar(&tokenName, "name", "cli", "Name to identify the token")
+	tokenCreateCmd.Flags().StringVar(&tokenExpiry, "expires", "90d", "Token lifetime, e.g. 90d or 12h (0 = never)")
+
+	userCmd.AddCommand(userAddCmd, userListCmd)
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	rootCmd.AddCommand(serveCmd, userCmd, tokenCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if (tlsCert == "") != (tlsKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be used together")
+	}
+
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	srv := &http.Server{
+		Addr:              listenAddr,
+		Handler:           server.New(store, server.Options{APIKey: apiKey, Logger: logger}).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsCert != "" {
+			errCh <- srv.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+
+	logger.Printf("envault-server %s listening on %s (database %s)", Version, listenAddr, dbPath)
+	if apiKey == "" {
+		logger.Printf("warning: no API key configured; any client with a valid token can connect")
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	logger.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
+
+func runUserAdd(cmd *cobra.Command, args []string) error {
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := store.CreateUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(user)
+	}
+
+	fmt.Printf("✓ Created user %s (%s)\n", user.Email, user.ID)
+	fmt.Printf("  Issue a CLI token with: envault-server token create %s\n", user.Email)
+	return nil
+}
+
+func runUserList(cmd *cobra.Command, args []string) error {
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	users, err := store.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(users)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Email", "ID", "Created"})
+	for _, user := range users {
+		table.Append([]string{user.Email, user.ID, user.CreatedAt.Format("2006-01-02")})
+	}
+	table.Render()
+	return nil
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) error {
+	ttl, err := utils.ParseTTL(tokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := store.GetUserByEmail(args[0])
+	if errors.Is(err, server.ErrNotFound) {
+		return fmt.Errorf("no user with email %s (create one with 'envault-server user add')", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	plaintext, token, err := store.CreateToken(user.ID, tokenName, ttl)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(struct {
+			*server.Token
+			Secret string `json:"token"`
+		}{token, plaintext})
+	}
+
+	fmt.Printf("✓ Created token %q for %s\n\n", token.Name, user.Email)
+	fmt.Printf("  %s\n\n", plaintext)
+	if token.ExpiresAt != nil {
+		fmt.Printf("Expires: %s\n", token.ExpiresAt.Format("2006-01-02"))
+	}
+	fmt.Println("Save this token securely - it will not be shown again.")
+	fmt.Println("Sign in with: envault login --token <token>")
+	return nil
+}
+
+func runTokenList(cmd *cobra.Command, args []string) error {
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := store.GetUserByEmail(args[0])
+	if errors.Is(err, server.ErrNotFound) {
+		return fmt.Errorf("no user with email %s", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	tokens, err := store.ListTokens(user.ID)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(tokens)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Name", "Last Used", "Expires"})
+	for _, token := range tokens {
+		lastUsed, expires := "never", "never"
+		if token.LastUsedAt != nil {
+			lastUsed = token.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		if token.ExpiresAt != nil {
+			expires = token.ExpiresAt.Format("2006-01-02")
+		}
+		table.Append([]string{token.ID, token.Name, lastUsed, expires})
+	}
+	table.Render()
+	return nil
+}
+
+func runTokenRevoke(cmd *cobra.Command, args []string) error {
+	store, err := server.OpenStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.RevokeToken(args[0]); err != nil {
+		if errors.Is(err, server.ErrNotFound) {
+			return fmt.Errorf("token %s not found", args[0])
+		}
+		return err
+	}
+
+	fmt.Printf("✓ Revoked token %s\n", args[0])
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dj-pearson/envault/internal/api"
+)
+
+// ExitError reports that envault should exit with Code without printing
+// anything, e.g. to pass on the exit code of a command started by 'envault
+// run'
+type ExitError struct {
+	Code int
+}
+
+// ExitOffline is the exit code of sync and clone when the sync backend could
+// not be reached, so scripts can tell that nothing was synced
+const ExitOffline = 7
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// apiError wraps an error returned by the API client with guidance the user
+// can act on. The original error stays in the chain so callers can still use
+// the api.Is* helpers on the result.
+func apiError(action string, err error) error {
+	switch {
+	case api.IsUnauthorized(err):
+		return fmt.Errorf("%s: %w\nYour session has expired or the token was revoked. Run 'envault login' to sign in again", action, err)
+	case api.IsForbidden(err):
+		return fmt.Errorf("%s: %w\nYou don't have permission for this project. Ask a project admin to grant you access", action, err)
+	case api.IsRateLimited(err):
+		return fmt.Errorf("%s: %w\nToo many requests. Wait a minute and try again", action, err)
+	case api.IsFunctionNotFound(err):
+		return fmt.Errorf("%s: %w\nThe server does not provide this function. It probably runs a different version of EnvVault than this CLI", action, err)
+	}
+
+	if apiErr, ok := api.AsError(err); ok && apiErr.Hint != "" {
+		return fmt.Errorf("%s: %w\nHint: %s", action, err, apiErr.Hint)
+	}
+
+	return fmt.Errorf("%s: %w", action, err)
+}
@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/envfile"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
@@ -15,15 +15,37 @@ import (
 )
 
 var (
-	exportEnv    string
-	exportOutput string
-	exportFormat string
+	exportEnv       string
+	exportOutput    string
+	exportFormat    string
+	exportName      string
+	exportNamespace string
 )
 
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "Export environment variables to a file",
-	Long: `Export environment variables to .env, JSON, or YAML format.
+	Long: `Export environment variables to a file format other tools read.
+
+Formats:
+  dotenv         .env file (default)
+  json, yaml     JSON object or YAML mapping
+  k8s-secret     Kubernetes Secret manifest (base64 data)
+  k8s-configmap  Kubernetes ConfigMap manifest
+  docker         docker run --env-file
+  systemd        systemd EnvironmentFile
+  tfvars         Terraform .tfvars
+  bash           export statements for bash, zsh and sh
+  fish           set -gx statements for fish
+  powershell     $env: assignments for PowerShell
+  properties     Java .properties
+  toml           TOML key/value pairs
+
+Values are quoted and escaped so the target tool reads back exactly what
+is in the vault. Formats that cannot hold a value, such as a multiline
+value in a Docker env file, fail with an error instead of writing a broken
+file. Kubernetes manifests are named after the project and environment
+unless --name is given.
 
 By default, exports to stdout. Use --output to write to a file.
 
@@ -35,7 +57,11 @@ Examples:
   envault export --output .env
   envault export --env production --output .env.production
   envault export --format json > config.json
-  envault export --format yaml --output config.yml`,
+  envault export --format yaml --output config.yml
+  envault export --env production --format k8s-secret --namespace web | kubectl apply -f -
+  envault export --format docker --output app.env && docker run --env-file app.env app
+  eval "$(envault export --format bash)"
+  envault export --format powershell | Out-String | Invoke-Expression`,
 	RunE: runExport,
 }
 
@@ -44,7 +70,9 @@ func init() {
 
 	exportCmd.Flags().StringVarP(&exportEnv, "env", "e", "development", "Environment to export")
 	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file (default: stdout)")
-	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "dotenv", "Format: dotenv, json, yaml")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "dotenv", "Format: "+strings.Join(envfile.Formats(), ", "))
+	exportCmd.Flags().StringVar(&exportName, "name", "", "Name of Kubernetes manifests (default: PROJECT-ENV)")
+	exportCmd.Flags().StringVar(&exportNamespace, "namespace", "", "Namespace of Kubernetes manifests")
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
@@ -100,6 +128,18 @@ func runExport(cmd *cobra.Command, args []string) error {
 		decrypted[secret.Key] = value
 	}
 
+	// Generate output based on format
+	name := exportName
+	if name == "" {
+		name = envfile.KubernetesName(ctx.ProjectName + "-" + exportEnv)
+	}
+
+	data, err := envfile.Export(exportFormat, decrypted, envfile.Options{Name: name, Namespace: exportNamespace})
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+	output := string(data)
+
 	// SECURITY SAFEGUARD: Warn about plaintext export
 	if exportOutput != "" && !quiet {
 		yellow.Printf("⚠ WARNING: Exporting %d secrets to plaintext file: %s\n", len(decrypted), exportOutput)
@@ -107,33 +147,6 @@ func runExport(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
-	// Generate output based on format
-	var output string
-	switch exportFormat {
-	case "json":
-		data, err := json.MarshalIndent(decrypted, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-		output = string(data)
-
-	case "yaml", "yml":
-		// Simple YAML output (no external dependency)
-		for key, value := range decrypted {
-			output += fmt.Sprintf("%s: %s\n", key, value)
-		}
-
-	default: // dotenv format
-		for key, value := range decrypted {
-			// Quote values that contain spaces or special chars
-			if needsQuoting(value) {
-				output += fmt.Sprintf("%s=\"%s\"\n", key, escapeValue(value))
-			} else {
-				output += fmt.Sprintf("%s=%s\n", key, value)
-			}
-		}
-	}
-
 	// Write to file or stdout
 	if exportOutput != "" {
 		if err := os.WriteFile(exportOutput, []byte(output), 0600); err != nil {
@@ -155,34 +168,3 @@ func runExport(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
-
-func needsQuoting(value string) bool {
-	// Quote if contains spaces, quotes, or special chars
-	specialChars := []string{" ", "\"", "'", "#", "$", "&", "|", ";", "<", ">", "(", ")", "{", "}"}
-	for _, char := range specialChars {
-		if contains(value, char) {
-			return true
-		}
-	}
-	return false
-}
-
-func contains(s, substr string) bool {
-	return len(s) > 0 && len(substr) > 0 && (s == substr || (len(s) >= len(substr) && containsHelper(s, substr)))
-}
-
-func containsHelper(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
-func escapeValue(value string) string {
-	// Escape quotes and backslashes
-	value = strings.ReplaceAll(value, "\\", "\\\\")
-	value = strings.ReplaceAll(value, "\"", "\\\"")
-	return value
-}
@@ -38,7 +38,7 @@ func init() {
 
 	getCmd.Flags().StringVarP(&getEnv, "env", "e", "development", "Environment")
 	getCmd.Flags().BoolVar(&getShowDescription, "show-description", false, "Show description")
-	getCmd.Flags().StringVarP(&getFormat, "format", "f", "plain", "Output format: plain, json, export")
+	getCmd.Flags().StringVarP(&getFormat, "format", "f", "plain", "Output format: plain, json, yaml, export, fish, powershell, ... (see 'envault export --help')")
 }
 
 func runGet(cmd *cobra.Command, args []string) error {
@@ -86,7 +86,10 @@ func runGet(cmd *cobra.Command, args []string) error {
 	}
 
 	// Output based on format
-	output := utils.FormatEnvVar(key, value, getFormat)
+	output, err := utils.FormatEnvVar(key, value, getFormat)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 	fmt.Println(output)
 
 	if getShowDescription && secret.Description != "" {
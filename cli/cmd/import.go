@@ -2,38 +2,80 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/envfile"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
-	"strings"
 )
 
 var (
 	importEnv       string
 	importOverwrite bool
 	importDryRun    bool
+	importFormat    string
+	importSeparator string
+	importService   string
+	importFromEnv   string
+
+	importFrom          string
+	importKeyTemplate   string
+	importEnvBy         string
+	importEnvMap        map[string]string
+	importIncludeFields bool
 )
 
 var importCmd = &cobra.Command{
 	Use:   "import FILE",
-	Short: "Import environment variables from a .env file",
-	Long: `Import environment variables from a .env file into encrypted storage.
-
-By default, existing variables are not overwritten. Use --overwrite to replace them.
-Use --dry-run to preview what would be imported without making changes.
+	Short: "Import environment variables from a file or the shell",
+	Long: `Import environment variables into encrypted storage from a file, or from
+the current shell with --from-env.
+
+Supported formats (detected from the file name and content, or set with --format):
+  dotenv       KEY=value lines, as read by docker compose and most dotenv libraries
+  json, yaml   Objects; nested keys are joined with --separator (db.host -> db_host)
+  k8s-secret   Kubernetes Secret (data is base64-decoded) or ConfigMap manifests
+  compose      environment: blocks of a docker-compose file (pick one with --service)
+  raw          KEY=VALUE or KEY: VALUE dumps such as 'heroku config' or values
+               copied from the Vercel dashboard; values are taken literally
+
+Password manager exports are read with --from 1password-csv, bitwarden-json
+or keepass-xml. Each entry's password (or its notes, if it has no password)
+is stored under a key named by --key-template, a Go template over the
+entry's .Title, .Folder, .Username, .URL and .Tags, turned into a valid
+name: "Stripe API key" becomes STRIPE_API_KEY. --include-fields also stores
+the username, URL and custom fields as KEY_USERNAME, KEY_URL and so on.
+
+With --env-by folder or --env-by tag, each entry goes to the environment
+named like its folder (or the last part of the folder path) or its tags,
+ignoring case. --env-map maps other names, and * catches the rest:
+  --env-by folder --env-map 'Prod Vault=production,*=development'
+Entries without an environment are skipped.
+
+Existing values are kept in the history like with 'envault set', and every
+change is written to the audit log. By default, existing variables are not
+overwritten; use --overwrite to replace them. Use --dry-run to see which
+variables would be added, changed or left alone. Use - to read from stdin.
 
 Examples:
   envault import .env
   envault import .env.production --env production
-  envault import .env --overwrite
-  envault import .env --dry-run`,
-	Args: cobra.ExactArgs(1),
+  envault import config.json --separator __
+  envault import secret.yaml --env production --dry-run
+  envault import docker-compose.yml --service api
+  heroku config --shell -a my-app | envault import - --overwrite
+  envault import --from-env 'MYAPP_*'
+  envault import --from bitwarden-json bitwarden.json --env-by folder --dry-run
+  envault import --from keepass-xml db.xml --env-by tag --key-template '{{.Folder}} {{.Title}}'`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runImport,
 }
 
@@ -43,6 +85,42 @@ func init() {
 	importCmd.Flags().StringVarP(&importEnv, "env", "e", "development", "Target environment")
 	importCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "Overwrite existing variables")
 	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Preview without importing")
+	importCmd.Flags().StringVar(&importFormat, "format", "auto", "File format ("+strings.Join(envfile.ImportFormats, ", ")+")")
+	importCmd.Flags().StringVar(&importSeparator, "separator", "_", "Separator for nested JSON and YAML keys")
+	importCmd.Flags().StringVar(&importService, "service", "", "docker-compose service to import")
+	importCmd.Flags().StringVar(&importFromEnv, "from-env", "", "Import shell variables matching a pattern, e.g. 'MYAPP_*'")
+
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Password manager export ("+strings.Join(envfile.PasswordManagerFormats, ", ")+")")
+	importCmd.Flags().StringVar(&importKeyTemplate, "key-template", "{{.Title}}", "Key name for password manager entries")
+	importCmd.Flags().StringVar(&importEnvBy, "env-by", "", "Pick the environment of each entry by folder or tag")
+	importCmd.Flags().StringToStringVar(&importEnvMap, "env-map", nil, "Map folders or tags to environments, e.g. 'Prod=production,*=development'")
+	importCmd.Flags().BoolVar(&importIncludeFields, "include-fields", false, "Also import usernames, URLs and custom fields")
+}
+
+// importVar is a variable to import and the environment it goes to
+type importVar struct {
+	Env   string
+	Key   string
+	Value string
+	// Origin is the password manager entry the variable came from
+	Origin string
+}
+
+// importChange is what importing a variable would do
+type importChange int
+
+const (
+	importNew importChange = iota
+	importChanged
+	importUnchanged
+	importInvalid
+)
+
+// importStep is a variable with the change importing it would make
+type importStep struct {
+	importVar
+	environment *models.Environment
+	change      importChange
 }
 
 func runImport(cmd *cobra.Command, args []string) error {
@@ -51,7 +129,9 @@ func runImport(cmd *cobra.Command, args []string) error {
 	red := color.New(color.FgRed)
 	cyan := color.New(color.FgCyan)
 
-	filePath := args[0]
+	if err := validateImportArgs(cmd, args); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
 	// Load project context
 	ctx, err := utils.LoadProjectContext()
@@ -59,14 +139,9 @@ func runImport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
-	// Read .env file
-	envMap, err := godotenv.Read(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filePath, err)
-	}
-
-	if len(envMap) == 0 {
-		return fmt.Errorf("no variables found in %s", filePath)
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "importing secrets"); err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
 	// Initialize services
@@ -86,69 +161,117 @@ func runImport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize crypto: %w", err)
 	}
 
-	// Get environment
-	environment, err := db.GetEnvironment(ctx.ProjectID, importEnv)
-	if err != nil {
-		return fmt.Errorf("environment '%s' not found: %w", importEnv, err)
+	// Read the variables
+	var vars []importVar
+	var source, auditSource string
+	switch {
+	case importFromEnv != "":
+		envMap, err := envfile.FromEnviron(os.Environ(), importFromEnv)
+		if err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+		vars = importVarsFromMap(envMap, importEnv)
+		source = fmt.Sprintf("shell variables matching %s", importFromEnv)
+		auditSource = "env_import"
+	case importFrom != "":
+		environments, err := db.ListEnvironments(ctx.ProjectID)
+		if err != nil {
+			return fmt.Errorf("failed to list environments: %w", err)
+		}
+		vars, err = readPasswordManagerExport(args[0], importFrom, environments, yellow)
+		if err != nil {
+			return err
+		}
+		source = fmt.Sprintf("%s (%s)", args[0], importFrom)
+		auditSource = importFrom
+	default:
+		envMap, format, err := readImportFile(args[0], importFormat, envfile.ParseOptions{
+			Separator: importSeparator,
+			Service:   importService,
+		})
+		if err != nil {
+			return err
+		}
+		vars = importVarsFromMap(envMap, importEnv)
+		source = fmt.Sprintf("%s (%s)", args[0], format)
+		auditSource = "file_import"
+	}
+
+	if len(vars) == 0 {
+		return fmt.Errorf("no variables found in %s", source)
 	}
 
-	// Get existing secrets
-	existingSecrets, err := db.ListSecrets(environment.ID)
+	// Work out what each variable would do
+	steps, err := planImport(db, cryptoSvc, ctx.ProjectID, vars)
 	if err != nil {
-		return fmt.Errorf("failed to list existing secrets: %w", err)
+		return err
 	}
 
-	existingKeys := make(map[string]bool)
-	for _, secret := range existingSecrets {
-		existingKeys[secret.Key] = true
+	counts := make(map[importChange]int)
+	environments := make(map[string]bool)
+	for _, step := range steps {
+		counts[step.change]++
+		environments[step.Env] = true
+	}
+
+	// Name variables env/KEY when they go to several environments
+	name := func(step importStep) string {
+		if len(environments) > 1 {
+			return step.Env + "/" + step.Key
+		}
+		return step.Key
+	}
+	target := fmt.Sprintf("%s environment", steps[0].Env)
+	if len(environments) > 1 {
+		target = fmt.Sprintf("%d environments", len(environments))
 	}
 
 	// Preview mode
 	if importDryRun {
-		cyan.Printf("Would import %d variables to %s environment:\n\n", len(envMap), importEnv)
+		cyan.Printf("Would import %d variables from %s to %s:\n\n", len(steps), source, target)
 
-		newCount := 0
-		existsCount := 0
+		for _, step := range steps {
+			origin := ""
+			if step.Origin != "" {
+				origin = fmt.Sprintf(", from %q", step.Origin)
+			}
 
-		for key := range envMap {
-			if existingKeys[key] {
+			switch step.change {
+			case importNew:
+				green.Printf("  + %s (new%s)\n", name(step), origin)
+			case importChanged:
 				if importOverwrite {
-					yellow.Printf("  %s (exists, would overwrite)\n", key)
+					yellow.Printf("  ~ %s (changed, would overwrite%s)\n", name(step), origin)
 				} else {
-					yellow.Printf("  %s (exists, would skip)\n", key)
+					yellow.Printf("  ~ %s (changed, would skip%s)\n", name(step), origin)
 				}
-				existsCount++
-			} else {
-				green.Printf("  %s (new)\n", key)
-				newCount++
+			case importUnchanged:
+				fmt.Printf("  = %s (unchanged%s)\n", name(step), origin)
+			case importInvalid:
+				red.Printf("  ! %s (%v%s)\n", name(step), utils.ValidateEnvKey(step.Key), origin)
 			}
 		}
 
 		fmt.Println()
-		cyan.Printf("Summary: %d new, %d existing\n", newCount, existsCount)
+		cyan.Printf("Summary: %d new, %d changed, %d unchanged, %d invalid\n",
+			counts[importNew], counts[importChanged], counts[importUnchanged], counts[importInvalid])
+		if counts[importChanged] > 0 && !importOverwrite {
+			yellow.Println("Use --overwrite to replace changed variables")
+		}
 		return nil
 	}
 
 	// Confirm if overwriting
-	if importOverwrite && len(existingKeys) > 0 && !quiet {
-		overwriteCount := 0
-		for key := range envMap {
-			if existingKeys[key] {
-				overwriteCount++
-			}
+	if importOverwrite && counts[importChanged] > 0 && !quiet {
+		prompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Overwrite %d existing variables", counts[importChanged]),
+			IsConfirm: true,
 		}
 
-		if overwriteCount > 0 {
-			prompt := promptui.Prompt{
-				Label:     fmt.Sprintf("Overwrite %d existing variables", overwriteCount),
-				IsConfirm: true,
-			}
-
-			result, err := prompt.Run()
-			if err != nil || strings.ToLower(result) != "y" {
-				yellow.Println("Cancelled")
-				return nil
-			}
+		result, err := prompt.Run()
+		if err != nil || strings.ToLower(result) != "y" {
+			yellow.Println("Cancelled")
+			return nil
 		}
 	}
 
@@ -157,34 +280,28 @@ func runImport(cmd *cobra.Command, args []string) error {
 	skipped := 0
 	failed := 0
 
-	for key, value := range envMap {
-		// Validate key
-		if err := utils.ValidateEnvKey(key); err != nil {
-			yellow.Printf("⚠ Skipping invalid key: %s (%v)\n", key, err)
+	for _, step := range steps {
+		switch step.change {
+		case importInvalid:
+			yellow.Printf("⚠ Skipping invalid key: %s (%v)\n", name(step), utils.ValidateEnvKey(step.Key))
 			failed++
 			continue
-		}
-
-		// Check if exists and skip if not overwriting
-		if existingKeys[key] && !importOverwrite {
-			if debug {
-				yellow.Printf("  Skipping %s (already exists)\n", key)
-			}
-			skipped++
-			continue
-		}
-
-		// Encrypt value
-		encrypted, err := cryptoSvc.Encrypt(value)
-		if err != nil {
-			yellow.Printf("⚠ Failed to encrypt %s: %v\n", key, err)
-			failed++
+		case importUnchanged:
 			continue
+		case importChanged:
+			// Skip existing variables if not overwriting
+			if !importOverwrite {
+				if debug {
+					yellow.Printf("  Skipping %s (already exists)\n", name(step))
+				}
+				skipped++
+				continue
+			}
 		}
 
-		// Store secret
-		if _, err := db.CreateSecret(environment.ID, key, encrypted, ""); err != nil {
-			yellow.Printf("⚠ Failed to store %s: %v\n", key, err)
+		// Store the secret with history and audit log, like set
+		if _, err := storeSecret(db, cryptoSvc, ctx.ProjectID, step.environment, step.Key, step.Value, "", auditSource); err != nil {
+			yellow.Printf("⚠ Failed to store %s: %v\n", name(step), err)
 			failed++
 			continue
 		}
@@ -195,7 +312,10 @@ func runImport(cmd *cobra.Command, args []string) error {
 	// Summary
 	if !quiet {
 		fmt.Println()
-		green.Printf("✓ Imported %d variables to %s environment\n", imported, importEnv)
+		green.Printf("✓ Imported %d variables to %s\n", imported, target)
+		if unchanged := counts[importUnchanged]; unchanged > 0 {
+			fmt.Printf("  %d variables were already up to date\n", unchanged)
+		}
 		if skipped > 0 {
 			yellow.Printf("  Skipped %d existing variables (use --overwrite to replace)\n", skipped)
 		}
@@ -206,3 +326,131 @@ func runImport(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// validateImportArgs checks that exactly one source was given and that the
+// flags fit it
+func validateImportArgs(cmd *cobra.Command, args []string) error {
+	switch {
+	case importFromEnv != "" && (len(args) > 0 || importFrom != ""):
+		return fmt.Errorf("--from-env cannot be combined with a FILE or --from")
+	case importFromEnv == "" && len(args) == 0:
+		return fmt.Errorf("specify a FILE or --from-env PATTERN")
+	}
+
+	if importFrom == "" {
+		for _, flag := range []string{"key-template", "env-by", "env-map", "include-fields"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("--%s only applies to --from", flag)
+			}
+		}
+		return nil
+	}
+
+	if cmd.Flags().Changed("format") {
+		return fmt.Errorf("--format cannot be combined with --from")
+	}
+	switch importEnvBy {
+	case "", "folder", "tag":
+	default:
+		return fmt.Errorf("--env-by must be folder or tag")
+	}
+	if len(importEnvMap) > 0 && importEnvBy == "" {
+		return fmt.Errorf("--env-map needs --env-by folder or --env-by tag")
+	}
+
+	return nil
+}
+
+// importVarsFromMap puts all variables of a file into one environment
+func importVarsFromMap(envMap map[string]string, envName string) []importVar {
+	vars := make([]importVar, 0, len(envMap))
+	for key, value := range envMap {
+		vars = append(vars, importVar{Env: envName, Key: key, Value: value})
+	}
+	return vars
+}
+
+// planImport compares the variables with the secrets they would replace.
+// The steps are sorted by environment and key. Two entries giving the same
+// key different values are an error.
+func planImport(db *storage.DB, cryptoSvc *crypto.Service, projectID string, vars []importVar) ([]importStep, error) {
+	environments := make(map[string]*models.Environment)
+	existing := make(map[string]map[string]string)
+	planned := make(map[string]int)
+
+	var steps []importStep
+	for _, v := range vars {
+		environment, ok := environments[v.Env]
+		if !ok {
+			var err error
+			environment, err = db.GetEnvironment(projectID, v.Env)
+			if err != nil {
+				return nil, fmt.Errorf("environment '%s' not found: %w", v.Env, err)
+			}
+			environments[v.Env] = environment
+
+			// Get existing secrets
+			secrets, err := db.ListSecrets(environment.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list existing secrets: %w", err)
+			}
+
+			values := make(map[string]string, len(secrets))
+			for _, secret := range secrets {
+				value, err := cryptoSvc.Decrypt(secret.EncryptedValue)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
+				}
+				values[secret.Key] = value
+			}
+			existing[v.Env] = values
+		}
+
+		ref := v.Env + "/" + v.Key
+		if i, ok := planned[ref]; ok {
+			if steps[i].Value != v.Value {
+				return nil, fmt.Errorf("Error: %s would get different values from %q and %q; change --key-template or --env-map", ref, steps[i].Origin, v.Origin)
+			}
+			continue
+		}
+
+		value, exists := existing[v.Env][v.Key]
+		change := importNew
+		switch {
+		case utils.ValidateEnvKey(v.Key) != nil:
+			change = importInvalid
+		case exists && value == v.Value:
+			change = importUnchanged
+		case exists:
+			change = importChanged
+		}
+
+		planned[ref] = len(steps)
+		steps = append(steps, importStep{importVar: v, environment: environment, change: change})
+	}
+
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].Env != steps[j].Env {
+			return steps[i].Env < steps[j].Env
+		}
+		return steps[i].Key < steps[j].Key
+	})
+
+	return steps, nil
+}
+
+// readImportFile reads variables from a file, or stdin for -, in one of the
+// envfile import formats. It returns the format that was used.
+func readImportFile(path, format string, opts envfile.ParseOptions) (map[string]string, string, error) {
+	data, err := readTemplateInput(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	envMap, format, err := envfile.Parse(format, path, data, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	return envMap, format, nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/dj-pearson/envault/internal/envfile"
+	"github.com/dj-pearson/envault/internal/models"
+	"github.com/fatih/color"
+)
+
+// readPasswordManagerExport turns the entries of a password manager export
+// into variables, named by --key-template and placed by --env-by. Entries
+// without a value or an environment are reported and skipped.
+func readPasswordManagerExport(path, format string, environments []*models.Environment, yellow *color.Color) ([]importVar, error) {
+	data, err := readTemplateInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	entries, err := envfile.ParseEntries(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	keyTemplate, err := template.New("key-template").Option("missingkey=error").Parse(importKeyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Error: invalid --key-template: %v", err)
+	}
+
+	var vars []importVar
+	for _, entry := range entries {
+		label := entry.Title
+		if label == "" {
+			label = "(untitled)"
+		}
+
+		var name bytes.Buffer
+		if err := keyTemplate.Execute(&name, entry); err != nil {
+			return nil, fmt.Errorf("Error: --key-template: %v", err)
+		}
+		key := envfile.KeyName(name.String())
+		if key == "" {
+			yellow.Printf("⚠ Skipping %q: --key-template gives an empty key\n", label)
+			continue
+		}
+
+		envNames := entryEnvironments(entry, environments)
+		if len(envNames) == 0 {
+			yellow.Printf("⚠ Skipping %q: no environment for its %s (see --env-map)\n", label, importEnvBy)
+			continue
+		}
+
+		values := entryValues(key, entry)
+		if len(values) == 0 {
+			yellow.Printf("⚠ Skipping %q: it has no password or notes\n", label)
+			continue
+		}
+
+		for _, envName := range envNames {
+			for key, value := range values {
+				vars = append(vars, importVar{Env: envName, Key: key, Value: value, Origin: label})
+			}
+		}
+	}
+
+	return vars, nil
+}
+
+// entryValues returns the variables an entry gives: the secret under key
+// and, with --include-fields, its other fields under key_FIELD
+func entryValues(key string, entry envfile.Entry) map[string]string {
+	values := make(map[string]string)
+	if value := entry.Value(); value != "" {
+		values[key] = value
+	}
+
+	if !importIncludeFields {
+		return values
+	}
+
+	if entry.Username != "" {
+		values[key+"_USERNAME"] = entry.Username
+	}
+	if entry.URL != "" {
+		values[key+"_URL"] = entry.URL
+	}
+	for _, field := range entry.FieldNames() {
+		if suffix := envfile.KeyName(field); suffix != "" && entry.Fields[field] != "" {
+			values[key+"_"+suffix] = entry.Fields[field]
+		}
+	}
+
+	return values
+}
+
+// entryEnvironments returns the environments an entry goes to: --env, or
+// with --env-by the environment of its folder or of each of its tags
+func entryEnvironments(entry envfile.Entry, environments []*models.Environment) []string {
+	if importEnvBy == "" {
+		return []string{importEnv}
+	}
+
+	var names []string
+	switch importEnvBy {
+	case "folder":
+		if entry.Folder != "" {
+			names = append(names, entry.Folder)
+			if i := strings.LastIndex(entry.Folder, "/"); i >= 0 {
+				names = append(names, entry.Folder[i+1:])
+			}
+		}
+	case "tag":
+		names = entry.Tags
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		envName := importEnvironmentFor(name, environments)
+		if envName == "" || seen[envName] {
+			continue
+		}
+		seen[envName] = true
+		result = append(result, envName)
+
+		// An entry has one folder, tried as a whole path and then by its last part
+		if importEnvBy == "folder" {
+			break
+		}
+	}
+
+	if len(result) == 0 {
+		if fallback, ok := importEnvMap["*"]; ok {
+			return []string{fallback}
+		}
+	}
+
+	return result
+}
+
+// importEnvironmentFor returns the environment a folder or tag maps to
+// through --env-map, or the environment of the same name ignoring case
+func importEnvironmentFor(name string, environments []*models.Environment) string {
+	if envName, ok := importEnvMap[name]; ok {
+		return envName
+	}
+	for from, envName := range importEnvMap {
+		if from != "*" && strings.EqualFold(from, name) {
+			return envName
+		}
+	}
+
+	for _, environment := range environments {
+		if strings.EqualFold(environment.Name, name) {
+			return environment.Name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/envfile"
+	"github.com/dj-pearson/envault/internal/models"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/fatih/color"
+	"github.com/zalando/go-keyring"
+)
+
+// testEnvironments are the environments of the project entries import into
+var testEnvironments = []*models.Environment{
+	{Name: "development"},
+	{Name: "staging"},
+	{Name: "production"},
+}
+
+// setImportFlags sets the password manager flags of envault import for one
+// test
+func setImportFlags(t *testing.T, keyTemplate, envBy string, envMap map[string]string, includeFields bool) {
+	t.Helper()
+
+	saved := []interface{}{importKeyTemplate, importEnvBy, importEnv, importEnvMap, importIncludeFields}
+	t.Cleanup(func() {
+		importKeyTemplate = saved[0].(string)
+		importEnvBy = saved[1].(string)
+		importEnv = saved[2].(string)
+		importEnvMap = saved[3].(map[string]string)
+		importIncludeFields = saved[4].(bool)
+	})
+
+	if keyTemplate == "" {
+		keyTemplate = "{{.Title}}"
+	}
+	importKeyTemplate = keyTemplate
+	importEnvBy = envBy
+	importEnv = "development"
+	importEnvMap = envMap
+	importIncludeFields = includeFields
+}
+
+// writeExport writes a password manager export to a temporary file
+func writeExport(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// importRefs returns ENV/KEY=value for each variable, sorted
+func importRefs(vars []importVar) []string {
+	refs := make([]string, 0, len(vars))
+	for _, v := range vars {
+		refs = append(refs, v.Env+"/"+v.Key+"="+v.Value)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+const testKeePassExport = `<KeePassFile>
+	<Root>
+		<Group>
+			<Name>Database</Name>
+			<Group>
+				<Name>Production</Name>
+				<Entry>
+					<Tags>payments</Tags>
+					<String><Key>Title</Key><Value>Stripe API key</Value></String>
+					<String><Key>UserName</Key><Value>ops@example.com</Value></String>
+					<String><Key>Password</Key><Value>sk_live_123</Value></String>
+					<String><Key>URL</Key><Value>https://stripe.com</Value></String>
+					<String><Key>Webhook secret</Key><Value>whsec_456</Value></String>
+				</Entry>
+			</Group>
+			<Group>
+				<Name>Clients</Name>
+				<Group>
+					<Name>Staging</Name>
+					<Entry>
+						<String><Key>Title</Key><Value>2FA recovery</Value></String>
+						<String><Key>Notes</Key><Value>one two three</Value></String>
+					</Entry>
+					<Entry>
+						<String><Key>Title</Key><Value>No secret</Value></String>
+						<String><Key>UserName</Key><Value>someone</Value></String>
+					</Entry>
+				</Group>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>
+`
+
+func TestImportKeyTemplate(t *testing.T) {
+	path := writeExport(t, "export.xml", testKeePassExport)
+
+	tests := []struct {
+		name          string
+		template      string
+		includeFields bool
+		want          []string
+	}{
+		{
+			name:     "title",
+			template: "{{.Title}}",
+			want: []string{
+				"development/STRIPE_API_KEY=sk_live_123",
+				"development/_2FA_RECOVERY=one two three",
+			},
+		},
+		{
+			name:     "folder and title",
+			template: "{{.Folder}} {{.Title}}",
+			want: []string{
+				"development/CLIENTS_STAGING_2FA_RECOVERY=one two three",
+				"development/PRODUCTION_STRIPE_API_KEY=sk_live_123",
+			},
+		},
+		{
+			name:     "prefix and suffix",
+			template: "app-{{.Title | printf \"%s\"}}-secret",
+			want: []string{
+				"development/APP_2FA_RECOVERY_SECRET=one two three",
+				"development/APP_STRIPE_API_KEY_SECRET=sk_live_123",
+			},
+		},
+		{
+			name:          "include fields",
+			template:      "{{.Title}}",
+			includeFields: true,
+			// Entries without a secret still give their other fields
+			want: []string{
+				"development/NO_SECRET_USERNAME=someone",
+				"development/STRIPE_API_KEY=sk_live_123",
+				"development/STRIPE_API_KEY_URL=https://stripe.com",
+				"development/STRIPE_API_KEY_USERNAME=ops@example.com",
+				"development/STRIPE_API_KEY_WEBHOOK_SECRET=whsec_456",
+				"development/_2FA_RECOVERY=one two three",
+			},
+		},
+		{
+			name:     "empty key skips the entry",
+			template: "{{if .URL}}{{.Title}}{{end}}",
+			want:     []string{"development/STRIPE_API_KEY=sk_live_123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImportFlags(t, tt.template, "", nil, tt.includeFields)
+
+			vars, err := readPasswordManagerExport(path, "keepass-xml", testEnvironments, color.New(color.FgYellow))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := importRefs(vars); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportKeyTemplateErrors(t *testing.T) {
+	path := writeExport(t, "export.xml", testKeePassExport)
+
+	for template, want := range map[string]string{
+		"{{.Title":    "invalid --key-template",
+		"{{.Secret}}": "--key-template",
+	} {
+		setImportFlags(t, template, "", nil, false)
+		_, err := readPasswordManagerExport(path, "keepass-xml", testEnvironments, color.New(color.FgYellow))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error containing %q", template, err, want)
+		}
+	}
+}
+
+func TestImportEnvironmentByFolder(t *testing.T) {
+	tests := []struct {
+		name   string
+		folder string
+		envMap map[string]string
+		want   []string
+	}{
+		{"folder named like an environment", "Production", nil, []string{"production"}},
+		{"last part of the folder path", "Clients/Acme/Staging", nil, []string{"staging"}},
+		{"mapped folder", "Prod", map[string]string{"Prod": "production"}, []string{"production"}},
+		{"mapped folder ignoring case", "prod", map[string]string{"Prod": "production"}, []string{"production"}},
+		{"whole path before last part", "Clients/Staging", map[string]string{"Clients/Staging": "production"}, []string{"production"}},
+		{"mapped last part", "Clients/Live", map[string]string{"Live": "production"}, []string{"production"}},
+		{"unknown folder", "Personal", nil, nil},
+		{"unknown folder with fallback", "Personal", map[string]string{"*": "development"}, []string{"development"}},
+		{"no folder with fallback", "", map[string]string{"*": "development"}, []string{"development"}},
+		{"no folder", "", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImportFlags(t, "", "folder", tt.envMap, false)
+
+			got := entryEnvironments(envfile.Entry{Folder: tt.folder}, testEnvironments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportEnvironmentByTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		envMap map[string]string
+		want   []string
+	}{
+		{"tags named like environments", []string{"payments", "Production", "staging"}, nil, []string{"production", "staging"}},
+		{"mapped tags", []string{"prod", "stage"}, map[string]string{"prod": "production", "stage": "staging"}, []string{"production", "staging"}},
+		{"tags mapping to the same environment", []string{"prod", "production"}, map[string]string{"prod": "production"}, []string{"production"}},
+		{"map wins over environment names", []string{"staging"}, map[string]string{"staging": "production"}, []string{"production"}},
+		{"no matching tag", []string{"payments"}, nil, nil},
+		{"no matching tag with fallback", []string{"payments"}, map[string]string{"*": "development"}, []string{"development"}},
+		{"no tags with fallback", nil, map[string]string{"*": "development"}, []string{"development"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImportFlags(t, "", "tag", tt.envMap, false)
+
+			got := entryEnvironments(envfile.Entry{Tags: tt.tags}, testEnvironments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportEnvironmentDefault(t *testing.T) {
+	setImportFlags(t, "", "", nil, false)
+
+	got := entryEnvironments(envfile.Entry{Folder: "Production", Tags: []string{"staging"}}, testEnvironments)
+	if want := []string{"development"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want --env (%v)", got, want)
+	}
+}
+
+// openTestDB creates a project with the test environments in a
+// temporary database
+func openTestDB(t *testing.T) (*storage.DB, *crypto.Service, string) {
+	t.Helper()
+	keyring.MockInit()
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "envault.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	project, err := db.CreateProject("app", "", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, environment := range testEnvironments {
+		if _, err := db.CreateEnvironment(project.ID, environment.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cryptoSvc, err := crypto.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cryptoSvc.Close)
+
+	return db, cryptoSvc, project.ID
+}
+
+const testBitwardenConflict = `{
+  "encrypted": false,
+  "folders": [
+    { "id": "1", "name": "Production" },
+    { "id": "2", "name": "Prod" },
+    { "id": "3", "name": "Live" }
+  ],
+  "items": [
+    { "name": "API key", "folderId": "1", "login": { "password": "key-one" } },
+    { "name": "API key", "folderId": "2", "login": { "password": "key-two" } },
+    { "name": "api-key", "folderId": "3", "login": { "password": "key-one" } }
+  ]
+}`
+
+func TestImportConflictingEntries(t *testing.T) {
+	db, cryptoSvc, projectID := openTestDB(t)
+	path := writeExport(t, "export.json", testBitwardenConflict)
+
+	tests := []struct {
+		name     string
+		template string
+		envMap   map[string]string
+		want     []string
+		err      string
+	}{
+		{
+			name:   "same key and environment, different values",
+			envMap: map[string]string{"Prod": "production", "Live": "production"},
+			err:    `production/API_KEY would get different values from "API key" and "API key"`,
+		},
+		{
+			name:   "same key and environment, same value",
+			envMap: map[string]string{"Live": "production"},
+			want:   []string{"production/API_KEY=key-one"},
+		},
+		{
+			name:   "same key in different environments",
+			envMap: map[string]string{"Prod": "staging", "Live": "development"},
+			want:   []string{"development/API_KEY=key-one", "production/API_KEY=key-one", "staging/API_KEY=key-two"},
+		},
+		{
+			name:     "key template tells them apart",
+			template: "{{.Folder}}_{{.Title}}",
+			envMap:   map[string]string{"Prod": "production", "Live": "production"},
+			want:     []string{"production/LIVE_API_KEY=key-one", "production/PRODUCTION_API_KEY=key-one", "production/PROD_API_KEY=key-two"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setImportFlags(t, tt.template, "folder", tt.envMap, false)
+
+			vars, err := readPasswordManagerExport(path, "bitwarden-json", testEnvironments, color.New(color.FgYellow))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			steps, err := planImport(db, cryptoSvc, projectID, vars)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, step := range steps {
+				got = append(got, step.Env+"/"+step.Key+"="+step.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -120,6 +120,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create project: %w", err)
 	}
 
+	if initTeam {
+		if err := db.SetProjectSyncEnabled(project.ID, true); err != nil {
+			return fmt.Errorf("failed to enable sync: %w", err)
+		}
+	}
+
 	// Create audit log for project creation
 	metadata := fmt.Sprintf(`{"project_name":"%s","sync_enabled":%t}`, projectName, initTeam)
 	if err := db.CreateAuditLog(project.ID, "project_initialized", metadata); err != nil {
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	injectOutput string
+	injectStrict bool
+)
+
+// secretReferencePattern matches envault://ENVIRONMENT/KEY placeholders
+var secretReferencePattern = regexp.MustCompile(`envault://([A-Za-z0-9_.-]+)/([A-Za-z_][A-Za-z0-9_]*)`)
+
+var injectCmd = &cobra.Command{
+	Use:   "inject FILE",
+	Short: "Replace envault:// references in a file with secrets",
+	Long: `Replace envault://ENVIRONMENT/KEY references in any file with the value of
+KEY in that environment. Everything else in the file is left as it is.
+
+Missing keys are replaced with empty strings and reported; with --strict
+they fail the command and nothing is written. Use - to read from stdin.
+The output goes to stdout, or to a 0600 file with --output.
+
+Examples:
+  envault inject config.tpl.json -o config.json
+  envault inject --strict docker-compose.tpl.yml > docker-compose.yml
+
+  # config.tpl.json
+  {"database": "envault://production/DATABASE_URL", "debug": false}`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInject,
+}
+
+func init() {
+	rootCmd.AddCommand(injectCmd)
+
+	injectCmd.Flags().StringVarP(&injectOutput, "output", "o", "", "Output file (default: stdout)")
+	injectCmd.Flags().BoolVar(&injectStrict, "strict", false, "Fail if a key is missing")
+}
+
+func runInject(cmd *cobra.Command, args []string) error {
+	input, err := readTemplateInput(args[0])
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	source, err := openSecretSource()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	var lookupErr error
+	output := secretReferencePattern.ReplaceAllFunc(input, func(ref []byte) []byte {
+		match := secretReferencePattern.FindSubmatch(ref)
+		value, _, err := source.lookup(string(match[1]), string(match[2]))
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+		return []byte(value)
+	})
+	if lookupErr != nil {
+		return lookupErr
+	}
+
+	if err := source.checkMissing(injectStrict); err != nil {
+		return err
+	}
+
+	return writeRendered(output, injectOutput)
+}
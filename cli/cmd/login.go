@@ -1,21 +1,38 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dj-pearson/envault/internal/api"
 	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/backend"
+	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultDashboardURL is the EnvVault web app that approves CLI logins
+	defaultDashboardURL = "https://envault.net"
+
+	// browserLoginTimeout is how long to wait for the browser to come back
+	browserLoginTimeout = 5 * time.Minute
 )
 
 var (
 	loginToken  string
 	loginManual bool
+	loginDevice bool
+	loginRemote string
 )
 
 var loginCmd = &cobra.Command{
@@ -25,8 +42,16 @@ var loginCmd = &cobra.Command{
 
 You can authenticate using:
   1. Interactive browser-based login (default)
-  2. Personal access token (--token flag)
-  3. Manual authentication (--manual flag)
+  2. Device code (--device, used automatically over SSH or without a display)
+  3. Personal access token (--token flag)
+  4. Manual token entry (--manual flag)
+
+Browser and device logins keep a refresh token, so the session renews
+itself instead of expiring.
+
+Each account is stored under a profile. Use --profile to log in to a second
+account; 'envault account switch' changes the one used by default and
+'envault account pin' ties a project to one.
 
 Once authenticated, you can use team sync features like:
   - envault sync (push/pull encrypted data)
@@ -34,8 +59,11 @@ Once authenticated, you can use team sync features like:
 
 Examples:
   envault login
+  envault login --device
   envault login --token envt_a1b2c3d4...
-  envault login --manual`,
+  envault login --manual
+  envault login --profile acme
+  envault login --remote office --token envt_...`,
 	RunE: runLogin,
 }
 
@@ -43,7 +71,83 @@ func init() {
 	rootCmd.AddCommand(loginCmd)
 
 	loginCmd.Flags().StringVar(&loginToken, "token", "", "Personal access token")
-	loginCmd.Flags().BoolVar(&loginManual, "manual", false, "Manual authentication (no browser)")
+	loginCmd.Flags().BoolVar(&loginManual, "manual", false, "Paste a personal access token")
+	loginCmd.Flags().BoolVar(&loginDevice, "device", false, "Log in with a code on another device (no local browser)")
+	loginCmd.Flags().StringVar(&loginRemote, "remote", "", "Remote to log in to (default: the project's default remote)")
+}
+
+// loginEndpoint returns the API URL and key to log in with. Inside a project
+// the remote's settings are used; elsewhere ENVAULT_API_URL and
+// ENVAULT_API_KEY are.
+func loginEndpoint(remoteName string) (string, string, error) {
+	project, err := utils.LoadProjectContext()
+	if err != nil {
+		if remoteName != "" {
+			return "", "", err
+		}
+		baseURL, apiKey := apiEndpoint("", nil)
+		return baseURL, apiKey, nil
+	}
+
+	name, rawURL, err := resolveRemote(project, remoteName)
+	if err != nil {
+		return "", "", err
+	}
+
+	if kind, _ := backend.Kind(rawURL); kind != backend.KindSupabase {
+		if remoteName != "" {
+			return "", "", fmt.Errorf("remote %q is a %s backend and does not need a login", name, kind)
+		}
+		// The default remote doesn't use the API; fall back to the environment
+		baseURL, apiKey := apiEndpoint("", nil)
+		return baseURL, apiKey, nil
+	}
+
+	creds, err := loadRemoteCredentials(project.ProjectID, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	baseURL, apiKey := apiEndpoint(rawURL, creds)
+	return baseURL, apiKey, nil
+}
+
+// dashboardURL returns the web app used to approve browser and device logins
+func dashboardURL() string {
+	if value := os.Getenv("ENVAULT_DASHBOARD_URL"); value != "" {
+		return strings.TrimSuffix(value, "/")
+	}
+	if value := viper.GetString("dashboard_url"); value != "" {
+		return strings.TrimSuffix(value, "/")
+	}
+	return defaultDashboardURL
+}
+
+// supportsInteractiveLogin reports whether browser and device logins are
+// available for an API. They need the EnvVault dashboard, which
+// envault-server does not have unless dashboard_url points at one.
+func supportsInteractiveLogin(baseURL string) bool {
+	if os.Getenv("ENVAULT_DASHBOARD_URL") != "" || viper.GetString("dashboard_url") != "" {
+		return true
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	return host == "envault.net" || strings.HasSuffix(host, ".envault.net") ||
+		strings.HasSuffix(host, ".supabase.co")
+}
+
+// loginClientName identifies this machine on the approval page
+func loginClientName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "EnvVault CLI"
+	}
+	return "EnvVault CLI on " + hostname
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
@@ -51,10 +155,15 @@ func runLogin(cmd *cobra.Command, args []string) error {
 	yellow := color.New(color.FgYellow)
 	cyan := color.New(color.FgCyan)
 
+	profile, _, err := currentProfile()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Check if already logged in
-	if auth.IsLoggedIn() {
-		session, _ := auth.LoadSession()
-		yellow.Printf("⚠ Already logged in as %s\n", session.Email)
+	if auth.IsLoggedIn(profile) {
+		session, _ := auth.LoadSession(profile)
+		yellow.Printf("⚠ Already logged in as %s%s\n", sessionDisplayName(session), profileSuffix(profile))
 
 		prompt := promptui.Prompt{
 			Label:     "Login with a different account",
@@ -68,75 +177,230 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		}
 
 		// Clear existing session
-		if err := auth.ClearSession(); err != nil {
+		if err := auth.ClearSession(profile); err != nil {
 			yellow.Printf("Warning: Could not clear session: %v\n", err)
 		}
 	}
 
-	// Get API configuration
-	// Get API key and base URL from environment or config
-	apiKey := os.Getenv("ENVAULT_API_KEY")
-	baseURL := os.Getenv("ENVAULT_API_URL")
+	// Get API configuration from the project's remote or the environment
+	baseURL, apiKey, err := loginEndpoint(loginRemote)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
 	if apiKey == "" {
-		return fmt.Errorf("Error: ENVAULT_API_KEY environment variable not set\n\nPlease set your Supabase API key:\n  export ENVAULT_API_KEY=your_anon_key_here")
+		return fmt.Errorf("Error: no API key configured\n\nAdd a remote with its API key:\n  envault remote add origin supabase --api-key your_anon_key_here\n\nor set it in the environment:\n  export ENVAULT_API_KEY=your_anon_key_here")
 	}
 
 	// Create API client
-	client := api.New(baseURL, apiKey)
+	client, err := newAPIEndpointClient(baseURL, apiKey)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
-	var accessToken string
+	var token *api.AuthToken
 
-	// Handle token-based login
-	if loginToken != "" {
-		if !quiet {
-			cyan.Println("Validating token...")
+	switch {
+	case loginToken != "":
+		token, err = tokenLogin(cmd.Context(), client, loginToken)
+
+	case loginManual:
+		token, err = manualLogin(cmd.Context(), client)
+
+	case !supportsInteractiveLogin(baseURL):
+		return fmt.Errorf("Error: browser login is not available for %s\nLog in with a token instead: envault login --token TOKEN", baseURL)
+
+	case loginDevice || !auth.CanOpenBrowser():
+		token, err = deviceLogin(cmd.Context(), client)
+
+	default:
+		token, err = browserLogin(cmd.Context(), client)
+		if errors.Is(err, errNoBrowser) {
+			yellow.Println("Could not open a browser; falling back to device login")
+			fmt.Println()
+			token, err = deviceLogin(cmd.Context(), client)
 		}
+	}
 
-		userID, err := client.ValidateToken(loginToken)
-		if err != nil {
-			return fmt.Errorf("token validation failed: %w", err)
+	if err != nil {
+		return err
+	}
+
+	session, err := completeLogin(cmd.Context(), client, token, profile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	green.Printf("✓ Logged in successfully as %s%s\n", sessionDisplayName(session), profileSuffix(profile))
+
+	// The first account becomes the active one; later ones have to be
+	// switched to explicitly
+	if profile != auth.ActiveProfile() {
+		if profiles, err := auth.ListProfiles(); err == nil && len(profiles) == 1 {
+			if err := auth.SetActiveProfile(profile); err != nil {
+				yellow.Printf("Warning: Could not select profile: %v\n", err)
+			}
+		} else if !quiet {
+			fmt.Printf("Run 'envault account switch %s' to use it by default\n", profile)
 		}
+	}
+
+	if !quiet {
+		fmt.Println()
+		cyan.Println("You can now use team features:")
+		fmt.Println("  envault sync     # Sync with team")
+		fmt.Println("  envault team     # Manage team members")
+	}
+
+	return nil
+}
+
+// errNoBrowser means the browser could not be launched
+var errNoBrowser = errors.New("no browser available")
+
+// browserLogin opens the dashboard in a browser and waits for it to redirect
+// back to a local callback with an authorization code (PKCE)
+func browserLogin(ctx context.Context, client *api.Client) (*api.AuthToken, error) {
+	cyan := color.New(color.FgCyan)
+
+	pkce, err := auth.NewPKCE()
+	if err != nil {
+		return nil, err
+	}
 
-		accessToken = loginToken
+	server, err := auth.NewCallbackServer()
+	if err != nil {
+		return nil, err
+	}
+	defer server.Close()
 
-		// Get user email (would need additional API call in real implementation)
-		email := "user@example.com" // Placeholder
+	authorizeURL := auth.AuthorizeURL(dashboardURL(), pkce, server, loginClientName())
 
-		// Save session
-		session := &auth.Session{
-			UserID:      userID,
-			Email:       email,
-			AccessToken: accessToken,
-			ExpiresAt:   time.Now().Add(90 * 24 * time.Hour), // 90 days
+	if err := auth.OpenBrowser(authorizeURL); err != nil {
+		if debug {
+			utils.Warn("%v", err)
 		}
+		return nil, errNoBrowser
+	}
 
-		if err := auth.SaveSession(session); err != nil {
-			return fmt.Errorf("failed to save session: %w", err)
+	cyan.Println("Opening browser for authentication...")
+	if !quiet {
+		fmt.Println("If the browser did not open, visit:")
+		fmt.Printf("  %s\n", authorizeURL)
+		fmt.Println()
+		fmt.Println("Waiting for you to approve the login...")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, browserLoginTimeout)
+	defer cancel()
+
+	code, err := server.Wait(waitCtx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out waiting for the browser login\nOn machines without a browser, use 'envault login --device'")
 		}
+		return nil, err
+	}
 
-		green.Printf("✓ Logged in successfully as %s\n", email)
-		return nil
+	token, err := client.ExchangeAuthCode(ctx, code, pkce.Verifier)
+	if err != nil {
+		return nil, apiError("login failed", err)
 	}
 
-	// Interactive login
-	if !loginManual {
-		yellow.Println("Opening browser for authentication...")
-		yellow.Println("(Browser-based login not yet implemented)")
-		fmt.Println()
-		yellow.Println("Please use token-based authentication for now:")
-		fmt.Println()
-		cyan.Println("1. Go to your EnvVault dashboard → Settings → CLI Access")
-		cyan.Println("2. Generate a new CLI token")
-		cyan.Println("3. Run: envault login --token YOUR_TOKEN")
-		fmt.Println()
-		return nil
+	return token, nil
+}
+
+// deviceLogin shows a code to enter on another device and polls until the
+// user approves it
+func deviceLogin(ctx context.Context, client *api.Client) (*api.AuthToken, error) {
+	cyan := color.New(color.FgCyan)
+	bold := color.New(color.Bold)
+
+	device, err := client.StartDeviceAuthorization(ctx, loginClientName())
+	if err != nil {
+		if api.IsNotFound(err) || api.IsFunctionNotFound(err) {
+			return nil, fmt.Errorf("device login is not supported by this server\nLog in with a token instead: envault login --token TOKEN")
+		}
+		return nil, apiError("failed to start device login", err)
+	}
+
+	verificationURI := dashboardURL() + "/cli/device"
+
+	cyan.Println("To log in, open this page on any device:")
+	fmt.Printf("  %s\n\n", verificationURI)
+	fmt.Print("and enter the code: ")
+	bold.Println(device.UserCode)
+	if !quiet {
+		fmt.Printf("\nOr open %s?code=%s\n", verificationURI, url.QueryEscape(device.UserCode))
+		fmt.Println("\nWaiting for approval...")
 	}
 
-	// Manual token input
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	expiresIn := time.Duration(device.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 15 * time.Minute
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, expiresIn)
+	defer cancel()
+
+	for {
+		select {
+		case <-pollCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("the login code expired; run 'envault login' again")
+		case <-time.After(interval):
+		}
+
+		token, err := client.PollDeviceAuthorization(pollCtx, device.DeviceCode)
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.Is(err, api.ErrAuthorizationPending):
+			continue
+		case errors.Is(err, api.ErrSlowDown):
+			interval += 5 * time.Second
+		case errors.Is(err, api.ErrAccessDenied):
+			return nil, fmt.Errorf("the login was denied")
+		case errors.Is(err, api.ErrDeviceCodeExpired):
+			return nil, fmt.Errorf("the login code expired; run 'envault login' again")
+		case api.IsNetworkError(err) || api.IsRateLimited(err):
+			// Transient; keep polling until the code expires
+			continue
+		default:
+			return nil, apiError("device login failed", err)
+		}
+	}
+}
+
+// tokenLogin validates a personal access token
+func tokenLogin(ctx context.Context, client *api.Client, token string) (*api.AuthToken, error) {
+	if !quiet {
+		color.New(color.FgCyan).Println("Validating token...")
+	}
+
+	userID, err := client.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, tokenValidationError(err)
+	}
+
+	return &api.AuthToken{UserID: userID, AccessToken: token}, nil
+}
+
+// manualLogin prompts for a personal access token
+func manualLogin(ctx context.Context, client *api.Client) (*api.AuthToken, error) {
+	cyan := color.New(color.FgCyan)
+
 	cyan.Println("Manual Authentication")
 	fmt.Println()
-	cyan.Println("1. Go to: https://your-envault-dashboard/settings/cli")
+	cyan.Printf("1. Go to: %s/dashboard/settings\n", dashboardURL())
 	cyan.Println("2. Generate a new personal access token")
 	cyan.Println("3. Copy the token and paste it below")
 	fmt.Println()
@@ -157,40 +421,121 @@ func runLogin(cmd *cobra.Command, args []string) error {
 
 	token, err := prompt.Run()
 	if err != nil {
-		return fmt.Errorf("prompt cancelled")
+		return nil, fmt.Errorf("prompt cancelled")
 	}
+	fmt.Println()
 
-	// Validate token
-	if !quiet {
-		cyan.Println("\nValidating token...")
-	}
+	return tokenLogin(ctx, client, token)
+}
 
-	userID, err := client.ValidateToken(token)
-	if err != nil {
-		return fmt.Errorf("token validation failed: %w", err)
-	}
+// completeLogin fetches the user's profile and saves the session under the
+// given login profile
+func completeLogin(ctx context.Context, client *api.Client, token *api.AuthToken, profile string) (*auth.Session, error) {
+	client.SetAuthToken(token.AccessToken)
 
-	// Save session
 	session := &auth.Session{
-		UserID:      userID,
-		Email:       "user@example.com", // Placeholder
-		AccessToken: token,
-		ExpiresAt:   time.Now().Add(90 * 24 * time.Hour), // 90 days
+		Profile:      profile,
+		UserID:       token.UserID,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+	}
+	if token.ExpiresAt != nil {
+		session.ExpiresAt = *token.ExpiresAt
+	}
+
+	user, err := client.GetCurrentUser(ctx)
+	switch {
+	case err == nil:
+		session.UserID = user.ID
+		session.Email = user.Email
+		session.Name = user.Name
+	case api.IsNotFound(err) || api.IsFunctionNotFound(err):
+		// Older backends have no profile endpoint; the session still works
+		if debug {
+			utils.Warn("Could not fetch user profile: %v", err)
+		}
+	default:
+		return nil, apiError("failed to fetch user profile", err)
 	}
 
 	if err := auth.SaveSession(session); err != nil {
-		return fmt.Errorf("failed to save session: %w", err)
+		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
-	fmt.Println()
-	green.Println("✓ Logged in successfully")
-	fmt.Println()
+	return session, nil
+}
 
-	if !quiet {
-		cyan.Println("You can now use team features:")
-		fmt.Println("  envault sync     # Sync with team")
-		fmt.Println("  envault team     # Manage team members")
+// sessionDisplayName describes the logged-in user
+func sessionDisplayName(session *auth.Session) string {
+	switch {
+	case session == nil:
+		return "unknown user"
+	case session.Email != "":
+		return session.Email
+	default:
+		return "user " + session.UserID
 	}
+}
 
-	return nil
+// sessionTokenSource returns the session's access token, renewing it with the
+// refresh token shortly before it expires. Renewed sessions are saved so that
+// other envault processes pick them up.
+func sessionTokenSource(session *auth.Session, baseURL, apiKey string) api.TokenSource {
+	var mu sync.Mutex
+
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !session.NeedsRefresh() {
+			return session.AccessToken, nil
+		}
+
+		// Refresh tokens are single use: another process may already have
+		// renewed the session
+		if latest, err := auth.LoadSession(session.Profile); err == nil && latest.RefreshToken != session.RefreshToken {
+			*session = *latest
+			if !session.NeedsRefresh() {
+				return session.AccessToken, nil
+			}
+		}
+
+		client, err := newAPIEndpointClient(baseURL, apiKey)
+		if err != nil {
+			return "", err
+		}
+
+		token, err := client.RefreshSession(ctx, session.RefreshToken)
+		if err != nil {
+			if !session.Expired() && !api.IsUnauthorized(err) {
+				// Still valid for a moment; try again on the next request
+				return session.AccessToken, nil
+			}
+			if api.IsUnauthorized(err) {
+				return "", fmt.Errorf("session expired (run '%s' again): %w", auth.LoginCommand(session.Profile), err)
+			}
+			return "", fmt.Errorf("failed to refresh session: %w", err)
+		}
+
+		session.AccessToken = token.AccessToken
+		session.RefreshToken = token.RefreshToken
+		session.ExpiresAt = time.Time{}
+		if token.ExpiresAt != nil {
+			session.ExpiresAt = *token.ExpiresAt
+		}
+
+		if err := auth.SaveSession(session); err != nil {
+			utils.Warn("Failed to save refreshed session: %v", err)
+		}
+
+		return session.AccessToken, nil
+	}
+}
+
+// tokenValidationError explains why a token was rejected during login
+func tokenValidationError(err error) error {
+	if api.IsUnauthorized(err) {
+		return fmt.Errorf("token validation failed: the token is invalid, expired or revoked\nGenerate a new token in your EnvVault dashboard → Settings → CLI Access")
+	}
+	return apiError("token validation failed", err)
 }
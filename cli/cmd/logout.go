@@ -16,8 +16,12 @@ var logoutCmd = &cobra.Command{
 This will remove your authentication session from this machine.
 You will need to login again to use team sync features.
 
+Only the account in use is logged out; pass --profile to log out of
+another one.
+
 Examples:
-  envault logout`,
+  envault logout
+  envault logout --profile acme`,
 	RunE: runLogout,
 }
 
@@ -29,24 +33,36 @@ func runLogout(cmd *cobra.Command, args []string) error {
 	green := color.New(color.FgGreen)
 	yellow := color.New(color.FgYellow)
 
+	profile, _, err := currentProfile()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Check if logged in
-	if !auth.IsLoggedIn() {
-		yellow.Println("Not currently logged in")
+	if !auth.IsLoggedIn(profile) {
+		yellow.Printf("Not currently logged in%s\n", profileSuffix(profile))
 		return nil
 	}
 
 	// Get current session for display
-	session, _ := auth.LoadSession()
+	session, _ := auth.LoadSession(profile)
 
 	// Clear session
-	if err := auth.ClearSession(); err != nil {
+	if err := auth.ClearSession(profile); err != nil {
 		return fmt.Errorf("failed to logout: %w", err)
 	}
 
+	// Fall back to the default profile rather than a logged-out one
+	if profile == auth.ActiveProfile() && profile != auth.DefaultProfile {
+		if err := auth.SetActiveProfile(auth.DefaultProfile); err != nil {
+			yellow.Printf("Warning: Could not reset active profile: %v\n", err)
+		}
+	}
+
 	if !quiet {
 		green.Printf("✓ Logged out successfully")
 		if session != nil {
-			fmt.Printf(" (was logged in as %s)", session.Email)
+			fmt.Printf(" (was logged in as %s%s)", sessionDisplayName(session), profileSuffix(profile))
 		}
 		fmt.Println()
 	}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dj-pearson/envault/internal/network"
+	"github.com/spf13/viper"
+)
+
+// networkOptions reads proxy, TLS and pinning settings. Environment variables
+// take precedence over the network.* keys in ~/.envault/config.yml.
+func networkOptions() network.Options {
+	setting := func(envVar, key string) string {
+		if value := os.Getenv(envVar); value != "" {
+			return value
+		}
+		return viper.GetString(key)
+	}
+
+	opts := network.Options{
+		Proxy:      setting("ENVAULT_PROXY", "network.proxy"),
+		NoProxy:    setting("ENVAULT_NO_PROXY", "network.no_proxy"),
+		CAFile:     expandHome(setting("ENVAULT_CA_BUNDLE", "network.ca_bundle")),
+		ClientCert: expandHome(setting("ENVAULT_CLIENT_CERT", "network.client_cert")),
+		ClientKey:  expandHome(setting("ENVAULT_CLIENT_KEY", "network.client_key")),
+	}
+
+	// An explicit proxy still honours the standard NO_PROXY variable
+	if opts.Proxy != "" && opts.NoProxy == "" {
+		opts.NoProxy = firstEnv("NO_PROXY", "no_proxy")
+	}
+
+	pins := os.Getenv("ENVAULT_PINNED_CERTS")
+	if pins == "" {
+		pins = strings.Join(viper.GetStringSlice("network.pins"), ",")
+	}
+	for _, pin := range strings.FieldsFunc(pins, func(r rune) bool { return r == ',' || r == ' ' }) {
+		opts.Pins = append(opts.Pins, pin)
+	}
+
+	return opts
+}
+
+// newHTTPClient returns a client that honours the network settings.
+// Certificate pins only apply to the EnvVault API, so callers talking to
+// third parties such as GitHub or S3 pass pinned=false.
+func newHTTPClient(pinned bool) (*http.Client, error) {
+	opts := networkOptions()
+	if !pinned {
+		opts.Pins = nil
+	}
+
+	client, err := network.NewClient(opts, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network settings: %w", err)
+	}
+
+	return client, nil
+}
+
+// firstEnv returns the first non-empty environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// expandHome expands a leading ~/ in a configured path
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return home + path[1:]
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/dj-pearson/envault/internal/api"
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
@@ -16,6 +17,9 @@ import (
 
 var (
 	projectsFormat string
+	projectsRemote bool
+	projectsURL    string
+	projectsAPIKey string
 )
 
 var projectsCmd = &cobra.Command{
@@ -26,9 +30,15 @@ var projectsCmd = &cobra.Command{
 This shows all projects that have been initialized on this machine,
 including their sync status and basic metadata.
 
+With --remote, the projects you are a member of on EnvVault cloud or an
+envault-server are listed instead, so you can 'envault clone' them. The
+current project's default remote is used, or --url.
+
 Examples:
   envault projects
-  envault projects --format json`,
+  envault projects --format json
+  envault projects --remote
+  envault projects --remote --url https://envault.internal.example.com`,
 	RunE: runProjects,
 }
 
@@ -36,12 +46,19 @@ func init() {
 	rootCmd.AddCommand(projectsCmd)
 
 	projectsCmd.Flags().StringVarP(&projectsFormat, "format", "f", "table", "Output format: table, json")
+	projectsCmd.Flags().BoolVar(&projectsRemote, "remote", false, "List projects on the server instead of local ones")
+	projectsCmd.Flags().StringVar(&projectsURL, "url", "", "EnvVault cloud or envault-server URL for --remote")
+	projectsCmd.Flags().StringVar(&projectsAPIKey, "api-key", "", "API key for the server")
 }
 
 func runProjects(cmd *cobra.Command, args []string) error {
 	cyan := color.New(color.FgCyan)
 	yellow := color.New(color.FgYellow)
 
+	if projectsRemote {
+		return runRemoteProjects(cmd)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -139,3 +156,85 @@ func runProjects(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runRemoteProjects(cmd *cobra.Command) error {
+	cyan := color.New(color.FgCyan)
+	yellow := color.New(color.FgYellow)
+
+	rawURL, creds, err := remoteProjectsEndpoint(projectsURL, projectsAPIKey)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	client, err := newRemoteProjectsClient(rawURL, creds)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	projects, err := client.GetProjects(cmd.Context())
+	if err != nil {
+		return apiError("failed to list projects", err)
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	type remoteProjectInfo struct {
+		api.Project
+		Local bool `json:"local"`
+	}
+
+	infos := make([]remoteProjectInfo, len(projects))
+	for i, project := range projects {
+		_, err := db.GetProject(project.ID)
+		infos[i] = remoteProjectInfo{Project: project, Local: err == nil}
+	}
+
+	if projectsFormat == "json" || jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(infos)
+	}
+
+	if len(infos) == 0 {
+		yellow.Println("No projects found on the server.")
+		return nil
+	}
+
+	cyan.Println("Remote Projects")
+	fmt.Println(strings.Repeat("─", 60))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "ID", "Updated", "Local"})
+	table.SetBorder(false)
+
+	for _, info := range infos {
+		name := info.Name
+		if name == "" {
+			name = "-"
+		}
+
+		local := ""
+		if info.Local {
+			local = "✓"
+		}
+
+		table.Append([]string{
+			name,
+			info.ID,
+			info.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			local,
+		})
+	}
+
+	table.Render()
+
+	if !quiet {
+		fmt.Println("\nClone one with: envault clone NAME|ID [dir]")
+	}
+
+	return nil
+}
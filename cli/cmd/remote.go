@@ -0,0 +1,677 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/backend"
+	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	remoteAPIKey          string
+	remoteAccessKeyID     string
+	remoteSecretAccessKey string
+	remoteSessionToken    string
+	remoteDefault         bool
+)
+
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage sync remotes",
+	Long: `Manage the remotes a project syncs with.
+
+A remote is a named place where the project's encrypted snapshots are
+stored. Remotes are saved in the project's .envault file; their
+credentials are kept in the OS keychain.
+
+Supported remote URLs:
+  supabase                              EnvVault cloud
+  https://PROJECT.supabase.co           EnvVault cloud or a self-hosted envault-server
+  file:///mnt/share/envault             Shared drive, NFS mount or USB stick
+  s3://bucket/prefix?region=eu-west-1   S3-compatible bucket (endpoint=URL, path_style=true)
+  git+ssh://git@host/org/secrets.git    Git repository (#branch, default main)
+
+Subcommands:
+  add          Add a remote
+  list         List remotes
+  remove       Remove a remote
+  set-default  Choose the remote used when --remote is not given`,
+}
+
+var remoteAddCmd = &cobra.Command{
+	Use:   "add NAME URL",
+	Short: "Add a remote",
+	Long: `Add a remote to the current project.
+
+The first remote added becomes the default. API remotes (EnvVault cloud
+and envault-server) need an API key, which is prompted for if --api-key
+is not given. S3 remotes use --access-key-id/--secret-access-key, or the
+AWS_* environment variables when none are stored.
+
+Examples:
+  envault remote add origin supabase --api-key eyJhbGciOi...
+  envault remote add office https://envault.internal.example.com
+  envault remote add usb file:///Volumes/USB/envault
+  envault remote add backup s3://acme-secrets/envault?region=eu-west-1 --access-key-id AKIA... --secret-access-key ...
+  envault remote add git git+ssh://git@github.com/acme/secrets.git#main`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRemoteAdd,
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List remotes",
+	Args:  cobra.NoArgs,
+	RunE:  runRemoteList,
+}
+
+var remoteRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a remote and its stored credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRemoteRemove,
+}
+
+var remoteSetDefaultCmd = &cobra.Command{
+	Use:   "set-default NAME",
+	Short: "Set the default remote",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRemoteSetDefault,
+}
+
+func init() {
+	rootCmd.AddCommand(remoteCmd)
+	remoteCmd.AddCommand(remoteAddCmd)
+	remoteCmd.AddCommand(remoteListCmd)
+	remoteCmd.AddCommand(remoteRemoveCmd)
+	remoteCmd.AddCommand(remoteSetDefaultCmd)
+
+	remoteAddCmd.Flags().StringVar(&remoteAPIKey, "api-key", "", "API key for EnvVault cloud or envault-server")
+	remoteAddCmd.Flags().StringVar(&remoteAccessKeyID, "access-key-id", "", "S3 access key ID")
+	remoteAddCmd.Flags().StringVar(&remoteSecretAccessKey, "secret-access-key", "", "S3 secret access key")
+	remoteAddCmd.Flags().StringVar(&remoteSessionToken, "session-token", "", "S3 session token")
+	remoteAddCmd.Flags().BoolVar(&remoteDefault, "default", false, "Make this the default remote")
+}
+
+func runRemoteAdd(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	name, rawURL := args[0], args[1]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if !remoteNamePattern.MatchString(name) {
+		return fmt.Errorf("Error: invalid remote name %q (use letters, digits, '-' and '_')", name)
+	}
+
+	if _, exists := ctx.Remotes[name]; exists {
+		return fmt.Errorf("Error: remote %q already exists (remove it first to change its URL)", name)
+	}
+
+	if err := migrateLegacyRemote(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	kind, err := backend.Kind(rawURL)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	creds := &auth.RemoteCredentials{
+		AccessKeyID:     remoteAccessKeyID,
+		SecretAccessKey: remoteSecretAccessKey,
+		SessionToken:    remoteSessionToken,
+	}
+
+	switch kind {
+	case backend.KindSupabase:
+		creds = &auth.RemoteCredentials{APIKey: remoteAPIKey}
+		if creds.APIKey == "" {
+			prompt := promptui.Prompt{
+				Label: "API key for " + rawURL,
+				Mask:  '*',
+			}
+			creds.APIKey, err = prompt.Run()
+			if err != nil {
+				return fmt.Errorf("prompt cancelled")
+			}
+		}
+
+	case backend.KindS3:
+		if (creds.AccessKeyID == "") != (creds.SecretAccessKey == "") {
+			return fmt.Errorf("Error: --access-key-id and --secret-access-key must be used together")
+		}
+
+	default:
+		creds = nil
+	}
+
+	// Validate the URL and credentials before saving anything
+	if kind != backend.KindSupabase {
+		if _, err := openBackend(rawURL, creds); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+	}
+
+	if !creds.IsEmpty() {
+		if err := auth.SaveRemoteCredentials(ctx.ProjectID, name, creds); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+	}
+
+	if err := utils.SetProjectValue(utils.RemoteKeyPrefix+name, rawURL); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	makeDefault := remoteDefault || ctx.DefaultRemote == ""
+	if makeDefault {
+		if err := utils.SetProjectValue("default_remote", name); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+	}
+
+	logRemoteChange(ctx.ProjectID, "remote_added", name, kind)
+
+	green.Printf("✓ Added remote %s (%s)\n", name, rawURL)
+	if makeDefault && !quiet {
+		fmt.Printf("  %s is the default remote; run 'envault sync' to push your secrets to it\n", name)
+	}
+
+	return nil
+}
+
+func runRemoteList(cmd *cobra.Command, args []string) error {
+	cyan := color.New(color.FgCyan)
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	type remoteInfo struct {
+		Name        string `json:"name"`
+		URL         string `json:"url"`
+		Kind        string `json:"kind"`
+		Default     bool   `json:"default"`
+		Credentials string `json:"credentials"`
+	}
+
+	var remotes []remoteInfo
+	for _, name := range ctx.RemoteNames() {
+		rawURL := ctx.Remotes[name]
+		kind, _ := backend.Kind(rawURL)
+
+		credentials := "none"
+		if creds, err := auth.LoadRemoteCredentials(ctx.ProjectID, name); err == nil && !creds.IsEmpty() {
+			credentials = "keychain"
+		} else if kind == backend.KindSupabase || kind == backend.KindS3 {
+			credentials = "environment"
+		}
+
+		remotes = append(remotes, remoteInfo{
+			Name:        name,
+			URL:         rawURL,
+			Kind:        kind,
+			Default:     name == ctx.DefaultRemote,
+			Credentials: credentials,
+		})
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(remotes)
+	}
+
+	if len(remotes) == 0 {
+		fmt.Println("No remotes configured")
+		if !quiet {
+			fmt.Println("\nSync uses EnvVault cloud with ENVAULT_API_URL/ENVAULT_API_KEY.")
+			fmt.Println("Add a remote: envault remote add origin URL")
+		}
+		return nil
+	}
+
+	cyan.Printf("Remotes for %s:\n\n", ctx.ProjectName)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "Name", "URL", "Kind", "Credentials"})
+	table.SetBorder(false)
+	table.SetColumnSeparator("")
+	table.SetHeaderLine(false)
+
+	for _, remote := range remotes {
+		marker := ""
+		if remote.Default {
+			marker = "*"
+		}
+		table.Append([]string{marker, remote.Name, remote.URL, remote.Kind, remote.Credentials})
+	}
+	table.Render()
+
+	return nil
+}
+
+func runRemoteRemove(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	name := args[0]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	rawURL, ok := ctx.Remotes[name]
+	if !ok {
+		return fmt.Errorf("Error: remote %q not found (see 'envault remote list')", name)
+	}
+
+	if err := migrateLegacyRemote(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := utils.SetProjectValue(utils.RemoteKeyPrefix+name, ""); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if ctx.DefaultRemote == name {
+		if err := utils.SetProjectValue("default_remote", ""); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+	}
+
+	kind, _ := backend.Kind(rawURL)
+	if kind == backend.KindSupabase || kind == backend.KindS3 {
+		if err := auth.DeleteRemoteCredentials(ctx.ProjectID, name); err != nil {
+			yellow.Printf("Warning: Failed to delete stored credentials: %v\n", err)
+		}
+	}
+
+	if db, err := openDB(); err == nil {
+		if err := db.ResetSyncMetadata(ctx.ProjectID, name); err != nil {
+			yellow.Printf("Warning: Failed to reset sync state: %v\n", err)
+		}
+		db.Close()
+	}
+
+	logRemoteChange(ctx.ProjectID, "remote_removed", name, kind)
+
+	green.Printf("✓ Removed remote %s\n", name)
+
+	// A single remaining remote is used as the default automatically
+	if ctx.DefaultRemote == name && len(ctx.Remotes) > 2 && !quiet {
+		yellow.Println("  No default remote is set; choose one with 'envault remote set-default NAME'")
+	}
+
+	return nil
+}
+
+func runRemoteSetDefault(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	name := args[0]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if _, ok := ctx.Remotes[name]; !ok {
+		return fmt.Errorf("Error: remote %q not found (see 'envault remote list')", name)
+	}
+
+	if err := migrateLegacyRemote(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := utils.SetProjectValue("default_remote", name); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	green.Printf("✓ Default remote set to %s\n", name)
+	return nil
+}
+
+// migrateLegacyRemote rewrites a sync_backend entry from older versions as
+// the origin remote, so that editing remotes does not lose it
+func migrateLegacyRemote(ctx *utils.ProjectContext) error {
+	for _, name := range ctx.RemoteNames() {
+		if err := utils.SetProjectValue(utils.RemoteKeyPrefix+name, ctx.Remotes[name]); err != nil {
+			return err
+		}
+	}
+
+	if ctx.DefaultRemote != "" {
+		if err := utils.SetProjectValue("default_remote", ctx.DefaultRemote); err != nil {
+			return err
+		}
+	}
+
+	return utils.SetProjectValue("sync_backend", "")
+}
+
+// syncRemote is a resolved sync target
+type syncRemote struct {
+	backend.SyncBackend
+
+	// Remote is the remote's name, or empty for projects that have no named
+	// remotes and sync through the environment-configured API
+	Remote string
+
+	// Default is true for the project's default remote. Only pushes to the
+	// default remote clear the offline outbox.
+	Default bool
+
+	// client is the API client of EnvVault remotes, used to look up the
+	// scope of a machine token
+	client *api.Client
+	scope  *api.MachineToken
+}
+
+// errReadOnlyToken is returned when pushing with a machine token
+var errReadOnlyToken = errors.New("machine tokens are read-only; unset ENVAULT_TOKEN and log in to push changes")
+
+// ReadOnly reports whether the remote is accessed with a machine token
+func (r *syncRemote) ReadOnly() bool {
+	return r.client != nil && api.IsMachineToken(envToken())
+}
+
+// machineScope returns the machine token the remote is accessed with, or
+// nil when it is accessed with a user session
+func (r *syncRemote) machineScope(ctx context.Context) (*api.MachineToken, error) {
+	if !r.ReadOnly() {
+		return nil, nil
+	}
+
+	if r.scope == nil {
+		scope, err := r.client.GetMachineTokenScope(ctx)
+		if err != nil {
+			return nil, apiError("failed to read machine token scope", err)
+		}
+		r.scope = scope
+	}
+
+	return r.scope, nil
+}
+
+// resolveRemote picks the remote to use: the named one, else the project's
+// default. It returns an empty name and URL for projects without remotes.
+func resolveRemote(project *utils.ProjectContext, name string) (string, string, error) {
+	if name == "" {
+		name = project.DefaultRemote
+	}
+
+	if name == "" {
+		if len(project.Remotes) > 0 {
+			return "", "", fmt.Errorf("no default remote set\nRun 'envault remote set-default NAME' or pass --remote NAME")
+		}
+		return "", "", nil
+	}
+
+	rawURL, ok := project.Remotes[name]
+	if !ok {
+		return "", "", fmt.Errorf("remote %q not found (see 'envault remote list')", name)
+	}
+
+	return name, rawURL, nil
+}
+
+// openSyncRemote opens the named remote, or the project's default remote if
+// name is empty
+func openSyncRemote(project *utils.ProjectContext, name string) (*syncRemote, error) {
+	remoteName, rawURL, err := resolveRemote(project, name)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &syncRemote{
+		Remote:  remoteName,
+		Default: remoteName == project.DefaultRemote,
+	}
+
+	kind, err := backend.Kind(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == backend.KindSupabase {
+		client, err := newRemoteAPIClient(project, remoteName, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		remote.SyncBackend = backend.NewSupabase(client)
+		remote.client = client
+		return remote, nil
+	}
+
+	creds, err := loadRemoteCredentials(project.ProjectID, remoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	remote.SyncBackend, err = openBackend(rawURL, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return remote, nil
+}
+
+// newAPIClient returns an authenticated client for the project's default
+// remote, which must be EnvVault cloud or an envault-server
+func newAPIClient(project *utils.ProjectContext) (*api.Client, error) {
+	remoteName, rawURL, err := resolveRemote(project, "")
+	if err != nil {
+		return nil, err
+	}
+
+	kind, err := backend.Kind(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != backend.KindSupabase {
+		return nil, fmt.Errorf("team features need an EnvVault remote, but the default remote %q is a %s backend\nAdd one with 'envault remote add NAME supabase' and make it the default", remoteName, kind)
+	}
+
+	return newRemoteAPIClient(project, remoteName, rawURL)
+}
+
+// newRemoteAPIClient creates an authenticated API client for an API remote.
+// ENVAULT_TOKEN, when set, is used instead of the login session.
+func newRemoteAPIClient(project *utils.ProjectContext, remoteName, rawURL string) (*api.Client, error) {
+	// Check authentication with the account pinned for the project, if any
+	if err := requireLogin(project); err != nil {
+		return nil, err
+	}
+
+	creds, err := loadRemoteCredentials(project.ProjectID, remoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL, apiKey := apiEndpoint(rawURL, creds)
+	if apiKey == "" {
+		if remoteName == "" {
+			return nil, fmt.Errorf("no API key configured\nAdd a remote with 'envault remote add origin supabase --api-key KEY' or set ENVAULT_API_KEY")
+		}
+		return nil, fmt.Errorf("no API key stored for remote %q\nRe-add it with 'envault remote add %s URL --api-key KEY' or set ENVAULT_API_KEY", remoteName, remoteName)
+	}
+
+	client, err := newAPIEndpointClient(baseURL, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Non-interactive callers such as CI never touch the session store
+	if token := envToken(); token != "" {
+		client.SetAuthToken(token)
+		return client, nil
+	}
+
+	account, _ := resolveProfile(project)
+	session, err := auth.LoadSession(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user session: %w", err)
+	}
+	client.SetTokenSource(sessionTokenSource(session, baseURL, apiKey))
+
+	return client, nil
+}
+
+// newAccountAPIClient creates an authenticated API client for calls that
+// are not about the current project, such as accepting an invitation. It
+// talks to the endpoint 'envault login' would use.
+func newAccountAPIClient() (*api.Client, error) {
+	project, _ := utils.LoadProjectContext()
+	baseURL, apiKey, err := loginEndpoint("")
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccountEndpointClient(project, baseURL, apiKey)
+}
+
+// newAccountEndpointClient is newAccountAPIClient for a given endpoint,
+// authenticated as the account resolved for project (which may be nil)
+func newAccountEndpointClient(project *utils.ProjectContext, baseURL, apiKey string) (*api.Client, error) {
+	if err := requireLogin(project); err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("no API key configured\nRun this inside a project with an EnvVault remote or set ENVAULT_API_KEY")
+	}
+
+	client, err := newAPIEndpointClient(baseURL, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := envToken(); token != "" {
+		client.SetAuthToken(token)
+		return client, nil
+	}
+
+	account, _ := resolveProfile(project)
+	session, err := auth.LoadSession(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user session: %w", err)
+	}
+	client.SetTokenSource(sessionTokenSource(session, baseURL, apiKey))
+
+	return client, nil
+}
+
+// newAPIEndpointClient creates an unauthenticated API client that uses the
+// configured proxy, CA bundle, client certificate and pins
+func newAPIEndpointClient(baseURL, apiKey string) (*api.Client, error) {
+	httpClient, err := newHTTPClient(true)
+	if err != nil {
+		return nil, err
+	}
+
+	client := api.New(baseURL, apiKey)
+	client.SetTransport(httpClient.Transport)
+
+	return client, nil
+}
+
+// apiEndpoint returns the base URL and API key for an API remote. The
+// ENVAULT_API_URL and ENVAULT_API_KEY environment variables fill in whatever
+// the remote does not specify, which keeps CI setups working.
+func apiEndpoint(rawURL string, creds *auth.RemoteCredentials) (string, string) {
+	baseURL := rawURL
+	if baseURL == "" || baseURL == backend.KindSupabase {
+		baseURL = os.Getenv("ENVAULT_API_URL")
+	}
+
+	apiKey := os.Getenv("ENVAULT_API_KEY")
+	if creds != nil && creds.APIKey != "" {
+		apiKey = creds.APIKey
+	}
+
+	return strings.TrimSuffix(baseURL, "/"), apiKey
+}
+
+// loadRemoteCredentials reads a remote's stored credentials. A keychain that
+// cannot be read is not fatal: the environment variables are used instead.
+func loadRemoteCredentials(projectID, remoteName string) (*auth.RemoteCredentials, error) {
+	if remoteName == "" {
+		return nil, nil
+	}
+
+	creds, err := auth.LoadRemoteCredentials(projectID, remoteName)
+	if err != nil {
+		if debug {
+			utils.Warn("Could not read credentials for remote %s: %v", remoteName, err)
+		}
+		return nil, nil
+	}
+
+	return creds, nil
+}
+
+// openBackend opens a non-API backend with optional stored credentials
+func openBackend(rawURL string, creds *auth.RemoteCredentials) (backend.SyncBackend, error) {
+	cfg, err := config.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config: %w", err)
+	}
+
+	httpClient, err := newHTTPClient(false)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := backend.Options{CacheDir: cfg.CacheDir, Transport: httpClient.Transport}
+	if creds != nil {
+		opts.AccessKeyID = creds.AccessKeyID
+		opts.SecretAccessKey = creds.SecretAccessKey
+		opts.SessionToken = creds.SessionToken
+	}
+
+	return backend.Open(rawURL, opts)
+}
+
+// openDB opens the local database
+func openDB() (*storage.DB, error) {
+	cfg, err := config.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config: %w", err)
+	}
+
+	return storage.New(cfg.DBPath)
+}
+
+// logRemoteChange records a remote change in the audit log, best effort
+func logRemoteChange(projectID, action, name, kind string) {
+	logAudit(projectID, action, map[string]interface{}{
+		"remote": name,
+		"kind":   kind,
+	})
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderEnv    string
+	renderOutput string
+	renderStrict bool
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render TEMPLATE",
+	Short: "Render a config file template with secrets",
+	Long: `Render a Go text/template with secrets from the vault, for software that
+reads config files rather than environment variables.
+
+Template functions:
+  secret "KEY"              Value of KEY in the --env environment
+  env "production" "KEY"    Value of KEY in another environment
+  base64 VALUE              Base64-encode a value
+  base64decode VALUE        Decode a base64 value
+  json VALUE                Encode a value as a JSON string
+  quote VALUE               Double-quote a value with backslash escapes
+  squote VALUE              Single-quote a value for POSIX shells
+  default FALLBACK VALUE    Use FALLBACK when VALUE is empty
+
+Keys of the --env environment are also available as {{ .KEY }}.
+
+Missing keys render as empty strings with a warning; with --strict they
+fail the render and nothing is written. Use - to read the template from
+stdin. The output goes to stdout, or to a 0600 file with --output.
+
+Examples:
+  envault render config/database.yml.tmpl -o config/database.yml
+  envault render --env production --strict nginx.conf.tmpl -o /etc/nginx/conf.d/app.conf
+
+  # database.yml.tmpl
+  password: {{ secret "DB_PASSWORD" | quote }}
+  replica_url: {{ env "production" "REPLICA_URL" }}
+  tls_key: {{ secret "TLS_KEY" | base64 }}`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRender,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVarP(&renderEnv, "env", "e", "development", "Environment for secret and .KEY")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "Output file (default: stdout)")
+	renderCmd.Flags().BoolVar(&renderStrict, "strict", false, "Fail if a key is missing")
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	input, err := readTemplateInput(args[0])
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	source, err := openSecretSource()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	// The default environment must exist even if the template only uses env
+	defaults, err := source.environment(renderEnv)
+	if err != nil {
+		return err
+	}
+
+	tmpl := template.New(filepath.Base(args[0])).Funcs(source.templateFuncs(renderEnv))
+	if renderStrict {
+		tmpl = tmpl.Option("missingkey=error")
+	} else {
+		tmpl = tmpl.Option("missingkey=zero")
+	}
+
+	tmpl, err = tmpl.Parse(string(input))
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, defaults); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if err := source.checkMissing(renderStrict); err != nil {
+		return err
+	}
+
+	return writeRendered(output.Bytes(), renderOutput)
+}
+
+// readTemplateInput reads a file, or stdin for -
+func readTemplateInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeRendered writes a rendered file with secure permissions, or to
+// stdout if path is empty
+func writeRendered(data []byte, path string) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	file, err := utils.CreateSecureFile(path)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// An existing file keeps its permissions when truncated
+	if err := utils.EnsureSecureFilePermissions(path); err != nil {
+		file.Close()
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if !quiet {
+		color.New(color.FgGreen).Printf("✓ Wrote %s\n", path)
+	}
+
+	// Try to add to .gitignore if the file is inside the project
+	clean := filepath.Clean(path)
+	if !filepath.IsAbs(clean) && !strings.HasPrefix(clean, "..") {
+		if err := utils.AddToGitignore(filepath.ToSlash(clean)); err != nil {
+			color.New(color.FgYellow).Printf("⚠ Could not add %s to .gitignore\n", path)
+		}
+	}
+
+	return nil
+}
+
+// secretSource decrypts environments on demand for render and inject, and
+// keeps track of keys that were asked for but don't exist
+type secretSource struct {
+	db        *storage.DB
+	cryptoSvc *crypto.Service
+	projectID string
+
+	environments map[string]map[string]string
+	missing      map[string]bool
+}
+
+// openSecretSource opens the vault of the current project
+func openSecretSource() (*secretSource, error) {
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return nil, fmt.Errorf("Error: %v", err)
+	}
+
+	// Initialize services
+	cfg, err := config.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config: %w", err)
+	}
+
+	db, err := storage.New(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	cryptoSvc, err := crypto.New()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize crypto: %w", err)
+	}
+
+	return &secretSource{
+		db:           db,
+		cryptoSvc:    cryptoSvc,
+		projectID:    ctx.ProjectID,
+		environments: make(map[string]map[string]string),
+		missing:      make(map[string]bool),
+	}, nil
+}
+
+// Close closes the database
+func (s *secretSource) Close() error {
+	return s.db.Close()
+}
+
+// environment returns the decrypted secrets of an environment
+func (s *secretSource) environment(name string) (map[string]string, error) {
+	if secrets, ok := s.environments[name]; ok {
+		return secrets, nil
+	}
+
+	environment, err := s.db.GetEnvironment(s.projectID, name)
+	if err != nil {
+		return nil, fmt.Errorf("environment '%s' not found: %w", name, err)
+	}
+
+	list, err := s.db.ListSecrets(environment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	secrets := make(map[string]string, len(list))
+	for _, secret := range list {
+		value, err := s.cryptoSvc.Decrypt(secret.EncryptedValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
+		}
+		secrets[secret.Key] = value
+	}
+
+	s.environments[name] = secrets
+	return secrets, nil
+}
+
+// lookup returns a secret, remembering it as missing if it doesn't exist
+func (s *secretSource) lookup(envName, key string) (string, bool, error) {
+	secrets, err := s.environment(envName)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := secrets[key]
+	if !ok {
+		s.missing[envName+"/"+key] = true
+	}
+	return value, ok, nil
+}
+
+// checkMissing reports the keys that were missing: as an error in strict
+// mode, otherwise as a warning
+func (s *secretSource) checkMissing(strict bool) error {
+	if len(s.missing) == 0 {
+		return nil
+	}
+
+	missing := make([]string, 0, len(s.missing))
+	for ref := range s.missing {
+		missing = append(missing, ref)
+	}
+	sort.Strings(missing)
+
+	if strict {
+		return fmt.Errorf("Error: missing keys: %s", strings.Join(missing, ", "))
+	}
+
+	utils.Warn("Missing keys were left empty: %s", strings.Join(missing, ", "))
+	return nil
+}
+
+// templateFuncs returns the functions available to render templates.
+// Missing keys render as empty strings and are reported by checkMissing.
+func (s *secretSource) templateFuncs(defaultEnv string) template.FuncMap {
+	get := func(envName, key string) (string, error) {
+		value, _, err := s.lookup(envName, key)
+		return value, err
+	}
+
+	return template.FuncMap{
+		"secret": func(key string) (string, error) {
+			return get(defaultEnv, key)
+		},
+		"env": get,
+		"base64": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"base64decode": func(value string) (string, error) {
+			data, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid base64: %w", err)
+			}
+			return string(data), nil
+		},
+		"json": func(value interface{}) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+		"quote": func(value string) string {
+			return strconv.Quote(value)
+		},
+		"squote": func(value string) string {
+			return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+		},
+		"default": func(fallback, value string) string {
+			if value == "" {
+				return fallback
+			}
+			return value
+		},
+	}
+}
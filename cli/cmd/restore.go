@@ -8,6 +8,7 @@ import (
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
@@ -52,6 +53,11 @@ func runRestore(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "restoring a backup"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -143,6 +149,9 @@ func runRestore(cmd *cobra.Command, args []string) error {
 			if err != nil {
 				return fmt.Errorf("failed to create environment %s: %w", envName, err)
 			}
+			if err := queueSyncChange(db, ctx.ProjectID, models.OutboxEnvironmentCreated, envName, "", nil); err != nil {
+				return fmt.Errorf("failed to queue %s for sync: %w", envName, err)
+			}
 			envsCreated++
 			if !quiet {
 				cyan.Printf("  Created environment: %s\n", envName)
@@ -159,6 +168,9 @@ func runRestore(cmd *cobra.Command, args []string) error {
 				if err := db.DeleteSecret(secret.ID); err != nil {
 					return fmt.Errorf("failed to clear secret %s: %w", secret.Key, err)
 				}
+				if err := queueSyncChange(db, ctx.ProjectID, models.OutboxSecretDeleted, envName, secret.Key, nil); err != nil {
+					return fmt.Errorf("failed to queue %s for sync: %w", secret.Key, err)
+				}
 			}
 		}
 
@@ -175,6 +187,9 @@ func runRestore(cmd *cobra.Command, args []string) error {
 			if err != nil {
 				return fmt.Errorf("failed to restore %s: %w", key, err)
 			}
+			if err := queueSyncChange(db, ctx.ProjectID, models.OutboxSecretSet, envName, key, encryptedValue); err != nil {
+				return fmt.Errorf("failed to queue %s for sync: %w", key, err)
+			}
 
 			totalRestored++
 		}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/backend"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/utils"
+)
+
+// Project roles, from least to most privileged
+const (
+	roleViewer    = "viewer"
+	roleDeveloper = "developer"
+	roleAdmin     = "admin"
+)
+
+// projectRoleRank orders roles. EnvVault cloud calls developers members.
+var projectRoleRank = map[string]int{
+	roleViewer:    1,
+	"member":      2,
+	roleDeveloper: 2,
+	roleAdmin:     3,
+}
+
+const (
+	// roleCacheTTL is how long a cached role is trusted before it is
+	// fetched again
+	roleCacheTTL = 24 * time.Hour
+
+	// roleRefreshTimeout bounds the role lookup so commands stay fast
+	// offline
+	roleRefreshTimeout = 3 * time.Second
+)
+
+// roleAllows reports whether role grants at least the required role
+func roleAllows(role, required string) bool {
+	return projectRoleRank[role] >= projectRoleRank[required]
+}
+
+// validateRole checks that role is one a member can be given
+func validateRole(role string) error {
+	switch role {
+	case roleViewer, roleDeveloper, roleAdmin:
+		return nil
+	}
+	return fmt.Errorf("invalid role %q (expected viewer, developer or admin)", role)
+}
+
+// requireProjectRole checks that the current user has at least the given
+// role in the project on its default remote. action names what is being
+// attempted, e.g. "setting secrets".
+//
+// Roles are cached from the server's team list so the check also works
+// offline. It is only a courtesy: the server enforces roles itself, so
+// whenever the role cannot be determined the command is allowed.
+func requireProjectRole(ctx context.Context, project *utils.ProjectContext, required, action string) error {
+	remoteName, rawURL, err := resolveRemote(project, "")
+	if err != nil {
+		return nil
+	}
+
+	return requireRemoteRole(ctx, project, remoteName, rawURL, required, action)
+}
+
+// requireRemoteRole is requireProjectRole for a given remote
+func requireRemoteRole(ctx context.Context, project *utils.ProjectContext, remoteName, rawURL, required, action string) error {
+	// Only API remotes have roles
+	if kind, err := backend.Kind(rawURL); err != nil || kind != backend.KindSupabase {
+		return nil
+	}
+
+	// Tokens from ENVAULT_TOKEN are left to the server; machine tokens
+	// cannot push anyway
+	if envToken() != "" {
+		return nil
+	}
+
+	// Without a session the project is only used locally
+	account, _ := resolveProfile(project)
+	session, err := auth.LoadSession(account)
+	if err != nil || session.UserID == "" {
+		return nil
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	cached, err := db.GetProjectRole(project.ProjectID, remoteName, session.UserID)
+	if err != nil {
+		return nil
+	}
+
+	role := ""
+	if cached != nil {
+		role = cached.Role
+	}
+
+	// Refresh stale roles, and check again before refusing in case the
+	// user was promoted since
+	if cached == nil || time.Since(cached.FetchedAt) > roleCacheTTL || (role != "" && !roleAllows(role, required)) {
+		if fetched, ok := fetchProjectRole(ctx, db, project, remoteName, rawURL, session.UserID); ok {
+			role = fetched
+		}
+	}
+
+	if role == "" || roleAllows(role, required) {
+		return nil
+	}
+
+	return fmt.Errorf("%s requires %s role (your role in %s is %s)\nAsk a project admin to run 'envault team role %s %s'",
+		action, required, project.ProjectName, role, session.Email, required)
+}
+
+// fetchProjectRole asks the remote for the user's role and caches it. It
+// reports false if the remote could not be asked, e.g. when offline or the
+// project has not been pushed yet.
+func fetchProjectRole(ctx context.Context, db *storage.DB, project *utils.ProjectContext, remoteName, rawURL, userID string) (string, bool) {
+	client, err := newRemoteAPIClient(project, remoteName, rawURL)
+	if err != nil {
+		return "", false
+	}
+	client.SetRetryPolicy(api.NoRetry)
+
+	ctx, cancel := context.WithTimeout(ctx, roleRefreshTimeout)
+	defer cancel()
+
+	members, err := client.ListTeamMembers(ctx, project.ProjectID)
+	if err != nil {
+		if debug {
+			utils.Warn("Could not refresh your project role: %v", err)
+		}
+		return "", false
+	}
+
+	role := memberRole(members, userID)
+	if err := db.SetProjectRole(project.ProjectID, remoteName, userID, role); err != nil && debug {
+		utils.Warn("%v", err)
+	}
+
+	return role, true
+}
+
+// cacheTeamRole records the current user's role from a team list fetched
+// from the project's default remote, best effort
+func cacheTeamRole(project *utils.ProjectContext, members []api.TeamMember) {
+	if envToken() != "" {
+		return
+	}
+
+	remoteName, _, err := resolveRemote(project, "")
+	if err != nil {
+		return
+	}
+
+	account, _ := resolveProfile(project)
+	session, err := auth.LoadSession(account)
+	if err != nil || session.UserID == "" {
+		return
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if err := db.SetProjectRole(project.ProjectID, remoteName, session.UserID, memberRole(members, session.UserID)); err != nil && debug {
+		utils.Warn("%v", err)
+	}
+}
+
+// memberRole returns a user's role in a team list, or "" if they are not
+// listed (project owners on EnvVault cloud may not be)
+func memberRole(members []api.TeamMember, userID string) string {
+	for _, member := range members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/dj-pearson/envault/internal/auth"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,9 +18,10 @@ var (
 	BuildTime string
 
 	// Global flags
-	quiet      bool
-	jsonOutput bool
-	debug      bool
+	quiet       bool
+	jsonOutput  bool
+	debug       bool
+	profileFlag string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -38,8 +43,13 @@ Features:
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// The command context is cancelled on Ctrl-C or SIGTERM so that in-flight
+// network requests and retries stop promptly.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -50,6 +60,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress output")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output in JSON format")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug mode")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "login profile to use (default: the project's pinned account or the active profile)")
 
 	// Version flag
 	rootCmd.Flags().BoolP("version", "v", false, "show version information")
@@ -91,4 +102,9 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil && debug {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	// Move session tokens saved by older versions out of session.json
+	if err := auth.MigrateLegacySession(); err != nil && debug {
+		fmt.Fprintln(os.Stderr, "Could not move session to keychain:", err)
+	}
 }
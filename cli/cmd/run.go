@@ -4,34 +4,129 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"runtime"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
 	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/supervisor"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-var (
-	runEnv         string
-	runPreserveEnv bool
-)
+// runOptions are the flags of 'envault run'
+type runOptions struct {
+	// Environments are layered in order; later ones win
+	Environments []string
+	// Set holds --set KEY=VALUE overrides, which win over every layer
+	Set map[string]string
+	// Only and Exclude filter vault keys by glob pattern
+	Only    []string
+	Exclude []string
+	// FileSecrets maps variables to the vault keys written to files for them
+	FileSecrets map[string]string
+
+	PreserveEnv bool
+	NoOverride  bool
+	Exec        bool
+	Redact      bool
+
+	// Watch restarts the command when its secrets change, checking every
+	// WatchInterval and stopping it with StopSignal, then SIGKILL after
+	// GracePeriod
+	Watch         bool
+	WatchInterval time.Duration
+	StopSignal    os.Signal
+	GracePeriod   time.Duration
+}
 
 var runCmd = &cobra.Command{
-	Use:   "run [command]",
+	Use:   "run [flags] [--] command [args...]",
 	Short: "Run a command with environment variables injected",
 	Long: `Run a command with environment variables injected from the encrypted vault.
 
 Variables are loaded into the process environment and then the command is executed.
 The variables only exist for that process and are not written to disk.
 
+envault stays running as the command's supervisor: signals such as Ctrl-C
+and SIGTERM are passed on to it and envault exits with its exit code. With
+--exec, envault replaces itself with the command instead, which has no
+overhead but leaves nothing to clean up afterwards (not available on
+Windows).
+
+With --redact, the command's output is scanned for the injected secret
+values, including their base64 and URL-encoded forms, which are replaced
+with ***KEY***. Values shorter than 4 characters are left alone. Output
+that could be the start of a secret, such as the first lines of a PEM key,
+is held back until more output shows whether it is one, so it may show up
+late. When output goes to a terminal the command still gets one, so
+colours and progress bars keep working.
+
+Some programs only read credentials from a file. --file-secret VAR=KEY
+writes the vault key KEY to a 0600 file in a private directory, in memory
+where the system supports it (tmpfs on Linux), and sets VAR to its path.
+KEY itself is not injected as a variable. The file is overwritten and
+removed when the command exits.
+
+With --watch, envault checks the selected environments for changes every
+few seconds. When a secret the command gets is set or deleted, for example
+with 'envault set' in another terminal, the command is stopped with the
+stop signal, killed if it is still running after the grace period, and
+started again with the new values. envault exits when the command exits
+on its own.
+
+Flags must come before the command; everything from the first argument
+that is not a flag (or after --) is the command.
+
+Flags:
+  -e, --env NAME       Environment to load (default development). Repeat to
+                       layer environments: later ones override earlier ones
+      --set KEY=VALUE  Set a variable for this run only (repeatable)
+      --file-secret VAR=KEY
+                       Write KEY to a temporary file and set VAR to its
+                       path (repeatable)
+      --only PATTERN   Only inject vault keys matching a glob, e.g. 'DB_*'
+      --exclude PATTERN
+                       Don't inject vault keys matching a glob
+      --preserve-env   Pass on the current shell environment as well
+      --no-override    Fail if a vault key is already set in the shell
+      --exec           Replace envault with the command
+      --redact         Mask secret values in the command's output
+      --watch          Restart the command when its secrets change
+      --watch-interval DURATION
+                       How often to check for changes (default 2s)
+      --stop-signal SIGNAL
+                       Signal that stops the command on restart
+                       (default SIGTERM)
+      --grace-period DURATION
+                       How long to wait before killing it (default 10s)
+
+Variables are applied in this order, each overriding the one before:
+  1. the shell environment (with --preserve-env)
+  2. each --env layer, in the order given
+  3. --set values
+
+--only and --exclude take comma-separated patterns and can be repeated;
+they filter vault keys, not the shell environment or --set values.
+
 Examples:
   envault run npm start
   envault run --env production node server.js
-  envault run python manage.py migrate
+  envault run --env base --env development npm start
+  envault run --set LOG_LEVEL=debug -- npm start
+  envault run --only 'DB_*' --exclude DB_ADMIN_PASSWORD python manage.py migrate
+  envault run --preserve-env --no-override make deploy
+  envault run --exec node server.js
+  envault run --redact -- ./ci/deploy.sh
+  envault run --watch -- npm run dev
+  envault run --watch --stop-signal SIGINT --grace-period 3s -- rails server
+  envault run --file-secret GOOGLE_APPLICATION_CREDENTIALS=GCP_SA_JSON -- terraform plan
   envault run bash  # Interactive shell with vars`,
 	DisableFlagParsing: true,
 	RunE:               runRun,
@@ -45,22 +140,17 @@ func runRun(cmd *cobra.Command, args []string) error {
 	green := color.New(color.FgGreen)
 	cyan := color.New(color.FgCyan)
 
-	// Parse flags manually since we disabled flag parsing
-	var cmdArgs []string
-	env := "development"
-	preserveEnv := false
+	if len(args) > 0 && (args[0] == "--help" || args[0] == "-h") {
+		return cmd.Help()
+	}
 
-	for i := 0; i < len(args); i++ {
-		if args[i] == "--env" || args[i] == "-e" {
-			if i+1 < len(args) {
-				env = args[i+1]
-				i++
-			}
-		} else if args[i] == "--preserve-env" {
-			preserveEnv = true
-		} else {
-			cmdArgs = append(cmdArgs, args[i])
-		}
+	// Parse flags manually since we disabled flag parsing
+	opts, cmdArgs, err := parseRunArgs(args)
+	if err == nil {
+		err = opts.withDefaults().validate()
+	}
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
 	if len(cmdArgs) == 0 {
@@ -90,48 +180,13 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize crypto: %w", err)
 	}
 
-	// Get environment
-	environment, err := db.GetEnvironment(ctx.ProjectID, env)
-	if err != nil {
-		return fmt.Errorf("environment '%s' not found: %w", env, err)
-	}
-
-	// Load secrets
-	secrets, err := db.ListSecrets(environment.ID)
+	env, err := buildRunEnv(db, cryptoSvc, ctx.ProjectID, opts)
 	if err != nil {
-		return fmt.Errorf("failed to load secrets: %w", err)
-	}
-
-	// Prepare environment variables
-	envVars := make(map[string]string)
-
-	// Preserve existing environment if requested
-	if preserveEnv {
-		for _, e := range os.Environ() {
-			parts := strings.SplitN(e, "=", 2)
-			if len(parts) == 2 {
-				envVars[parts[0]] = parts[1]
-			}
-		}
-	}
-
-	// Decrypt and add secrets
-	for _, secret := range secrets {
-		value, err := cryptoSvc.Decrypt(secret.EncryptedValue)
-		if err != nil {
-			return fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
-		}
-		envVars[secret.Key] = value
+		return err
 	}
 
 	if !quiet && debug {
-		cyan.Printf("✓ Loaded %d environment variables from %s\n", len(secrets), env)
-	}
-
-	// Convert map to slice for exec
-	envSlice := make([]string, 0, len(envVars))
-	for k, v := range envVars {
-		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
+		cyan.Printf("✓ Loaded %d environment variables from %s\n", len(env.Vault), strings.Join(opts.Environments, " + "))
 	}
 
 	// Find command in PATH
@@ -141,7 +196,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// SECURITY SAFEGUARD: Warn if running with production env
-	if env == "production" && !quiet {
+	if opts.hasEnvironment("production") && !quiet {
 		yellow := color.New(color.FgYellow)
 		yellow.Printf("⚠ Running with production environment variables\n")
 	}
@@ -151,12 +206,422 @@ func runRun(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
-	// Execute command with environment
-	// Use syscall.Exec to replace current process (like exec in bash)
-	err = syscall.Exec(cmdPath, cmdArgs, envSlice)
+	// Replace the current process with the command (like exec in bash)
+	if opts.Exec {
+		if runtime.GOOS == "windows" {
+			return fmt.Errorf("--exec is not supported on Windows")
+		}
+		if err := syscall.Exec(cmdPath, cmdArgs, env.environ()); err != nil {
+			return fmt.Errorf("failed to execute command: %w", err)
+		}
+		return nil
+	}
+
+	if opts.Watch {
+		return watchRun(db, cryptoSvc, ctx.ProjectID, opts, cmdPath, cmdArgs, env)
+	}
+
+	// Run the command as a supervised child
+	child, err := newRunChild(cmdPath, cmdArgs, env, opts)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	return runResult(child.Run())
+}
+
+// newRunChild prepares the supervised command: it writes the --file-secret
+// files, which are removed when the command exits, and sets up redaction
+func newRunChild(cmdPath string, cmdArgs []string, env *runEnv, opts *runOptions) (*supervisor.Process, error) {
+	var secretDir *utils.SecretDir
+	if len(env.Files) > 0 {
+		var err error
+		secretDir, err = writeSecretFiles(env, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	child := supervisor.New(cmdPath, cmdArgs, env.environ())
+	if opts.Redact {
+		redactor := utils.NewRedactor(env.Secrets)
+		child.FilterOutput(redactor.Writer)
+	}
+	if secretDir != nil {
+		child.AfterExit(func(int) {
+			if err := secretDir.Remove(); err != nil {
+				utils.Warn("Could not remove secret files in %s: %v", secretDir.Path(), err)
+			}
+		})
+	}
+	child.AfterExit(func(code int) {
+		if debug {
+			color.New(color.FgCyan).Fprintf(os.Stderr, "Command exited with code %d\n", code)
+		}
+	})
+
+	return child, nil
+}
+
+// runResult turns the outcome of a supervised command into the error
+// 'envault run' returns, so envault exits with the command's exit code
+func runResult(code int, err error) error {
 	if err != nil {
 		return fmt.Errorf("failed to execute command: %w", err)
 	}
+	if code != 0 {
+		if code < 0 {
+			code = 1
+		}
+		return &ExitError{Code: code}
+	}
 
 	return nil
 }
+
+// parseRunArgs splits the arguments of 'envault run' into its options and
+// the command to run. Flags are only recognised before the command. Defaults
+// are filled in by withDefaults.
+func parseRunArgs(args []string) (*runOptions, []string, error) {
+	opts := &runOptions{Set: make(map[string]string), FileSecrets: make(map[string]string)}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			return opts, args[i+1:], nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return opts, args[i:], nil
+		}
+
+		// Accept both --flag value and --flag=value
+		name, value, hasValue := strings.Cut(arg, "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("flag %s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "--env", "-e":
+			env, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.Environments = append(opts.Environments, env)
+
+		case "--set":
+			pair, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid --set %q (expected KEY=VALUE)", pair)
+			}
+			if err := utils.ValidateEnvKey(key); err != nil {
+				return nil, nil, err
+			}
+			opts.Set[key] = val
+
+		case "--file-secret":
+			pair, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			name, key, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid --file-secret %q (expected VAR=KEY)", pair)
+			}
+			if err := utils.ValidateEnvKey(name); err != nil {
+				return nil, nil, err
+			}
+			if err := utils.ValidateEnvKey(key); err != nil {
+				return nil, nil, err
+			}
+			opts.FileSecrets[name] = key
+
+		case "--only", "--exclude":
+			patterns, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, pattern := range strings.Split(patterns, ",") {
+				pattern = strings.TrimSpace(pattern)
+				if pattern == "" {
+					continue
+				}
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+				}
+				if name == "--only" {
+					opts.Only = append(opts.Only, pattern)
+				} else {
+					opts.Exclude = append(opts.Exclude, pattern)
+				}
+			}
+
+		case "--watch":
+			opts.Watch = true
+		case "--watch-interval", "--grace-period":
+			value, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return nil, nil, fmt.Errorf("invalid %s %q (expected a duration such as 2s or 500ms)", name, value)
+			}
+			if name == "--watch-interval" {
+				opts.WatchInterval = duration
+			} else {
+				opts.GracePeriod = duration
+			}
+		case "--stop-signal":
+			value, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			sig, err := supervisor.ParseSignal(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.StopSignal = sig
+
+		case "--preserve-env":
+			opts.PreserveEnv = true
+		case "--no-override":
+			opts.NoOverride = true
+		case "--exec":
+			opts.Exec = true
+		case "--redact":
+			opts.Redact = true
+
+		// Global flags aren't parsed for run either
+		case "-q", "--quiet":
+			quiet = true
+		case "--debug":
+			debug = true
+
+		default:
+			return nil, nil, fmt.Errorf("unknown flag %s (see 'envault run --help')", name)
+		}
+	}
+
+	return opts, nil, nil
+}
+
+// validate rejects combinations of flags that cannot work together
+func (o *runOptions) validate() error {
+	if o.Exec && o.Redact {
+		return fmt.Errorf("--redact needs envault to stay running and cannot be used with --exec")
+	}
+	if o.Exec && o.Watch {
+		return fmt.Errorf("--watch needs envault to stay running and cannot be used with --exec")
+	}
+	if o.Exec && len(o.FileSecrets) > 0 {
+		return fmt.Errorf("--file-secret needs envault to remove the files afterwards and cannot be used with --exec")
+	}
+	for name := range o.FileSecrets {
+		if _, ok := o.Set[name]; ok {
+			return fmt.Errorf("%s is given by both --set and --file-secret", name)
+		}
+	}
+	return nil
+}
+
+// writeSecretFiles writes the --file-secret values to a private directory
+// and points their variables at the files
+func writeSecretFiles(env *runEnv, opts *runOptions) (*utils.SecretDir, error) {
+	dir, err := utils.NewSecretDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range env.Files {
+		path, err := dir.WriteFile(opts.FileSecrets[name], value)
+		if err != nil {
+			dir.Remove()
+			return nil, err
+		}
+		env.Vars[name] = path
+	}
+
+	return dir, nil
+}
+
+// withDefaults fills in the default environment and watch settings
+func (o *runOptions) withDefaults() *runOptions {
+	if len(o.Environments) == 0 {
+		o.Environments = []string{"development"}
+	}
+	if o.WatchInterval == 0 {
+		o.WatchInterval = 2 * time.Second
+	}
+	if o.StopSignal == nil {
+		o.StopSignal = syscall.SIGTERM
+	}
+	if o.GracePeriod == 0 {
+		o.GracePeriod = 10 * time.Second
+	}
+	return o
+}
+
+// hasEnvironment reports whether name is one of the layers
+func (o *runOptions) hasEnvironment(name string) bool {
+	for _, env := range o.Environments {
+		if env == name {
+			return true
+		}
+	}
+	return false
+}
+
+// includesKey reports whether a vault key passes the --only and --exclude
+// filters
+func (o *runOptions) includesKey(key string) bool {
+	if len(o.Only) > 0 && !matchesAny(o.Only, key) {
+		return false
+	}
+	return !matchesAny(o.Exclude, key)
+}
+
+// matchesAny reports whether key matches one of the glob patterns
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runEnv is what 'envault run' passes to the command
+type runEnv struct {
+	// Vars is the command's environment
+	Vars map[string]string
+	// Vault holds the vault variables that were injected
+	Vault map[string]string
+	// Files maps each --file-secret variable to the value of its file
+	Files map[string]string
+	// Secrets holds every vault value the command gets, by key, as
+	// variables or files
+	Secrets map[string]string
+}
+
+// environ returns the variables in the KEY=value form os/exec expects
+func (e *runEnv) environ() []string {
+	environ := make([]string, 0, len(e.Vars))
+	for k, v := range e.Vars {
+		environ = append(environ, fmt.Sprintf("%s=%s", k, v))
+	}
+	return environ
+}
+
+// buildRunEnv decrypts the selected environments and merges them with the
+// shell environment and --set values in the documented order. Keys used by
+// --file-secret are looked up regardless of --only and --exclude and are
+// not injected as variables.
+func buildRunEnv(db *storage.DB, cryptoSvc *crypto.Service, projectID string, opts *runOptions) (*runEnv, error) {
+	fileKeys := make(map[string]bool)
+	for _, key := range opts.FileSecrets {
+		fileKeys[key] = true
+	}
+
+	// Decrypt the layers, later environments overriding earlier ones
+	vault := make(map[string]string)
+	fileValues := make(map[string]string)
+	for _, envName := range opts.Environments {
+		environment, err := db.GetEnvironment(projectID, envName)
+		if err != nil {
+			return nil, fmt.Errorf("environment '%s' not found: %w", envName, err)
+		}
+
+		secrets, err := db.ListSecrets(environment.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load secrets: %w", err)
+		}
+
+		for _, secret := range secrets {
+			isFile := fileKeys[secret.Key]
+			if !isFile && !opts.includesKey(secret.Key) {
+				continue
+			}
+
+			value, err := cryptoSvc.Decrypt(secret.EncryptedValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
+			}
+
+			if isFile {
+				fileValues[secret.Key] = value
+			} else {
+				vault[secret.Key] = value
+			}
+		}
+	}
+
+	result := &runEnv{
+		Vars:    make(map[string]string),
+		Vault:   vault,
+		Files:   make(map[string]string),
+		Secrets: make(map[string]string),
+	}
+
+	for name, key := range opts.FileSecrets {
+		value, ok := fileValues[key]
+		if !ok {
+			return nil, fmt.Errorf("Error: --file-secret %s: key '%s' not found in %s", name, key, strings.Join(opts.Environments, " + "))
+		}
+		result.Files[name] = value
+		result.Secrets[key] = value
+	}
+	for key, value := range vault {
+		result.Secrets[key] = value
+	}
+
+	// Refuse to shadow variables that are already set
+	if opts.NoOverride {
+		var collisions []string
+		for key := range vault {
+			if _, ok := os.LookupEnv(key); ok {
+				collisions = append(collisions, key)
+			}
+		}
+		for name := range opts.FileSecrets {
+			if _, ok := os.LookupEnv(name); ok {
+				collisions = append(collisions, name)
+			}
+		}
+		if len(collisions) > 0 {
+			sort.Strings(collisions)
+			return nil, fmt.Errorf("Error: vault keys already set in the shell: %s\nUnset them, or drop --no-override to let the vault win", strings.Join(collisions, ", "))
+		}
+	}
+
+	// Preserve existing environment if requested
+	if opts.PreserveEnv {
+		for _, e := range os.Environ() {
+			parts := strings.SplitN(e, "=", 2)
+			if len(parts) == 2 {
+				result.Vars[parts[0]] = parts[1]
+			}
+		}
+	}
+
+	for key, value := range vault {
+		result.Vars[key] = value
+	}
+
+	for key, value := range opts.Set {
+		result.Vars[key] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/supervisor"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+)
+
+// watchRun runs the command and restarts it with the new environment
+// whenever the secrets it gets change, until it exits on its own
+func watchRun(db *storage.DB, cryptoSvc *crypto.Service, projectID string, opts *runOptions, cmdPath string, cmdArgs []string, env *runEnv) error {
+	cyan := color.New(color.FgCyan)
+
+	checksum, err := runChecksum(db, projectID, opts.Environments)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		cyan.Fprintf(os.Stderr, "Watching %s for changes\n", strings.Join(opts.Environments, " + "))
+	}
+
+	for {
+		child, err := newRunChild(cmdPath, cmdArgs, env, opts)
+		if err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
+		if err := child.Start(); err != nil {
+			return fmt.Errorf("failed to execute command: %w", err)
+		}
+
+		stop := supervisor.Forward(child)
+		next := waitForSecretChange(child, db, cryptoSvc, projectID, opts, env, &checksum)
+		stop()
+
+		// The command exited by itself (or was interrupted)
+		if next == nil {
+			return runResult(child.Wait())
+		}
+
+		if !quiet {
+			cyan.Fprintf(os.Stderr, "↻ Secrets changed, restarting %s\n", cmdArgs[0])
+		}
+
+		child.Stop(opts.StopSignal, opts.GracePeriod)
+		child.Wait()
+
+		env = next
+	}
+}
+
+// waitForSecretChange polls the vault until the secrets the command gets
+// change, and returns the new environment. It returns nil once the command
+// has exited. Writes that leave the command's secrets as they were, such as
+// changes to keys filtered out with --only, don't count.
+func waitForSecretChange(child *supervisor.Process, db *storage.DB, cryptoSvc *crypto.Service, projectID string, opts *runOptions, current *runEnv, checksum *string) *runEnv {
+	ticker := time.NewTicker(opts.WatchInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	warn := func(format string, err error) {
+		if err.Error() != lastErr {
+			utils.Warn(format, err)
+			lastErr = err.Error()
+		}
+	}
+
+	for {
+		select {
+		case <-child.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		sum, err := runChecksum(db, projectID, opts.Environments)
+		if err != nil {
+			warn("Could not check the vault for changes: %v", err)
+			continue
+		}
+		if sum == *checksum {
+			continue
+		}
+
+		next, err := buildRunEnv(db, cryptoSvc, projectID, opts)
+		if err != nil {
+			warn("Secrets changed but could not be loaded; the command keeps running: %v", err)
+			continue
+		}
+
+		*checksum = sum
+		lastErr = ""
+
+		if maps.Equal(next.Secrets, current.Secrets) {
+			continue
+		}
+
+		return next
+	}
+}
+
+// runChecksum combines the secrets checksums of the layered environments
+func runChecksum(db *storage.DB, projectID string, environments []string) (string, error) {
+	sums := make([]string, 0, len(environments))
+	for _, envName := range environments {
+		environment, err := db.GetEnvironment(projectID, envName)
+		if err != nil {
+			return "", fmt.Errorf("environment '%s' not found: %w", envName, err)
+		}
+
+		sum, err := db.SecretsChecksum(environment.ID)
+		if err != nil {
+			return "", err
+		}
+		sums = append(sums, sum)
+	}
+
+	return strings.Join(sums, ","), nil
+}
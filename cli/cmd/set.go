@@ -1,15 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/envfile"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
@@ -40,7 +42,7 @@ func init() {
 	rootCmd.AddCommand(setCmd)
 
 	setCmd.Flags().StringVarP(&setEnv, "env", "e", "development", "Environment")
-	setCmd.Flags().StringVarP(&setFile, "file", "f", "", "Import from .env file")
+	setCmd.Flags().StringVarP(&setFile, "file", "f", "", "Import from a file (.env, JSON, YAML, ...)")
 	setCmd.Flags().StringVarP(&setDescription, "description", "d", "", "Description")
 }
 
@@ -54,6 +56,11 @@ func runSet(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v\nRun 'envault init' first", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "setting secrets"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -79,7 +86,7 @@ func runSet(cmd *cobra.Command, args []string) error {
 
 	// Handle file import
 	if setFile != "" {
-		return importFromFile(db, cryptoSvc, env.ID, setFile, green, yellow)
+		return importFromFile(db, cryptoSvc, ctx.ProjectID, env, setFile, green, yellow)
 	}
 
 	// Handle single key-value pair
@@ -139,20 +146,79 @@ func runSet(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if _, err := storeSecret(db, cryptoSvc, ctx.ProjectID, env, key, value, setDescription, ""); err != nil {
+		return err
+	}
+
+	if !quiet {
+		green.Printf("✓ Set %s in %s environment\n", key, setEnv)
+	}
+
+	return nil
+}
+
+func importFromFile(db *storage.DB, cryptoSvc *crypto.Service, projectID string, env *models.Environment, filePath string, green, yellow *color.Color) error {
+	// Read the file in whichever format it is
+	envMap, _, err := readImportFile(filePath, "auto", envfile.ParseOptions{})
+	if err != nil {
+		return err
+	}
+
+	if len(envMap) == 0 {
+		return fmt.Errorf("no variables found in %s", filePath)
+	}
+
+	// Import each variable
+	count := 0
+	for key, value := range envMap {
+		// Validate key
+		if err := utils.ValidateEnvKey(key); err != nil {
+			yellow.Printf("⚠ Skipping invalid key: %s (%v)\n", key, err)
+			continue
+		}
+
+		if _, err := storeSecret(db, cryptoSvc, projectID, env, key, value, "", "file_import"); err != nil {
+			yellow.Printf("⚠ Failed to store %s: %v\n", key, err)
+			continue
+		}
+
+		count++
+	}
+
+	green.Printf("✓ Imported %d variables from %s\n", count, filePath)
+	return nil
+}
+
+// storeSecret encrypts and stores a value the way set does: the old value
+// is kept in the history, and the change is queued for sync and written to
+// the audit log, with source naming the import it came from, if any. An
+// empty description keeps the existing one. It reports whether an existing
+// secret was updated.
+func storeSecret(db *storage.DB, cryptoSvc *crypto.Service, projectID string, env *models.Environment, key, value, description, source string) (bool, error) {
+	yellow := color.New(color.FgYellow)
+
 	// Check if secret already exists (for history tracking)
 	existingSecret, _ := db.GetSecret(env.ID, key)
 	isUpdate := existingSecret != nil
+	if isUpdate && description == "" {
+		description = existingSecret.Description
+	}
 
 	// Encrypt value
 	encrypted, err := cryptoSvc.Encrypt(value)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt value: %w", err)
+		return false, fmt.Errorf("failed to encrypt value: %w", err)
 	}
 
 	// Store secret
-	secret, err := db.CreateSecret(env.ID, key, encrypted, setDescription)
+	secret, err := db.CreateSecret(env.ID, key, encrypted, description)
 	if err != nil {
-		return fmt.Errorf("failed to store secret: %w", err)
+		return false, fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	// Queue the change for the next sync
+	if err := queueSyncChange(db, projectID, models.OutboxSecretSet, env.Name, key, encrypted); err != nil {
+		yellow.Printf("Warning: Failed to queue %s for sync: %v\n", key, err)
 	}
 
 	// Create history entry if this is an update
@@ -174,7 +240,7 @@ func runSet(cmd *cobra.Command, args []string) error {
 			nextVersion,
 		); err != nil {
 			// Don't fail the operation, just warn
-			yellow.Printf("Warning: Failed to create history entry: %v\n", err)
+			yellow.Printf("Warning: Failed to create history entry for %s: %v\n", key, err)
 		}
 	}
 
@@ -183,89 +249,18 @@ func runSet(cmd *cobra.Command, args []string) error {
 	if isUpdate {
 		action = "secret_updated"
 	}
-	metadata := fmt.Sprintf(`{"key":"%s","environment":"%s"}`, key, setEnv)
-	if err := db.CreateAuditLog(ctx.ProjectID, action, metadata); err != nil {
-		// Don't fail the operation, just warn
-		yellow.Printf("Warning: Failed to create audit log: %v\n", err)
-	}
-
-	if !quiet {
-		green.Printf("✓ Set %s in %s environment\n", key, setEnv)
-	}
-
-	return nil
-}
-
-func importFromFile(db *storage.DB, cryptoSvc *crypto.Service, envID, filePath string, green, yellow *color.Color) error {
-	// Read .env file
-	envMap, err := godotenv.Read(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	metadata := map[string]interface{}{
+		"key":         key,
+		"environment": env.Name,
 	}
-
-	if len(envMap) == 0 {
-		return fmt.Errorf("no variables found in %s", filePath)
+	if source != "" {
+		metadata["source"] = source
 	}
-
-	// Get project context for audit logging
-	ctx, _ := utils.LoadProjectContext()
-
-	// Import each variable
-	count := 0
-	for key, value := range envMap {
-		// Validate key
-		if err := utils.ValidateEnvKey(key); err != nil {
-			yellow.Printf("⚠ Skipping invalid key: %s (%v)\n", key, err)
-			continue
-		}
-
-		// Check if secret exists
-		existingSecret, _ := db.GetSecret(envID, key)
-		isUpdate := existingSecret != nil
-
-		// Encrypt and store
-		encrypted, err := cryptoSvc.Encrypt(value)
-		if err != nil {
-			yellow.Printf("⚠ Failed to encrypt %s: %v\n", key, err)
-			continue
-		}
-
-		secret, err := db.CreateSecret(envID, key, encrypted, "")
-		if err != nil {
-			yellow.Printf("⚠ Failed to store %s: %v\n", key, err)
-			continue
-		}
-
-		// Create history if update
-		if isUpdate {
-			history, _ := db.ListSecretHistory(secret.ID, 1)
-			nextVersion := 1
-			if len(history) > 0 {
-				nextVersion = history[0].Version + 1
-			}
-			db.CreateSecretHistory(
-				existingSecret.ID,
-				existingSecret.EnvironmentID,
-				existingSecret.Key,
-				existingSecret.EncryptedValue,
-				existingSecret.Description,
-				nextVersion,
-			)
-		}
-
-		// Create audit log
-		if ctx != nil {
-			action := "secret_created"
-			if isUpdate {
-				action = "secret_updated"
-			}
-			metadata := fmt.Sprintf(`{"key":"%s","source":"file_import"}`, key)
-			db.CreateAuditLog(ctx.ProjectID, action, metadata)
-		}
-
-		count++
+	data, _ := json.Marshal(metadata)
+	if err := db.CreateAuditLog(projectID, action, string(data)); err != nil {
+		// Don't fail the operation, just warn
+		yellow.Printf("Warning: Failed to create audit log: %v\n", err)
 	}
 
-	green.Printf("✓ Imported %d variables from %s\n", count, filePath)
-	return nil
+	return isUpdate, nil
 }
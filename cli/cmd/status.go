@@ -16,7 +16,7 @@ var statusCmd = &cobra.Command{
 	Short: "Show project status",
 	Long: `Show the current project's status including:
   - Project name and ID
-  - Sync status
+  - Sync status and changes waiting to be pushed
   - Environments and variable counts
   - Storage size
   - Team information (if applicable)
@@ -76,6 +76,23 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		yellow.Printf("Status: Local only\n")
 	}
 
+	if project.SyncEnabled {
+		if ctx.DefaultRemote != "" {
+			fmt.Printf("Remote: %s (%s)\n", ctx.DefaultRemote, ctx.Remotes[ctx.DefaultRemote])
+		}
+
+		if meta, err := db.GetSyncMetadata(project.ID, ctx.DefaultRemote); err == nil && meta != nil && meta.LastSyncAt != nil {
+			fmt.Printf("Last sync: %s (version %d)\n", meta.LastSyncAt.Format("2006-01-02 15:04:05"), meta.Version)
+		}
+
+		pending, err := db.CountOutbox(project.ID)
+		if err == nil && pending > 0 {
+			yellow.Printf("Pending sync: %d local changes (run 'envault sync' to push)\n", pending)
+		} else if err == nil {
+			fmt.Println("Pending sync: none")
+		}
+	}
+
 	fmt.Println()
 
 	// Environments
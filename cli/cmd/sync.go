@@ -1,15 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"os"
+	"time"
 
 	"github.com/dj-pearson/envault/internal/api"
-	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/backend"
 	"github.com/dj-pearson/envault/internal/config"
 	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
@@ -17,9 +19,12 @@ import (
 )
 
 var (
-	syncPush  bool
-	syncPull  bool
-	syncForce bool
+	syncPush       bool
+	syncPull       bool
+	syncForce      bool
+	syncWatch      bool
+	syncInterval   time.Duration
+	syncRemoteName string
 )
 
 var syncCmd = &cobra.Command{
@@ -42,7 +47,30 @@ Examples:
   envault sync              # Two-way sync
   envault sync --push       # Push only
   envault sync --pull       # Pull only
-  envault sync --force      # Force sync (override conflicts)`,
+  envault sync --force      # Force sync (override conflicts)
+  envault sync --watch      # Keep syncing until interrupted
+  envault sync --watch --interval 1m
+  envault sync --remote backup   # Sync with a non-default remote
+  ENVAULT_TOKEN=envm_... envault sync   # Pull in CI with a machine token
+
+Changes made while offline are queued locally and pushed automatically
+on the next successful sync or networked command. Run 'envault status'
+to see how many changes are pending. When the backend can't be reached,
+sync exits with status 7 so scripts can tell that nothing was synced.
+
+With --watch, envault keeps running: it polls for remote changes every
+--interval (default 30s, or the sync_interval config setting), pushes
+local changes a few seconds after they are made and backs off while the
+server is unreachable. Use 'envault daemon status' and 'envault daemon
+stop' to inspect or stop a running watcher.
+
+With ENVAULT_TOKEN set to a machine token (see 'envault token'), sync
+only pulls, and only the environments the token was issued for.
+
+Projects sync with their default remote unless --remote is given. See
+'envault remote' to sync through EnvVault cloud, a self-hosted
+envault-server, a shared directory, an S3-compatible bucket or a git
+repository.`,
 	RunE: runSync,
 }
 
@@ -52,6 +80,9 @@ func init() {
 	syncCmd.Flags().BoolVar(&syncPush, "push", false, "Push local changes only")
 	syncCmd.Flags().BoolVar(&syncPull, "pull", false, "Pull cloud changes only")
 	syncCmd.Flags().BoolVar(&syncForce, "force", false, "Force sync (override conflicts)")
+	syncCmd.Flags().BoolVar(&syncWatch, "watch", false, "Keep syncing in the foreground until interrupted")
+	syncCmd.Flags().DurationVar(&syncInterval, "interval", 0, "Poll interval for --watch (default 30s)")
+	syncCmd.Flags().StringVar(&syncRemoteName, "remote", "", "Remote to sync with (default: the project's default remote)")
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
@@ -59,16 +90,6 @@ func runSync(cmd *cobra.Command, args []string) error {
 	yellow := color.New(color.FgYellow)
 	cyan := color.New(color.FgCyan)
 
-	// Check authentication
-	if !auth.IsLoggedIn() {
-		return fmt.Errorf("Error: Not logged in\nRun 'envault login' first to enable team sync")
-	}
-
-	session, err := auth.GetCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get user session: %w", err)
-	}
-
 	// Load project context
 	ctx, err := utils.LoadProjectContext()
 	if err != nil {
@@ -92,108 +113,73 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize crypto: %w", err)
 	}
 
-	// Get API client
-	apiKey := os.Getenv("ENVAULT_API_KEY")
-	baseURL := os.Getenv("ENVAULT_API_URL")
-
-	if apiKey == "" {
-		return fmt.Errorf("Error: ENVAULT_API_KEY not set")
+	// Get sync remote
+	remote, err := openSyncRemote(ctx, syncRemoteName)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	client := api.New(baseURL, apiKey)
-	client.SetAuthToken(session.AccessToken)
+	if syncWatch {
+		return runSyncWatch(cmd, remote, db, cryptoSvc, ctx)
+	}
 
 	// Determine sync direction
 	doPull := !syncPush || syncPull
 	doPush := !syncPull || syncPush
 
-	// PULL from cloud
-	if doPull {
-		if !quiet {
-			cyan.Println("↓ Pulling from cloud...")
-		}
-
-		// Get latest blob from server
-		pullResp, err := client.PullEncryptedBlob(ctx.ProjectID, nil)
-		if err != nil {
-			return fmt.Errorf("failed to pull from cloud: %w", err)
+	// Machine tokens can only pull
+	if remote.ReadOnly() {
+		if syncPush {
+			return fmt.Errorf("Error: %v", errReadOnlyToken)
 		}
+		doPush = false
+	}
 
-		if pullResp.HasUpdate {
-			// Decrypt and import
-			encryptedData, err := base64.StdEncoding.DecodeString(pullResp.EncryptedData)
-			if err != nil {
-				return fmt.Errorf("failed to decode encrypted data: %w", err)
+	// Viewers can only pull
+	if doPush && !remote.ReadOnly() {
+		if err := requireRemoteRole(cmd.Context(), ctx, remote.Remote, ctx.Remotes[remote.Remote], roleDeveloper, "pushing changes"); err != nil {
+			if syncPush {
+				return fmt.Errorf("Error: %v", err)
 			}
-
-			// Verify checksum
-			actualChecksum := crypto.Hash(pullResp.EncryptedData)
-			if actualChecksum != pullResp.Checksum {
-				return fmt.Errorf("checksum mismatch: data may be corrupted")
+			doPush = false
+			if !quiet {
+				yellow.Println("Your role in this project is read-only; pulling only")
 			}
+		}
+	}
 
-			// Decrypt blob
-			decryptedJSON, err := cryptoSvc.Decrypt(encryptedData)
-			if err != nil {
-				return fmt.Errorf("failed to decrypt blob: %w", err)
-			}
+	// PULL from cloud
+	var pulled *pullResult
+	if doPull {
+		if !quiet {
+			cyan.Printf("↓ Pulling from %s...\n", remote.Name())
+		}
 
-			// Parse JSON data
-			var importData map[string]map[string]string
-			if err := json.Unmarshal([]byte(decryptedJSON), &importData); err != nil {
-				return fmt.Errorf("failed to parse synced data: %w", err)
+		pulled, err = pullProject(cmd.Context(), remote, db, cryptoSvc, ctx.ProjectID)
+		if err != nil {
+			if backend.IsUnavailable(err) {
+				return reportOffline(db, ctx.ProjectID, nil, err)
 			}
+			return err
+		}
 
-			// Check if there's any data to import
-			if len(importData) == 0 {
-				if !quiet {
-					fmt.Println("  No data in cloud sync")
+		if pulled != nil {
+			if !quiet {
+				for _, envName := range pulled.EnvironmentsCreated {
+					cyan.Printf("  Created environment: %s\n", envName)
 				}
-				return nil
 			}
 
-			// Import secrets into local database
-			secretsImported := 0
-			envsCreated := 0
-			for envName, secrets := range importData {
-				// Get or create environment
-				env, err := db.GetEnvironment(ctx.ProjectID, envName)
-				if err != nil {
-					// Environment doesn't exist, create it
-					env, err = db.CreateEnvironment(ctx.ProjectID, envName)
-					if err != nil {
-						return fmt.Errorf("failed to create environment %s: %w", envName, err)
-					}
-					envsCreated++
-					if !quiet {
-						cyan.Printf("  Created environment: %s\n", envName)
-					}
-				}
-
-				// Import secrets for this environment
-				for key, value := range secrets {
-					// Encrypt the value
-					encryptedValue, err := cryptoSvc.Encrypt(value)
-					if err != nil {
-						return fmt.Errorf("failed to encrypt %s: %w", key, err)
-					}
-
-					// Create/update secret (CreateSecret has upsert logic)
-					_, err = db.CreateSecret(env.ID, key, encryptedValue, "")
-					if err != nil {
-						return fmt.Errorf("failed to import %s: %w", key, err)
-					}
-
-					secretsImported++
-				}
+			if len(pulled.EnvironmentsCreated) > 0 {
+				green.Printf("✓ Pulled version %d (%d secrets, %d new environments)\n",
+					pulled.Version, pulled.Secrets, len(pulled.EnvironmentsCreated))
+			} else {
+				green.Printf("✓ Pulled version %d (%d secrets imported)\n",
+					pulled.Version, pulled.Secrets)
 			}
 
-			if envsCreated > 0 {
-				green.Printf("✓ Pulled version %d from cloud (%d secrets, %d new environments)\n",
-					pullResp.Version, secretsImported, envsCreated)
-			} else {
-				green.Printf("✓ Pulled version %d from cloud (%d secrets imported)\n",
-					pullResp.Version, secretsImported)
+			if pulled.Replayed > 0 && !quiet {
+				cyan.Printf("  Re-applied %d local changes made since the last sync\n", pulled.Replayed)
 			}
 		} else {
 			if !quiet {
@@ -205,88 +191,486 @@ func runSync(cmd *cobra.Command, args []string) error {
 	// PUSH to cloud
 	if doPush {
 		if !quiet {
-			cyan.Println("↑ Pushing local changes...")
+			cyan.Printf("↑ Pushing local changes to %s...\n", remote.Name())
 		}
 
-		// Get all environments and secrets
-		environments, err := db.ListEnvironments(ctx.ProjectID)
+		pushed, err := pushProject(cmd.Context(), remote, db, cryptoSvc, ctx.ProjectID)
 		if err != nil {
-			return fmt.Errorf("failed to list environments: %w", err)
+			if backend.IsUnavailable(err) {
+				return reportOffline(db, ctx.ProjectID, pulled, err)
+			}
+			return err
 		}
 
-		// Check if there's anything to push
-		if len(environments) == 0 {
+		if pushed == nil {
 			if !quiet {
 				yellow.Println("  No environments to sync")
 			}
 			return nil
 		}
 
-		// Build export data structure
-		exportData := make(map[string]map[string]string)
-		totalSecrets := 0
+		if !quiet && pushed.Secrets == 0 {
+			yellow.Println("  No secrets to sync")
+		}
+
+		green.Printf("✓ Pushed version %d (%d environments, %d secrets)\n",
+			pushed.Version, pushed.Environments, pushed.Secrets)
+
+		if pushed.Flushed > 0 && !quiet {
+			cyan.Printf("  Included %d queued changes\n", pushed.Flushed)
+		}
+	}
+
+	if !quiet {
+		fmt.Println()
+		green.Println("✓ Sync complete")
+
+		fmt.Println()
+		cyan.Println("Your secrets are encrypted end-to-end.")
+		cyan.Println("The server only stores encrypted blobs it cannot decrypt.")
+	}
+
+	return nil
+}
+
+// pullResult summarises a successful pull
+type pullResult struct {
+	Version             int
+	Secrets             int
+	EnvironmentsCreated []string
+	Replayed            int
+}
+
+// pullProject fetches the newest blob since the last known version, imports
+// it and then re-applies any queued local changes on top so that work done
+// offline is not overwritten. It returns nil if there was nothing new.
+func pullProject(ctx context.Context, remote *syncRemote, db *storage.DB, cryptoSvc *crypto.Service, projectID string) (*pullResult, error) {
+	meta, err := db.GetSyncMetadata(projectID, remote.Remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinceVersion *int
+	if meta != nil {
+		sinceVersion = &meta.Version
+	}
+
+	// Get latest blob from the backend
+	pullResp, err := remote.Pull(ctx, projectID, sinceVersion)
+	if err != nil {
+		return nil, apiError("failed to pull from "+remote.Name(), err)
+	}
+
+	if pullResp == nil {
+		return nil, nil
+	}
+
+	// Machine tokens limited to some environments only get those, each
+	// encrypted on its own
+	var importData map[string]map[string]string
+	if pullResp.Environments != nil {
+		importData = make(map[string]map[string]string)
+		for _, envBlob := range pullResp.Environments {
+			envData, err := decryptSnapshot(cryptoSvc, envBlob.EncryptedData, envBlob.Checksum)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", envBlob.Environment, err)
+			}
+			for envName, secrets := range envData {
+				importData[envName] = secrets
+			}
+		}
+	} else {
+		importData, err = decryptSnapshot(cryptoSvc, pullResp.EncryptedData, pullResp.Checksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The server already leaves out environments a machine token may not
+	// read; older servers don't, so check again
+	scope, err := remote.machineScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil {
+		if scope.ProjectID != projectID {
+			return nil, fmt.Errorf("ENVAULT_TOKEN is for project %s, not %s", scope.ProjectID, projectID)
+		}
+		for envName := range importData {
+			if !scope.Allows(envName) {
+				delete(importData, envName)
+			}
+		}
+	}
+
+	result := &pullResult{Version: pullResp.Version}
+
+	// Import secrets into local database
+	for envName, secrets := range importData {
+		// Get or create environment
+		env, err := db.GetEnvironment(projectID, envName)
+		if err != nil {
+			// Environment doesn't exist, create it
+			env, err = db.CreateEnvironment(projectID, envName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create environment %s: %w", envName, err)
+			}
+			result.EnvironmentsCreated = append(result.EnvironmentsCreated, envName)
+		}
 
-		for _, env := range environments {
-			secrets, err := db.ListSecrets(env.ID)
+		// Import secrets for this environment
+		for key, value := range secrets {
+			// Encrypt the value
+			encryptedValue, err := cryptoSvc.Encrypt(value)
 			if err != nil {
-				return fmt.Errorf("failed to list secrets for %s: %w", env.Name, err)
+				return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+			}
+
+			// Create/update secret (CreateSecret has upsert logic)
+			if _, err := db.CreateSecret(env.ID, key, encryptedValue, ""); err != nil {
+				return nil, fmt.Errorf("failed to import %s: %w", key, err)
 			}
 
-			envSecrets := make(map[string]string)
-			for _, secret := range secrets {
-				// Decrypt secret
-				value, err := cryptoSvc.Decrypt(secret.EncryptedValue)
+			result.Secrets++
+		}
+	}
+
+	// Local changes that have not been pushed yet win over the pulled data
+	replayed, err := replayOutbox(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	result.Replayed = replayed
+
+	if err := db.UpdateSyncMetadata(projectID, remote.Remote, pullResp.Version, pullResp.Checksum); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// replayOutbox re-applies queued local changes in the order they were made
+func replayOutbox(db *storage.DB, projectID string) (int, error) {
+	entries, err := db.ListOutbox(projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		switch entry.Operation {
+		case models.OutboxEnvironmentCreated:
+			if _, err := db.GetEnvironment(projectID, entry.Environment); err != nil {
+				if _, err := db.CreateEnvironment(projectID, entry.Environment); err != nil {
+					return 0, fmt.Errorf("failed to re-apply %s: %w", entry.Operation, err)
+				}
+			}
+
+		case models.OutboxEnvironmentDeleted:
+			if env, err := db.GetEnvironment(projectID, entry.Environment); err == nil {
+				if err := db.DeleteEnvironment(env.ID); err != nil {
+					return 0, fmt.Errorf("failed to re-apply %s: %w", entry.Operation, err)
+				}
+			}
+
+		case models.OutboxSecretSet:
+			env, err := db.GetEnvironment(projectID, entry.Environment)
+			if err != nil {
+				env, err = db.CreateEnvironment(projectID, entry.Environment)
 				if err != nil {
-					return fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
+					return 0, fmt.Errorf("failed to re-apply %s: %w", entry.Operation, err)
 				}
-				envSecrets[secret.Key] = value
-				totalSecrets++
 			}
 
-			exportData[env.Name] = envSecrets
-		}
+			description := ""
+			if existing, err := db.GetSecret(env.ID, entry.Key); err == nil {
+				description = existing.Description
+			}
 
-		if !quiet && totalSecrets == 0 {
-			yellow.Println("  No secrets to sync")
+			if _, err := db.CreateSecret(env.ID, entry.Key, entry.EncryptedValue, description); err != nil {
+				return 0, fmt.Errorf("failed to re-apply %s for %s: %w", entry.Operation, entry.Key, err)
+			}
+
+		case models.OutboxSecretDeleted:
+			env, err := db.GetEnvironment(projectID, entry.Environment)
+			if err != nil {
+				continue
+			}
+			if secret, err := db.GetSecret(env.ID, entry.Key); err == nil {
+				if err := db.DeleteSecret(secret.ID); err != nil {
+					return 0, fmt.Errorf("failed to re-apply %s for %s: %w", entry.Operation, entry.Key, err)
+				}
+			}
 		}
+	}
+
+	return len(entries), nil
+}
+
+// pushResult summarises a successful push
+type pushResult struct {
+	Version      int
+	Environments int
+	Secrets      int
+	Flushed      int
+}
 
-		// Convert to JSON
-		jsonBytes, err := json.Marshal(exportData)
+// checkPushAllowed returns why pending queued changes can't be pushed to
+// remote, or nil if they can
+func checkPushAllowed(ctx context.Context, remote *syncRemote, project *utils.ProjectContext, pending int) error {
+	if remote.ReadOnly() {
+		return errReadOnlyToken
+	}
+
+	action := fmt.Sprintf("pushing %d queued changes", pending)
+	return requireRemoteRole(ctx, project, remote.Remote, project.Remotes[remote.Remote], roleDeveloper, action)
+}
+
+// pushProject uploads a snapshot of all local environments and clears the
+// queued changes it included. It returns nil if there is nothing to push.
+func pushProject(ctx context.Context, remote *syncRemote, db *storage.DB, cryptoSvc *crypto.Service, projectID string) (*pushResult, error) {
+	if remote.ReadOnly() {
+		return nil, errReadOnlyToken
+	}
+
+	// Remember which queued changes this snapshot covers
+	pending, err := db.ListOutbox(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get all environments and secrets
+	environments, err := db.ListEnvironments(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+
+	// Check if there's anything to push
+	if len(environments) == 0 {
+		return nil, nil
+	}
+
+	project, err := db.GetProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build export data structure
+	exportData := make(map[string]map[string]string)
+	meta := api.PushMetadata{ProjectName: project.Name}
+	totalSecrets := 0
+
+	for _, env := range environments {
+		secrets, err := db.ListSecrets(env.ID)
 		if err != nil {
-			return fmt.Errorf("failed to serialize data: %w", err)
+			return nil, fmt.Errorf("failed to list secrets for %s: %w", env.Name, err)
+		}
+
+		envSecrets := make(map[string]string)
+		for _, secret := range secrets {
+			// Decrypt secret
+			value, err := cryptoSvc.Decrypt(secret.EncryptedValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", secret.Key, err)
+			}
+			envSecrets[secret.Key] = value
+			totalSecrets++
 		}
-		jsonData := string(jsonBytes)
 
-		// Encrypt the entire blob
-		encryptedBlob, err := cryptoSvc.Encrypt(jsonData)
+		exportData[env.Name] = envSecrets
+		meta.Environments = append(meta.Environments, env.Name)
+
+		// Each environment is also encrypted on its own, so that the server
+		// can give machine tokens only the environments they may read
+		envBlob, envChecksum, err := encryptSnapshot(cryptoSvc, map[string]map[string]string{env.Name: envSecrets})
 		if err != nil {
-			return fmt.Errorf("failed to encrypt blob: %w", err)
+			return nil, err
 		}
+		meta.EnvironmentBlobs = append(meta.EnvironmentBlobs, api.EnvironmentBlob{
+			Environment:   env.Name,
+			EncryptedData: envBlob,
+			Checksum:      envChecksum,
+		})
+	}
 
-		// Encode as base64
-		encodedBlob := base64.StdEncoding.EncodeToString(encryptedBlob)
+	encodedBlob, checksum, err := encryptSnapshot(cryptoSvc, exportData)
+	if err != nil {
+		return nil, err
+	}
 
-		// Calculate checksum
-		checksum := crypto.Hash(encodedBlob)
+	// Push to the backend
+	version, err := backend.PushSnapshot(ctx, remote.SyncBackend, projectID, encodedBlob, checksum, meta)
+	if err != nil {
+		return nil, apiError("failed to push to "+remote.Name(), err)
+	}
 
-		// Push to server
-		pushResp, err := client.PushEncryptedBlob(ctx.ProjectID, encodedBlob, checksum)
-		if err != nil {
-			return fmt.Errorf("failed to push to cloud: %w", err)
+	// The outbox tracks changes not yet on the default remote
+	if !remote.Default {
+		pending = nil
+	}
+
+	if len(pending) > 0 {
+		if err := db.ClearOutbox(projectID, pending[len(pending)-1].ID); err != nil {
+			return nil, err
 		}
+	}
 
-		green.Printf("✓ Pushed version %d to cloud (%d environments, %d secrets)\n",
-			pushResp.Version, len(environments), totalSecrets)
+	if err := db.UpdateSyncMetadata(projectID, remote.Remote, version, checksum); err != nil {
+		return nil, err
 	}
 
-	if !quiet {
-		fmt.Println()
-		green.Println("✓ Sync complete")
+	if err := db.SetProjectSyncEnabled(projectID, true); err != nil {
+		return nil, err
+	}
 
-		fmt.Println()
-		cyan.Println("Your secrets are encrypted end-to-end.")
-		cyan.Println("The server only stores encrypted blobs it cannot decrypt.")
+	return &pushResult{
+		Version:      version,
+		Environments: len(environments),
+		Secrets:      totalSecrets,
+		Flushed:      len(pending),
+	}, nil
+}
+
+// encryptSnapshot serializes, encrypts and base64 encodes secrets by
+// environment and returns the result with its checksum
+func encryptSnapshot(cryptoSvc *crypto.Service, data map[string]map[string]string) (string, string, error) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to serialize data: %w", err)
 	}
 
-	return nil
+	encrypted, err := cryptoSvc.Encrypt(string(jsonBytes))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt blob: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(encrypted)
+	return encoded, crypto.Hash(encoded), nil
+}
+
+// decryptSnapshot reverses encryptSnapshot after verifying the checksum
+func decryptSnapshot(cryptoSvc *crypto.Service, encoded, checksum string) (map[string]map[string]string, error) {
+	if crypto.Hash(encoded) != checksum {
+		return nil, fmt.Errorf("checksum mismatch: data may be corrupted")
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted data: %w", err)
+	}
+
+	decrypted, err := cryptoSvc.Decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob: %w", err)
+	}
+
+	var data map[string]map[string]string
+	if err := json.Unmarshal([]byte(decrypted), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse synced data: %w", err)
+	}
+
+	return data, nil
+}
+
+// reportOffline explains that the sync backend could not be reached and that
+// local changes stay queued until the next successful sync. pulled is what
+// an earlier pull of the same sync applied to the local vault, if anything.
+// It returns an ExitError with ExitOffline.
+func reportOffline(db *storage.DB, projectID string, pulled *pullResult, err error) error {
+	yellow := color.New(color.FgYellow)
+
+	pending, _ := db.CountOutbox(projectID)
+
+	yellow.Println("⚠ Could not reach the sync backend (working offline)")
+	if debug {
+		yellow.Printf("  %v\n", err)
+	}
+	if pulled != nil {
+		yellow.Printf("  Version %d was pulled and applied to your local vault before the connection failed\n", pulled.Version)
+	}
+	switch {
+	case pending > 0:
+		yellow.Printf("  %d local changes are queued in the outbox and will be pushed on the next sync\n", pending)
+	case quiet:
+	case pulled != nil:
+		fmt.Println("  Run 'envault sync' again when you're back online")
+	default:
+		fmt.Println("  Your local vault is unchanged. Run 'envault sync' again when you're back online")
+	}
+
+	return &ExitError{Code: ExitOffline}
+}
+
+// queueSyncChange records a local change in the sync outbox so it survives
+// until the next successful push. Local-only projects are not tracked.
+func queueSyncChange(db *storage.DB, projectID, operation, environment, key string, encryptedValue []byte) error {
+	project, err := db.GetProject(projectID)
+	if err != nil {
+		return err
+	}
+
+	if !project.SyncEnabled {
+		return nil
+	}
+
+	return db.EnqueueOutbox(projectID, operation, environment, key, encryptedValue)
+}
+
+// flushPendingChanges pushes changes that were queued while offline. It is
+// called opportunistically by networked commands and never fails them: if the
+// push does not succeed the changes simply stay queued.
+func flushPendingChanges(ctx context.Context, project *utils.ProjectContext) {
+	projectID := project.ProjectID
+
+	cfg, err := config.New()
+	if err != nil {
+		return
+	}
+
+	db, err := storage.New(cfg.DBPath)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	pending, err := db.CountOutbox(projectID)
+	if err != nil || pending == 0 {
+		return
+	}
+
+	cryptoSvc, err := crypto.New()
+	if err != nil {
+		return
+	}
+
+	remote, err := openSyncRemote(project, "")
+	if err != nil {
+		return
+	}
+
+	// Viewers' changes stay queued until they are allowed to push
+	if err := checkPushAllowed(ctx, remote, project, pending); err != nil {
+		if !quiet {
+			color.New(color.FgYellow).Printf("⚠ %v\n", err)
+		}
+		return
+	}
+
+	if _, err := pullProject(ctx, remote, db, cryptoSvc, projectID); err != nil {
+		if debug {
+			utils.Warn("Could not push queued changes: %v", err)
+		}
+		return
+	}
+
+	pushed, err := pushProject(ctx, remote, db, cryptoSvc, projectID)
+	if err != nil || pushed == nil {
+		if err != nil && debug {
+			utils.Warn("Could not push queued changes: %v", err)
+		}
+		return
+	}
+
+	if !quiet {
+		color.New(color.FgGreen).Printf("✓ Pushed %d queued changes (version %d)\n", pushed.Flushed, pushed.Version)
+	}
 }
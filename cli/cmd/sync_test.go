@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// captureOutput returns what f prints to stdout, in colour or not
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = w, w, true
+	defer func() { os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor }()
+
+	f()
+	w.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestReportOffline(t *testing.T) {
+	offline := errors.New("dial tcp: connection refused")
+
+	tests := []struct {
+		name    string
+		pulled  *pullResult
+		pending int
+		want    []string
+		notWant string
+	}{
+		{
+			name: "nothing pulled or queued",
+			want: []string{"Your local vault is unchanged"},
+		},
+		{
+			name:    "pulled before the push failed",
+			pulled:  &pullResult{Version: 7},
+			want:    []string{"Version 7 was pulled and applied to your local vault", "Run 'envault sync' again"},
+			notWant: "unchanged",
+		},
+		{
+			name:    "pulled with queued changes",
+			pulled:  &pullResult{Version: 7},
+			pending: 2,
+			want:    []string{"Version 7 was pulled and applied", "2 local changes are queued in the outbox"},
+			notWant: "unchanged",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _, projectID := openTestDB(t)
+			for i := 0; i < tt.pending; i++ {
+				if err := db.EnqueueOutbox(projectID, "set", "production", fmt.Sprintf("KEY_%d", i), []byte("x")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var err error
+			out := captureOutput(t, func() { err = reportOffline(db, projectID, tt.pulled, offline) })
+
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) || exitErr.Code != ExitOffline {
+				t.Errorf("got %v, want exit code %d", err, ExitOffline)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output %q does not contain %q", out, want)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(out, tt.notWant) {
+				t.Errorf("output %q contains %q", out, tt.notWant)
+			}
+		})
+	}
+}
@@ -1,12 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/dj-pearson/envault/internal/api"
-	"github.com/dj-pearson/envault/internal/auth"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
@@ -14,19 +14,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	teamInviteRole   string
+	teamInviteExpiry string
+)
+
 var teamCmd = &cobra.Command{
 	Use:   "team",
 	Short: "Manage team members",
 	Long: `Manage team members for your EnvVault projects.
 
 Subcommands:
-  list             List team members
-  invite EMAIL     Invite a team member
-  remove EMAIL     Remove a team member
+  list                  List team members
+  invite EMAIL          Invite someone to the project
+  invites               List pending invitations
+  revoke-invite EMAIL   Withdraw an invitation
+  accept [ID]           Accept an invitation sent to you
+  role EMAIL ROLE       Change a member's role
+  remove EMAIL          Remove a team member
+  leave                 Leave the project
+
+Roles:
+  viewer     Read and pull secrets
+  developer  Also set, delete and push secrets
+  admin      Also manage the team and machine tokens
 
 Examples:
   envault team list
-  envault team invite alice@company.com
+  envault team invite alice@company.com --role developer
+  envault team accept
+  envault team role alice@company.com admin
   envault team remove bob@company.com`,
 }
 
@@ -38,9 +55,68 @@ var teamListCmd = &cobra.Command{
 
 var teamInviteCmd = &cobra.Command{
 	Use:   "invite EMAIL",
-	Short: "Invite a team member",
+	Short: "Invite someone to the project",
+	Long: `Invite someone to the current project.
+
+The invitation is addressed to an email, so the invitee does not need an
+account yet. They join by running 'envault team accept' once logged in,
+before the invitation expires. Inviting the same email again replaces the
+earlier invitation.
+
+Examples:
+  envault team invite alice@company.com
+  envault team invite alice@company.com --role developer --expires 14d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTeamInvite,
+}
+
+var teamInvitesCmd = &cobra.Command{
+	Use:   "invites",
+	Short: "List pending invitations",
+	Args:  cobra.NoArgs,
+	RunE:  runTeamInvites,
+}
+
+var teamRevokeInviteCmd = &cobra.Command{
+	Use:   "revoke-invite EMAIL|ID",
+	Short: "Withdraw a pending invitation",
 	Args:  cobra.ExactArgs(1),
-	RunE:  runTeamInvite,
+	RunE:  runTeamRevokeInvite,
+}
+
+var teamAcceptCmd = &cobra.Command{
+	Use:   "accept [ID]",
+	Short: "Accept an invitation sent to you",
+	Long: `Accept an invitation sent to your account's email.
+
+Without an ID the only pending invitation is accepted, or you are asked to
+choose one. This works outside a project too, using the same server as
+'envault login'.
+
+Examples:
+  envault team accept
+  envault team accept 7d1c...`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTeamAccept,
+}
+
+var teamRoleCmd = &cobra.Command{
+	Use:   "role EMAIL ROLE",
+	Short: "Change a member's role",
+	Long: `Change a team member's role to viewer, developer or admin.
+
+Examples:
+  envault team role alice@company.com admin
+  envault team role bob@company.com viewer`,
+	Args: cobra.ExactArgs(2),
+	RunE: runTeamRole,
+}
+
+var teamLeaveCmd = &cobra.Command{
+	Use:   "leave",
+	Short: "Leave the current project",
+	Args:  cobra.NoArgs,
+	RunE:  runTeamLeave,
 }
 
 var teamRemoveCmd = &cobra.Command{
@@ -55,45 +131,49 @@ func init() {
 	teamCmd.AddCommand(teamListCmd)
 	teamCmd.AddCommand(teamInviteCmd)
 	teamCmd.AddCommand(teamRemoveCmd)
+	teamCmd.AddCommand(teamInvitesCmd)
+	teamCmd.AddCommand(teamRevokeInviteCmd)
+	teamCmd.AddCommand(teamAcceptCmd)
+	teamCmd.AddCommand(teamRoleCmd)
+	teamCmd.AddCommand(teamLeaveCmd)
+
+	teamInviteCmd.Flags().StringVar(&teamInviteRole, "role", "", "Role to invite as: viewer, developer or admin (prompted if not given)")
+	teamInviteCmd.Flags().StringVar(&teamInviteExpiry, "expires", "7d", "How long the invitation stays valid (at most 30d)")
 }
 
 func runTeamList(cmd *cobra.Command, args []string) error {
 	cyan := color.New(color.FgCyan)
 	green := color.New(color.FgGreen)
 
-	// Check authentication
-	if !auth.IsLoggedIn() {
-		return fmt.Errorf("Error: Not logged in\nRun 'envault login' first")
-	}
-
-	session, err := auth.GetCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get user session: %w", err)
-	}
-
 	// Load project context
 	ctx, err := utils.LoadProjectContext()
 	if err != nil {
 		return fmt.Errorf("Error: %v", err)
 	}
 
-	// Get API client
-	apiKey := os.Getenv("ENVAULT_API_KEY")
-	baseURL := os.Getenv("ENVAULT_API_URL")
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
-	if apiKey == "" {
-		return fmt.Errorf("Error: ENVAULT_API_KEY not set")
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	client := api.New(baseURL, apiKey)
-	client.SetAuthToken(session.AccessToken)
+	// Push any changes queued while offline
+	flushPendingChanges(cmd.Context(), ctx)
 
 	// Fetch team members
-	members, err := client.ListTeamMembers(ctx.ProjectID)
+	members, err := client.ListTeamMembers(cmd.Context(), ctx.ProjectID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch team members: %w", err)
+		return apiError("failed to fetch team members", err)
 	}
 
+	// Refresh the role used by the local permission checks
+	cacheTeamRole(ctx, members)
+
 	cyan.Printf("Team members for project: %s\n\n", ctx.ProjectName)
 
 	if len(members) == 0 {
@@ -132,14 +212,18 @@ func runTeamInvite(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid email address: %s", email)
 	}
 
-	// Check authentication
-	if !auth.IsLoggedIn() {
-		return fmt.Errorf("Error: Not logged in\nRun 'envault login' first")
+	ttl, err := utils.ParseTTL(teamInviteExpiry)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+	if ttl == 0 {
+		return fmt.Errorf("Error: invitations must expire (use e.g. --expires 7d)")
 	}
 
-	session, err := auth.GetCurrentUser()
-	if err != nil {
-		return fmt.Errorf("failed to get user session: %w", err)
+	if teamInviteRole != "" {
+		if err := validateRole(teamInviteRole); err != nil {
+			return fmt.Errorf("Error: %v", err)
+		}
 	}
 
 	// Load project context
@@ -148,83 +232,455 @@ func runTeamInvite(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins manage the team
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "inviting team members"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Select role
-	rolePrompt := promptui.Select{
-		Label: "Select role for " + email,
-		Items: []string{"viewer", "developer", "admin"},
+	role := teamInviteRole
+	if role == "" {
+		rolePrompt := promptui.Select{
+			Label: "Select role for " + email,
+			Items: []string{roleViewer, roleDeveloper, roleAdmin},
+		}
+
+		_, role, err = rolePrompt.Run()
+		if err != nil {
+			return fmt.Errorf("prompt cancelled")
+		}
+
+		// Confirm invitation
+		if !quiet {
+			yellow.Printf("\nInvite %s as %s to %s?\n", email, role, ctx.ProjectName)
+
+			confirmPrompt := promptui.Prompt{
+				Label:     "Continue",
+				IsConfirm: true,
+			}
+
+			result, err := confirmPrompt.Run()
+			if err != nil || strings.ToLower(result) != "y" {
+				fmt.Println("Invitation cancelled")
+				return nil
+			}
+		}
 	}
 
-	_, role, err := rolePrompt.Run()
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
 	if err != nil {
-		return fmt.Errorf("prompt cancelled")
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	// Confirm invitation
+	// Push any changes queued while offline
+	flushPendingChanges(cmd.Context(), ctx)
+
+	// Send invitation
+	invitation, err := client.CreateInvitation(cmd.Context(), ctx.ProjectID, ctx.ProjectName, email, role, ttl)
+	if err != nil {
+		if api.IsConflict(err) {
+			return fmt.Errorf("Error: %s is already a member of %s\nChange their role with 'envault team role %s ROLE'", email, ctx.ProjectName, email)
+		}
+		return apiError("failed to invite team member", err)
+	}
+
+	logAudit(ctx.ProjectID, "invitation_created", map[string]interface{}{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"expires_at":    invitation.ExpiresAt,
+	})
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(invitation)
+	}
+
+	green.Printf("\n✓ Invited %s as %s\n", email, invitation.Role)
+
 	if !quiet {
-		yellow.Printf("\nInvite %s as %s to %s?\n", email, role, ctx.ProjectName)
+		fmt.Println()
+		fmt.Printf("The invitation expires %s. They accept it with:\n", invitation.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		green.Println("  envault team accept")
+	}
+
+	return nil
+}
+
+func runTeamInvites(cmd *cobra.Command, args []string) error {
+	cyan := color.New(color.FgCyan)
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins can see invitations
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "listing invitations"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	invitations, err := client.ListInvitations(cmd.Context(), ctx.ProjectID)
+	if err != nil {
+		return apiError("failed to list invitations", err)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(invitations)
+	}
+
+	if len(invitations) == 0 {
+		fmt.Println("No pending invitations")
+		return nil
+	}
+
+	cyan.Printf("Pending invitations for %s:\n\n", ctx.ProjectName)
 
-		confirmPrompt := promptui.Prompt{
-			Label:     "Continue",
-			IsConfirm: true,
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Email", "Role", "Invited By", "Expires", "ID"})
+	table.SetBorder(false)
+
+	for _, invitation := range invitations {
+		expires := invitation.ExpiresAt.Local().Format("2006-01-02 15:04")
+		if invitation.Expired() {
+			expires += " (expired)"
 		}
 
-		result, err := confirmPrompt.Run()
-		if err != nil || strings.ToLower(result) != "y" {
-			fmt.Println("Invitation cancelled")
-			return nil
+		invitedBy := invitation.InvitedByEmail
+		if invitedBy == "" {
+			invitedBy = invitation.InvitedBy
 		}
+
+		table.Append([]string{invitation.Email, invitation.Role, invitedBy, expires, invitation.ID})
+	}
+	table.Render()
+
+	return nil
+}
+
+func runTeamRevokeInvite(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	target := args[0]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	// Get API client
-	apiKey := os.Getenv("ENVAULT_API_KEY")
-	baseURL := os.Getenv("ENVAULT_API_URL")
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
-	if apiKey == "" {
-		return fmt.Errorf("Error: ENVAULT_API_KEY not set")
+	// Only admins manage the team
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "revoking invitations"); err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	client := api.New(baseURL, apiKey)
-	client.SetAuthToken(session.AccessToken)
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
-	// Send invitation
-	memberID, err := client.InviteTeamMember(ctx.ProjectID, email, role)
+	// Find the invitation by email or ID
+	invitations, err := client.ListInvitations(cmd.Context(), ctx.ProjectID)
+	if err != nil {
+		return apiError("failed to list invitations", err)
+	}
+
+	var invitation *api.Invitation
+	for i := range invitations {
+		if invitations[i].ID == target || strings.EqualFold(invitations[i].Email, target) {
+			invitation = &invitations[i]
+			break
+		}
+	}
+	if invitation == nil {
+		return fmt.Errorf("Error: no pending invitation for %s (see 'envault team invites')", target)
+	}
+
+	revoked, err := client.RevokeInvitation(cmd.Context(), ctx.ProjectID, invitation.ID)
+	if err != nil {
+		return apiError("failed to revoke invitation", err)
+	}
+	if !revoked {
+		return fmt.Errorf("Error: the invitation for %s was already accepted or revoked", invitation.Email)
+	}
+
+	logAudit(ctx.ProjectID, "invitation_revoked", map[string]interface{}{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+	})
+
+	green.Printf("✓ Revoked the invitation for %s\n", invitation.Email)
+
+	return nil
+}
+
+func runTeamAccept(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	// Invitations are accepted before the project exists locally, so this
+	// works outside a project
+	client, err := newAccountAPIClient()
 	if err != nil {
-		return fmt.Errorf("failed to invite team member: %w", err)
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	green.Printf("\n✓ Invitation sent to %s\n", email)
+	var invitationID string
+	if len(args) > 0 {
+		invitationID = args[0]
+	} else {
+		invitations, err := client.ListMyInvitations(cmd.Context())
+		if err != nil {
+			return apiError("failed to list invitations", err)
+		}
+
+		switch {
+		case len(invitations) == 0:
+			fmt.Println("No pending invitations")
+			return nil
+
+		case len(invitations) == 1:
+			invitationID = invitations[0].ID
+
+		case quiet || jsonOutput:
+			return fmt.Errorf("Error: %d pending invitations; pass the ID of the one to accept", len(invitations))
+
+		default:
+			items := make([]string, len(invitations))
+			for i, invitation := range invitations {
+				items[i] = fmt.Sprintf("%s as %s (from %s, expires %s)",
+					invitationProjectName(&invitation), invitation.Role, invitation.InvitedByEmail,
+					invitation.ExpiresAt.Local().Format("2006-01-02"))
+			}
+
+			prompt := promptui.Select{
+				Label: "Select invitation to accept",
+				Items: items,
+			}
+
+			index, _, err := prompt.Run()
+			if err != nil {
+				return fmt.Errorf("prompt cancelled")
+			}
+			invitationID = invitations[index].ID
+		}
+	}
+
+	membership, err := client.AcceptInvitation(cmd.Context(), invitationID)
+	if err != nil {
+		return apiError("failed to accept invitation", err)
+	}
+
+	logAudit(membership.ProjectID, "invitation_accepted", map[string]interface{}{
+		"invitation_id": invitationID,
+		"role":          membership.Role,
+	})
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(membership)
+	}
+
+	name := membership.ProjectName
+	if name == "" {
+		name = membership.ProjectID
+	}
+	green.Printf("✓ Joined %s as %s\n", name, membership.Role)
 
 	if !quiet {
-		fmt.Println()
-		yellow.Println("Note: The user must have an EnvVault account to accept the invitation")
-		yellow.Printf("Member ID: %s\n", memberID)
+		fmt.Printf("\nProject ID: %s\n", membership.ProjectID)
+		fmt.Printf("Run 'envault clone %s' to pull its secrets into a new directory\n", membership.ProjectID)
 	}
 
 	return nil
 }
 
-func runTeamRemove(cmd *cobra.Command, args []string) error {
+func runTeamRole(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	email, role := args[0], args[1]
+
+	if err := validateRole(role); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins manage the team
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "changing roles"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Find the member by email
+	members, err := client.ListTeamMembers(cmd.Context(), ctx.ProjectID)
+	if err != nil {
+		return apiError("failed to fetch team members", err)
+	}
+
+	var member *api.TeamMember
+	for i := range members {
+		if strings.EqualFold(members[i].Email, email) {
+			member = &members[i]
+			break
+		}
+	}
+	if member == nil {
+		return fmt.Errorf("Error: %s is not a member of %s (see 'envault team list')", email, ctx.ProjectName)
+	}
+
+	if member.Role == role {
+		fmt.Printf("%s is already %s\n", member.Email, role)
+		return nil
+	}
+
+	changed, err := client.UpdateTeamMemberRole(cmd.Context(), ctx.ProjectID, member.UserID, role)
+	if err != nil {
+		return apiError("failed to change role", err)
+	}
+	if !changed {
+		return fmt.Errorf("Error: %s is no longer a member of %s", member.Email, ctx.ProjectName)
+	}
+
+	logAudit(ctx.ProjectID, "member_role_changed", map[string]interface{}{
+		"email":    member.Email,
+		"user_id":  member.UserID,
+		"old_role": member.Role,
+		"role":     role,
+	})
+
+	// Keep the cached role current when changing your own
+	member.Role = role
+	cacheTeamRole(ctx, members)
+
+	green.Printf("✓ %s is now %s\n", member.Email, role)
+
+	return nil
+}
+
+func runTeamLeave(cmd *cobra.Command, args []string) error {
 	green := color.New(color.FgGreen)
 	yellow := color.New(color.FgYellow)
 
-	email := args[0]
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
 
-	// Check authentication
-	if !auth.IsLoggedIn() {
-		return fmt.Errorf("Error: Not logged in\nRun 'envault login' first")
+	// Confirm leaving
+	if !quiet {
+		yellow.Printf("\n⚠ Leave %s?\n", ctx.ProjectName)
+		yellow.Println("⚠ You will no longer be able to sync its secrets until you are invited again")
+
+		if !utils.ConfirmDangerousAction("Leave the project") {
+			fmt.Println("Cancelled")
+			return nil
+		}
 	}
 
-	session, err := auth.GetCurrentUser()
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get user session: %w", err)
+		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Push any changes queued while offline, while we still can
+	flushPendingChanges(cmd.Context(), ctx)
+
+	left, err := client.LeaveProject(cmd.Context(), ctx.ProjectID)
+	if err != nil {
+		return apiError("failed to leave project", err)
+	}
+	if !left {
+		return fmt.Errorf("Error: you are not a member of %s", ctx.ProjectName)
+	}
+
+	// Forget the cached role
+	cacheTeamRole(ctx, nil)
+
+	logAudit(ctx.ProjectID, "member_left", map[string]interface{}{
+		"project": ctx.ProjectName,
+	})
+
+	green.Printf("\n✓ Left %s\n", ctx.ProjectName)
+
+	if !quiet {
+		fmt.Println()
+		yellow.Println("Secrets already on this machine stay in your local vault")
+	}
+
+	return nil
+}
+
+func runTeamRemove(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	email := args[0]
+
 	// Load project context
 	ctx, err := utils.LoadProjectContext()
 	if err != nil {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check authentication with the project's account
+	if err := requireLogin(ctx); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins manage the team
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "removing team members"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Confirm removal
 	if !quiet {
 		yellow.Printf("\n⚠ Remove %s from %s?\n", email, ctx.ProjectName)
@@ -236,33 +692,42 @@ func runTeamRemove(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Get API client
-	apiKey := os.Getenv("ENVAULT_API_KEY")
-	baseURL := os.Getenv("ENVAULT_API_URL")
-
-	if apiKey == "" {
-		return fmt.Errorf("Error: ENVAULT_API_KEY not set")
+	// Get API client for the project's default remote
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
 	}
 
-	client := api.New(baseURL, apiKey)
-	client.SetAuthToken(session.AccessToken)
+	// Push any changes queued while offline
+	flushPendingChanges(cmd.Context(), ctx)
 
 	// Get user ID from email
-	userID, err := client.GetUserByEmail(email)
+	userID, err := client.GetUserByEmail(cmd.Context(), email)
 	if err != nil {
-		return fmt.Errorf("failed to find user with email %s: %w", email, err)
+		if api.IsUserNotFound(err) {
+			return fmt.Errorf("no EnvVault account found for %s", email)
+		}
+		return apiError(fmt.Sprintf("failed to find user with email %s", email), err)
+	}
+	if userID == "" {
+		return fmt.Errorf("no EnvVault account found for %s", email)
 	}
 
 	// Remove team member
-	success, err := client.RemoveTeamMember(ctx.ProjectID, userID)
+	success, err := client.RemoveTeamMember(cmd.Context(), ctx.ProjectID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to remove team member: %w", err)
+		return apiError("failed to remove team member", err)
 	}
 
 	if !success {
 		return fmt.Errorf("failed to remove team member (operation returned false)")
 	}
 
+	logAudit(ctx.ProjectID, "member_removed", map[string]interface{}{
+		"email":   email,
+		"user_id": userID,
+	})
+
 	green.Printf("\n✓ Removed %s from team\n", email)
 
 	if !quiet {
@@ -272,3 +737,11 @@ func runTeamRemove(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// invitationProjectName names an invitation's project
+func invitationProjectName(invitation *api.Invitation) string {
+	if invitation.ProjectName != "" {
+		return invitation.ProjectName
+	}
+	return invitation.ProjectID
+}
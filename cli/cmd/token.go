@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenEnvironments []string
+	tokenExpiry       string
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage machine tokens for CI",
+	Long: `Manage machine tokens for CI pipelines and other automation.
+
+A machine token can only read one project, optionally limited to some of
+its environments, and always expires. Unlike a personal token it cannot
+push changes or manage the team. Only project admins can manage them.
+
+Use a token by setting ENVAULT_TOKEN; nothing is written to disk:
+  ENVAULT_TOKEN=envm_... envault sync
+  envault run --env production -- ./deploy.sh
+
+Subcommands:
+  create NAME  Issue a token
+  list         List the project's tokens
+  revoke ID    Revoke a token
+
+Examples:
+  envault token create github-actions --env production --expires 30d
+  envault token list
+  envault token revoke 3f6c...`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Issue a read-only machine token",
+	Long: `Issue a read-only machine token for the current project.
+
+The token is printed once and cannot be recovered. Without --env it can
+read every environment. With --env the server only ever sends it those
+environments. This needs a push from this version of the CLI or newer,
+since older pushes can't be split by environment.
+
+Examples:
+  envault token create github-actions --env production
+  envault token create staging-deploy --env staging --env preview --expires 7d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokenCreate,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the project's machine tokens",
+	Args:  cobra.NoArgs,
+	RunE:  runTokenList,
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke ID",
+	Short: "Revoke a machine token",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTokenRevoke,
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().StringSliceVar(&tokenEnvironments, "env", nil, "Environment the token can read (repeatable; default: all)")
+	tokenCreateCmd.Flags().StringVar(&tokenExpiry, "expires", "90d", "Token lifetime, e.g. 30d or 12h (at most 365d)")
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	name := args[0]
+
+	ttl, err := utils.ParseTTL(tokenExpiry)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+	if ttl == 0 {
+		return fmt.Errorf("Error: machine tokens must expire (use e.g. --expires 90d)")
+	}
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins manage machine tokens
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "creating machine tokens"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Warn about environments that don't exist locally; they may still exist
+	// for teammates
+	if db, err := openDB(); err == nil {
+		for _, env := range tokenEnvironments {
+			if _, err := db.GetEnvironment(ctx.ProjectID, env); err != nil {
+				yellow.Printf("Warning: environment %q does not exist in this project\n", env)
+			}
+		}
+		db.Close()
+	}
+
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	token, err := client.CreateMachineToken(cmd.Context(), ctx.ProjectID, name, tokenEnvironments, ttl)
+	if err != nil {
+		return apiError("failed to create machine token", err)
+	}
+
+	logAudit(ctx.ProjectID, "machine_token_created", map[string]interface{}{
+		"token_id":     token.ID,
+		"name":         token.Name,
+		"environments": tokenScopeText(token),
+	})
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(token)
+	}
+
+	green.Printf("✓ Created machine token %q for %s\n\n", token.Name, ctx.ProjectName)
+	fmt.Printf("  %s\n\n", token.Token)
+	fmt.Printf("Environments: %s (read-only)\n", tokenScopeText(token))
+	if token.ExpiresAt != nil {
+		fmt.Printf("Expires:      %s\n", token.ExpiresAt.Local().Format("2006-01-02"))
+	}
+	fmt.Println()
+	fmt.Println("Save this token securely - it will not be shown again.")
+	fmt.Println("Use it by setting ENVAULT_TOKEN in your CI secrets.")
+
+	return nil
+}
+
+func runTokenList(cmd *cobra.Command, args []string) error {
+	cyan := color.New(color.FgCyan)
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins can see machine tokens
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "listing machine tokens"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	tokens, err := client.ListMachineTokens(cmd.Context(), ctx.ProjectID)
+	if err != nil {
+		return apiError("failed to list machine tokens", err)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tokens)
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("No machine tokens")
+		if !quiet {
+			fmt.Println("\nCreate one with: envault token create NAME --env ENV")
+		}
+		return nil
+	}
+
+	cyan.Printf("Machine tokens for %s:\n\n", ctx.ProjectName)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Name", "Environments", "Expires", "Last Used"})
+	table.SetBorder(false)
+
+	for _, token := range tokens {
+		expires := "never"
+		if token.ExpiresAt != nil {
+			expires = token.ExpiresAt.Local().Format("2006-01-02")
+			if token.ExpiresAt.Before(time.Now()) {
+				expires += " (expired)"
+			}
+		}
+
+		lastUsed := "never"
+		if token.LastUsedAt != nil {
+			lastUsed = token.LastUsedAt.Local().Format("2006-01-02 15:04")
+		}
+
+		table.Append([]string{token.ID, token.Name, tokenScopeText(&token), expires, lastUsed})
+	}
+	table.Render()
+
+	return nil
+}
+
+func runTokenRevoke(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+
+	tokenID := args[0]
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Only admins manage machine tokens
+	if err := requireProjectRole(cmd.Context(), ctx, roleAdmin, "revoking machine tokens"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	client, err := newAPIClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	revoked, err := client.RevokeMachineToken(cmd.Context(), ctx.ProjectID, tokenID)
+	if err != nil {
+		return apiError("failed to revoke machine token", err)
+	}
+
+	if !revoked {
+		return fmt.Errorf("Error: machine token %s not found (see 'envault token list')", tokenID)
+	}
+
+	logAudit(ctx.ProjectID, "machine_token_revoked", map[string]interface{}{
+		"token_id": tokenID,
+	})
+
+	green.Printf("✓ Revoked machine token %s\n", tokenID)
+
+	return nil
+}
+
+// tokenScopeText describes the environments a machine token can read
+func tokenScopeText(token *api.MachineToken) string {
+	if len(token.Environments) == 0 {
+		return "all"
+	}
+	return strings.Join(token.Environments, ", ")
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/models"
 	"github.com/dj-pearson/envault/internal/storage"
 	"github.com/dj-pearson/envault/internal/utils"
 	"github.com/fatih/color"
@@ -54,6 +55,11 @@ func runUnset(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error: %v", err)
 	}
 
+	// Check the user's role before changing anything
+	if err := requireProjectRole(cmd.Context(), ctx, roleDeveloper, "deleting secrets"); err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
 	// Initialize services
 	cfg, err := config.New()
 	if err != nil {
@@ -123,6 +129,11 @@ func runUnset(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to delete from %s: %w", envName, err)
 		}
 
+		// Queue the change for the next sync
+		if err := queueSyncChange(db, ctx.ProjectID, models.OutboxSecretDeleted, envName, key, nil); err != nil {
+			yellow.Printf("Warning: Failed to queue change for sync: %v\n", err)
+		}
+
 		// Create audit log
 		metadata := fmt.Sprintf(`{"key":"%s","environment":"%s"}`, key, envName)
 		if err := db.CreateAuditLog(ctx.ProjectID, "secret_deleted", metadata); err != nil {
@@ -0,0 +1,591 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/dj-pearson/envault/internal/storage"
+	"github.com/dj-pearson/envault/internal/supervisor"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	upFile string
+	upEnv  []string
+)
+
+// upFiles are looked for in order when --file is not given
+var upFiles = []string{"envault.yml", "envault.yaml", "Procfile"}
+
+// processNamePattern matches the process names accepted in Procfiles and
+// envault.yml
+var processNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// upColors tell the processes' output apart
+var upColors = []color.Attribute{
+	color.FgCyan, color.FgYellow, color.FgGreen, color.FgMagenta, color.FgBlue, color.FgRed,
+}
+
+var upCmd = &cobra.Command{
+	Use:   "up [process...]",
+	Short: "Run the processes of a Procfile or envault.yml with their secrets",
+	Long: `Start several processes at once, each with its own environment.
+
+The processes are read from envault.yml, envault.yaml or Procfile in the
+current directory, or from --file. Each one gets its secrets the same way
+'envault run' would, so every process can name its environments, layers
+and key filters. Output is prefixed with the process name. Signals are
+passed on to every process, and when one process exits the others are
+stopped and envault exits with its exit code.
+
+Name processes to start only those.
+
+In a Procfile, 'envault run' flags go between the name and the command:
+
+  web: --env base --env development --only 'DB_*,API_*' -- npm start
+  worker: -e production --redact python worker.py
+
+envault.yml describes the same thing in YAML:
+
+  env: development            # Default for processes that don't set one
+  processes:
+    web:
+      command: npm start
+      env: [base, development]
+      only: ["DB_*", "API_*"]
+      set: {PORT: "3000"}
+    worker:
+      command: python worker.py
+      dir: ./worker
+      env: production
+      exclude: [DB_ADMIN_PASSWORD]
+      redact: true
+      preserve_env: true
+      file_secrets: {GOOGLE_APPLICATION_CREDENTIALS: GCP_SA_JSON}
+      stop_signal: SIGINT
+      grace_period: 5s
+
+Commands run through the shell (sh -c, or cmd /C on Windows). --exec and
+--watch are not available here.
+
+Examples:
+  envault up
+  envault up web
+  envault up --file Procfile.dev --env staging`,
+	RunE: runUp,
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd)
+
+	upCmd.Flags().StringVarP(&upFile, "file", "f", "", "Procfile or YAML file to read (default envault.yml or Procfile)")
+	upCmd.Flags().StringSliceVarP(&upEnv, "env", "e", nil, "Environment for processes that don't name one (default development, repeat to layer)")
+}
+
+// upProcess is one process to start
+type upProcess struct {
+	Name    string
+	Command string
+	Dir     string
+	Options *runOptions
+}
+
+func runUp(cmd *cobra.Command, args []string) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	// Load project context
+	ctx, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	path, err := findUpFile(upFile)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	processes, err := loadUpFile(path, upEnv)
+	if err != nil {
+		return fmt.Errorf("Error: %s: %v", path, err)
+	}
+
+	processes, err = selectProcesses(processes, args)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Initialize services
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	db, err := storage.New(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	cryptoSvc, err := crypto.New()
+	if err != nil {
+		return fmt.Errorf("failed to initialize crypto: %w", err)
+	}
+
+	shellPath, shellArgs, err := upShell()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	// Prefixes are padded to the longest name
+	width := 0
+	for _, process := range processes {
+		if len(process.Name) > width {
+			width = len(process.Name)
+		}
+	}
+
+	// Decrypt every process's environment before starting anything
+	envs := make([]*runEnv, len(processes))
+	production := false
+	for i, process := range processes {
+		envs[i], err = buildRunEnv(db, cryptoSvc, ctx.ProjectID, process.Options)
+		if err != nil {
+			return fmt.Errorf("%s: %w", process.Name, err)
+		}
+		production = production || process.Options.hasEnvironment("production")
+
+		if debug {
+			color.New(color.FgCyan).Printf("✓ Loaded %d environment variables for %s from %s\n", len(envs[i].Vault), process.Name, strings.Join(process.Options.Environments, " + "))
+		}
+	}
+
+	// SECURITY SAFEGUARD: Warn if running with production env
+	if production && !quiet {
+		yellow.Printf("⚠ Running with production environment variables\n")
+	}
+
+	// Start everything, backing out if a process cannot be started
+	children := make([]*supervisor.Process, 0, len(processes))
+	for i, process := range processes {
+		if !quiet {
+			green.Printf("✓ Starting %s: %s\n", process.Name, process.Command)
+		}
+
+		child, err := newRunChild(shellPath, append(shellArgs, process.Command), envs[i], process.Options)
+		if err == nil {
+			child.Cmd.Stdin = nil
+			child.Cmd.Dir = process.Dir
+
+			prefix := color.New(upColors[i%len(upColors)]).Sprintf("%-*s | ", width, process.Name)
+			child.FilterOutput(supervisor.PrefixLines(prefix))
+
+			err = child.Start()
+		}
+		if err != nil {
+			stopProcesses(children, processes)
+			return fmt.Errorf("failed to start %s: %w", process.Name, err)
+		}
+
+		children = append(children, child)
+	}
+
+	stopForwarding := supervisor.Forward(children...)
+	defer stopForwarding()
+
+	// Wait for the first process to exit
+	exited := make(chan int, len(children))
+	for i, child := range children {
+		go func(i int, child *supervisor.Process) {
+			<-child.Done()
+			exited <- i
+		}(i, child)
+	}
+
+	first := <-exited
+	code, err := children[first].Wait()
+
+	if !quiet && len(children) > 1 {
+		yellow.Fprintf(os.Stderr, "%s exited with code %d, stopping the other processes\n", processes[first].Name, code)
+	}
+
+	stopProcesses(children, processes)
+
+	return runResult(code, err)
+}
+
+// stopProcesses stops the processes that are still running, in parallel,
+// and waits for all of them
+func stopProcesses(children []*supervisor.Process, processes []upProcess) {
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func(child *supervisor.Process, opts *runOptions) {
+			defer wg.Done()
+			child.Stop(opts.StopSignal, opts.GracePeriod)
+			child.Wait()
+		}(child, processes[i].Options)
+	}
+	wg.Wait()
+}
+
+// upShell returns the shell that runs the processes' commands, and the
+// arguments that come before the command
+func upShell() (string, []string, error) {
+	if runtime.GOOS == "windows" {
+		path, err := exec.LookPath("cmd")
+		if err != nil {
+			return "", nil, fmt.Errorf("cmd.exe not found")
+		}
+		return path, []string{"cmd", "/C"}, nil
+	}
+
+	path, err := exec.LookPath("sh")
+	if err != nil {
+		return "", nil, fmt.Errorf("sh not found in PATH")
+	}
+	return path, []string{"sh", "-c"}, nil
+}
+
+// findUpFile returns the process file to read
+func findUpFile(path string) (string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		return path, nil
+	}
+
+	for _, name := range upFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %s found (pass one with --file)", strings.Join(upFiles, ", "))
+}
+
+// selectProcesses keeps the named processes, or all of them if none are
+// named
+func selectProcesses(processes []upProcess, names []string) ([]upProcess, error) {
+	if len(names) == 0 {
+		return processes, nil
+	}
+
+	byName := make(map[string]upProcess)
+	for _, process := range processes {
+		byName[process.Name] = process
+	}
+
+	selected := make([]upProcess, 0, len(names))
+	for _, name := range names {
+		process, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("no process named %q", name)
+		}
+		selected = append(selected, process)
+	}
+
+	return selected, nil
+}
+
+// loadUpFile reads a Procfile, or envault.yml if the file name ends in .yml
+// or .yaml. defaultEnvs are used for processes that don't name an
+// environment.
+func loadUpFile(path string, defaultEnvs []string) ([]upProcess, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []upProcess
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		processes, err = parseUpYAML(data, defaultEnvs)
+	default:
+		processes, err = parseProcfile(data, defaultEnvs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("no processes defined")
+	}
+
+	return processes, nil
+}
+
+// parseProcfile parses "name: [run flags] command" lines
+func parseProcfile(data []byte, defaultEnvs []string) ([]upProcess, error) {
+	var processes []upProcess
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, rest, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !processNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: expected 'name: command'", lineNo)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: process %s is defined twice", lineNo, name)
+		}
+		seen[name] = true
+
+		// Leading words are run flags; the rest is the shell command
+		words, starts, err := splitWords(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		opts, cmdArgs, err := parseRunArgs(words)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if len(cmdArgs) == 0 {
+			return nil, fmt.Errorf("line %d: process %s has no command", lineNo, name)
+		}
+
+		process := upProcess{
+			Name:    name,
+			Command: strings.TrimSpace(rest[starts[len(words)-len(cmdArgs)]:]),
+			Options: opts,
+		}
+		if err := process.finish(defaultEnvs); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		processes = append(processes, process)
+	}
+
+	return processes, scanner.Err()
+}
+
+// upConfig is the layout of envault.yml
+type upConfig struct {
+	Env       upList                     `yaml:"env"`
+	Processes map[string]upProcessConfig `yaml:"processes"`
+}
+
+type upProcessConfig struct {
+	Command     string            `yaml:"command"`
+	Dir         string            `yaml:"dir"`
+	Env         upList            `yaml:"env"`
+	Only        upList            `yaml:"only"`
+	Exclude     upList            `yaml:"exclude"`
+	Set         map[string]string `yaml:"set"`
+	FileSecrets map[string]string `yaml:"file_secrets"`
+	PreserveEnv bool              `yaml:"preserve_env"`
+	NoOverride  bool              `yaml:"no_override"`
+	Redact      bool              `yaml:"redact"`
+	StopSignal  string            `yaml:"stop_signal"`
+	GracePeriod string            `yaml:"grace_period"`
+}
+
+// upList is a YAML list that may also be written as a single string
+type upList []string
+
+func (l *upList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = upList{value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// parseUpYAML parses envault.yml, keeping the processes in file order
+func parseUpYAML(data []byte, defaultEnvs []string) ([]upProcess, error) {
+	var cfg upConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, err
+	}
+
+	// The map loses the order the processes were written in
+	var order struct {
+		Processes yaml.Node `yaml:"processes"`
+	}
+	if err := yaml.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+
+	if len(defaultEnvs) == 0 {
+		defaultEnvs = cfg.Env
+	}
+
+	var processes []upProcess
+	for i := 0; i+1 < len(order.Processes.Content); i += 2 {
+		name := order.Processes.Content[i].Value
+		if !processNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid process name %q (use letters, digits, '-' and '_')", name)
+		}
+
+		process, err := cfg.Processes[name].process(name, defaultEnvs)
+		if err != nil {
+			return nil, fmt.Errorf("process %s: %v", name, err)
+		}
+		processes = append(processes, process)
+	}
+
+	return processes, nil
+}
+
+// process turns a YAML process into run flags, so it is validated the same
+// way as 'envault run'
+func (c upProcessConfig) process(name string, defaultEnvs []string) (upProcess, error) {
+	if strings.TrimSpace(c.Command) == "" {
+		return upProcess{}, fmt.Errorf("no command")
+	}
+
+	var args []string
+	for _, env := range c.Env {
+		args = append(args, "--env", env)
+	}
+	for _, pattern := range c.Only {
+		args = append(args, "--only", pattern)
+	}
+	for _, pattern := range c.Exclude {
+		args = append(args, "--exclude", pattern)
+	}
+	for _, key := range sortedKeys(c.Set) {
+		args = append(args, "--set", key+"="+c.Set[key])
+	}
+	for _, key := range sortedKeys(c.FileSecrets) {
+		args = append(args, "--file-secret", key+"="+c.FileSecrets[key])
+	}
+	if c.PreserveEnv {
+		args = append(args, "--preserve-env")
+	}
+	if c.NoOverride {
+		args = append(args, "--no-override")
+	}
+	if c.Redact {
+		args = append(args, "--redact")
+	}
+	if c.StopSignal != "" {
+		args = append(args, "--stop-signal", c.StopSignal)
+	}
+	if c.GracePeriod != "" {
+		args = append(args, "--grace-period", c.GracePeriod)
+	}
+
+	opts, _, err := parseRunArgs(args)
+	if err != nil {
+		return upProcess{}, err
+	}
+
+	process := upProcess{Name: name, Command: c.Command, Dir: c.Dir, Options: opts}
+	if err := process.finish(defaultEnvs); err != nil {
+		return upProcess{}, err
+	}
+	return process, nil
+}
+
+// finish applies the default environments and checks the process's flags
+func (p *upProcess) finish(defaultEnvs []string) error {
+	if p.Options.Exec || p.Options.Watch {
+		return fmt.Errorf("--exec and --watch are not supported by envault up")
+	}
+
+	if len(p.Options.Environments) == 0 {
+		p.Options.Environments = append([]string(nil), defaultEnvs...)
+	}
+
+	return p.Options.withDefaults().validate()
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitWords splits s into words the way a shell would for simple
+// 'single' and "double" quoting, and returns where each word starts in s
+func splitWords(s string) ([]string, []int, error) {
+	var words []string
+	var starts []int
+
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for i, r := range s {
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		}
+
+		switch r {
+		case ' ', '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		}
+
+		if !inWord {
+			starts = append(starts, i)
+			inWord = true
+		}
+		if r == '\'' || r == '"' {
+			quote = r
+			continue
+		}
+		word.WriteRune(r)
+	}
+
+	if quote != 0 {
+		return nil, nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, starts, nil
+}
@@ -137,7 +137,11 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 
 // fetchLatestRelease gets the latest release from GitHub
 func fetchLatestRelease() (*GitHubRelease, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+	client, err := newHTTPClient(false)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = 10 * time.Second
 
 	req, err := http.NewRequest("GET", githubAPI, nil)
 	if err != nil {
@@ -218,7 +222,12 @@ func downloadAndInstall(url string) error {
 	defer os.Remove(tmpFile.Name())
 
 	// Download binary
-	client := &http.Client{Timeout: 5 * time.Minute}
+	client, err := newHTTPClient(false)
+	if err != nil {
+		return err
+	}
+	client.Timeout = 5 * time.Minute
+
 	resp, err := client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to download: %w", err)
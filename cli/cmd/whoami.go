@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+	"github.com/dj-pearson/envault/internal/auth"
+	"github.com/dj-pearson/envault/internal/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the account in use",
+	Long: `Show which EnvVault account commands run in this directory use, and why.
+
+With ENVAULT_TOKEN set, the token is described instead; for a machine
+token this needs a project whose default remote is an EnvVault API.
+
+Examples:
+  envault whoami
+  envault whoami --profile acme
+  envault whoami --json`,
+	Args: cobra.NoArgs,
+	RunE: runWhoami,
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
+
+func runWhoami(cmd *cobra.Command, args []string) error {
+	cyan := color.New(color.FgCyan)
+
+	// ENVAULT_TOKEN takes precedence over every login profile
+	if token := envToken(); token != "" {
+		return runWhoamiToken(cmd.Context(), token)
+	}
+
+	name, source, err := currentProfile()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	session, err := auth.LoadSession(name)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if jsonOutput {
+		info := struct {
+			Profile   string     `json:"profile"`
+			Source    string     `json:"source"`
+			UserID    string     `json:"user_id"`
+			Email     string     `json:"email,omitempty"`
+			Name      string     `json:"name,omitempty"`
+			ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		}{
+			Profile: name,
+			Source:  source,
+			UserID:  session.UserID,
+			Email:   session.Email,
+			Name:    session.Name,
+		}
+		if !session.ExpiresAt.IsZero() {
+			info.ExpiresAt = &session.ExpiresAt
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	if quiet {
+		fmt.Println(sessionDisplayName(session))
+		return nil
+	}
+
+	cyan.Println(sessionDisplayName(session))
+	if session.Name != "" {
+		fmt.Printf("  Name:    %s\n", session.Name)
+	}
+	fmt.Printf("  User ID: %s\n", session.UserID)
+	fmt.Printf("  Profile: %s (%s)\n", name, source)
+
+	switch {
+	case session.ExpiresAt.IsZero():
+		fmt.Println("  Expires: never")
+	case session.RefreshToken != "":
+		fmt.Println("  Expires: renewed automatically")
+	default:
+		fmt.Printf("  Expires: %s\n", session.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	return nil
+}
+
+// runWhoamiToken describes the token in ENVAULT_TOKEN
+func runWhoamiToken(ctx context.Context, token string) error {
+	cyan := color.New(color.FgCyan)
+
+	project, err := utils.LoadProjectContext()
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	client, err := newAPIClient(project)
+	if err != nil {
+		return fmt.Errorf("Error: %v", err)
+	}
+
+	if !api.IsMachineToken(token) {
+		user, err := client.GetCurrentUser(ctx)
+		if err != nil {
+			return apiError("failed to fetch user profile", err)
+		}
+
+		if jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(struct {
+				Source string `json:"source"`
+				*api.User
+			}{"ENVAULT_TOKEN", user})
+		}
+
+		cyan.Println(user.Email)
+		fmt.Printf("  User ID: %s\n", user.ID)
+		fmt.Println("  Profile: none (ENVAULT_TOKEN)")
+		return nil
+	}
+
+	scope, err := client.GetMachineTokenScope(ctx)
+	if err != nil {
+		return apiError("failed to read machine token scope", err)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(scope)
+	}
+
+	cyan.Printf("Machine token %s\n", scope.Name)
+	fmt.Printf("  Project:      %s\n", scope.ProjectID)
+	fmt.Printf("  Environments: %s (read-only)\n", tokenScopeText(scope))
+	if scope.ExpiresAt != nil {
+		fmt.Printf("  Expires:      %s\n", scope.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Device authorization states reported while polling
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("polling too fast")
+	ErrAccessDenied         = errors.New("authorization denied")
+	ErrDeviceCodeExpired    = errors.New("device code expired")
+)
+
+// User is the profile of the authenticated user
+type User struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// AuthToken is the result of a successful login or refresh
+type AuthToken struct {
+	UserID       string     `json:"user_id"`
+	AccessToken  string     `json:"access_token"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// DeviceAuthorization is a pending device-code login
+type DeviceAuthorization struct {
+	DeviceCode string `json:"device_code"`
+	UserCode   string `json:"user_code"`
+	ExpiresIn  int    `json:"expires_in"`
+	Interval   int    `json:"interval"`
+}
+
+// ExchangeAuthCode exchanges the one-time code from a browser login for a
+// session. The verifier proves this client started the login (PKCE).
+func (c *Client) ExchangeAuthCode(ctx context.Context, code, codeVerifier string) (*AuthToken, error) {
+	payload := map[string]interface{}{
+		"p_code":          code,
+		"p_code_verifier": codeVerifier,
+	}
+
+	var result AuthToken
+	if err := c.rpcCall(ctx, "exchange_cli_auth_code", payload, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RefreshSession exchanges a refresh token for a new session. Refresh tokens
+// are single use, so the returned token replaces the old one.
+func (c *Client) RefreshSession(ctx context.Context, refreshToken string) (*AuthToken, error) {
+	payload := map[string]interface{}{
+		"p_refresh_token": refreshToken,
+	}
+
+	var result AuthToken
+	if err := c.rpcCall(ctx, "refresh_cli_session", payload, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// StartDeviceAuthorization begins a device-code login for machines without
+// a browser
+func (c *Client) StartDeviceAuthorization(ctx context.Context, clientName string) (*DeviceAuthorization, error) {
+	payload := map[string]interface{}{
+		"p_client_name": clientName,
+	}
+
+	var result DeviceAuthorization
+	if err := c.rpcCall(ctx, "start_device_authorization", payload, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// PollDeviceAuthorization checks whether a device-code login was approved.
+// It returns ErrAuthorizationPending until the user approves the code.
+func (c *Client) PollDeviceAuthorization(ctx context.Context, deviceCode string) (*AuthToken, error) {
+	payload := map[string]interface{}{
+		"p_device_code": deviceCode,
+	}
+
+	var result struct {
+		AuthToken
+		Status string `json:"status"`
+	}
+
+	if err := c.rpcCall(ctx, "poll_device_authorization", payload, &result); err != nil {
+		return nil, err
+	}
+
+	switch result.Status {
+	case "approved":
+		return &result.AuthToken, nil
+	case "pending":
+		return nil, ErrAuthorizationPending
+	case "slow_down":
+		return nil, ErrSlowDown
+	case "denied":
+		return nil, ErrAccessDenied
+	default:
+		return nil, ErrDeviceCodeExpired
+	}
+}
+
+// GetCurrentUser returns the profile of the user the auth token belongs to
+func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.rpcCall(ctx, "get_current_user", map[string]interface{}{}, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,12 +17,18 @@ const (
 
 // Client represents an API client for EnvVault backend
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	apiKey     string
-	authToken  string
+	baseURL     string
+	httpClient  *http.Client
+	apiKey      string
+	authToken   string
+	tokenSource TokenSource
+	retryPolicy RetryPolicy
 }
 
+// TokenSource returns the access token to send with a request, renewing it
+// first if it is about to expire
+type TokenSource func(ctx context.Context) (string, error)
+
 // New creates a new API client
 func New(baseURL, apiKey string) *Client {
 	if baseURL == "" {
@@ -33,17 +40,35 @@ func New(baseURL, apiKey string) *Client {
 		httpClient: &http.Client{
 			Timeout: DefaultTimeout,
 		},
-		apiKey: apiKey,
+		apiKey:      apiKey,
+		retryPolicy: DefaultRetryPolicy,
 	}
 }
 
+// SetRetryPolicy overrides the retry policy used for transient failures
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// SetTransport replaces the HTTP transport, e.g. to route requests through a
+// proxy or to trust a private CA
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
 // SetAuthToken sets the authentication token for API requests
 func (c *Client) SetAuthToken(token string) {
 	c.authToken = token
 }
 
+// SetTokenSource makes the client ask source for the access token before
+// each request instead of using a fixed token
+func (c *Client) SetTokenSource(source TokenSource) {
+	c.tokenSource = source
+}
+
 // ValidateToken validates a CLI token with the backend
-func (c *Client) ValidateToken(token string) (string, error) {
+func (c *Client) ValidateToken(ctx context.Context, token string) (string, error) {
 	payload := map[string]interface{}{
 		"p_token": token,
 	}
@@ -52,23 +77,50 @@ func (c *Client) ValidateToken(token string) (string, error) {
 		UserID string `json:"user_id"`
 	}
 
-	if err := c.rpcCall("validate_cli_token", payload, &result); err != nil {
+	if err := c.rpcCall(ctx, "validate_cli_token", payload, &result); err != nil {
 		return "", err
 	}
 
 	return result.UserID, nil
 }
 
+// PushMetadata is sent along with a blob so that the server can list the
+// project and its environments by name, and hand machine tokens limited to
+// some environments only those. It never contains plaintext secrets. Empty
+// fields are not sent and leave what the server has unchanged.
+type PushMetadata struct {
+	ProjectName      string
+	Environments     []string
+	EnvironmentBlobs []EnvironmentBlob
+}
+
+// EnvironmentBlob is one environment's secrets, encrypted on their own in
+// the same format as the whole blob
+type EnvironmentBlob struct {
+	Environment   string `json:"environment"`
+	EncryptedData string `json:"encrypted_data"`
+	Checksum      string `json:"checksum"`
+}
+
 // PushEncryptedBlob pushes an encrypted blob to the backend
-func (c *Client) PushEncryptedBlob(projectID, encryptedData, checksum string) (*PushBlobResponse, error) {
+func (c *Client) PushEncryptedBlob(ctx context.Context, projectID, encryptedData, checksum string, meta PushMetadata) (*PushBlobResponse, error) {
 	payload := map[string]interface{}{
 		"p_project_id":     projectID,
 		"p_encrypted_data": encryptedData,
 		"p_checksum":       checksum,
 	}
+	if meta.ProjectName != "" {
+		payload["p_project_name"] = meta.ProjectName
+	}
+	if meta.Environments != nil {
+		payload["p_environments"] = meta.Environments
+	}
+	if meta.EnvironmentBlobs != nil {
+		payload["p_environment_blobs"] = meta.EnvironmentBlobs
+	}
 
 	var result PushBlobResponse
-	if err := c.rpcCall("push_encrypted_blob", payload, &result); err != nil {
+	if err := c.rpcCall(ctx, "push_encrypted_blob", payload, &result); err != nil {
 		return nil, err
 	}
 
@@ -76,7 +128,7 @@ func (c *Client) PushEncryptedBlob(projectID, encryptedData, checksum string) (*
 }
 
 // PullEncryptedBlob pulls the latest encrypted blob from the backend
-func (c *Client) PullEncryptedBlob(projectID string, sinceVersion *int) (*PullBlobResponse, error) {
+func (c *Client) PullEncryptedBlob(ctx context.Context, projectID string, sinceVersion *int) (*PullBlobResponse, error) {
 	payload := map[string]interface{}{
 		"p_project_id": projectID,
 	}
@@ -85,7 +137,7 @@ func (c *Client) PullEncryptedBlob(projectID string, sinceVersion *int) (*PullBl
 	}
 
 	var result PullBlobResponse
-	if err := c.rpcCall("pull_encrypted_blob", payload, &result); err != nil {
+	if err := c.rpcCall(ctx, "pull_encrypted_blob", payload, &result); err != nil {
 		return nil, err
 	}
 
@@ -93,8 +145,8 @@ func (c *Client) PullEncryptedBlob(projectID string, sinceVersion *int) (*PullBl
 }
 
 // GetProjects retrieves all projects for the authenticated user
-func (c *Client) GetProjects() ([]Project, error) {
-	req, err := c.newRequest("GET", "/rest/v1/projects", nil)
+func (c *Client) GetProjects(ctx context.Context) ([]Project, error) {
+	req, err := c.newRequest(ctx, "GET", "/rest/v1/projects", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -108,9 +160,9 @@ func (c *Client) GetProjects() ([]Project, error) {
 }
 
 // GetEnvironments retrieves all environments for a project
-func (c *Client) GetEnvironments(projectID string) ([]Environment, error) {
+func (c *Client) GetEnvironments(ctx context.Context, projectID string) ([]Environment, error) {
 	url := fmt.Sprintf("/rest/v1/environments?project_id=eq.%s", projectID)
-	req, err := c.newRequest("GET", url, nil)
+	req, err := c.newRequest(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -123,8 +175,9 @@ func (c *Client) GetEnvironments(projectID string) ([]Environment, error) {
 	return environments, nil
 }
 
-// InviteTeamMember invites a team member to a project
-func (c *Client) InviteTeamMember(projectID, email, role string) (string, error) {
+// InviteTeamMember adds an existing user to a project straight away. Use
+// CreateInvitation to let them accept or decline instead.
+func (c *Client) InviteTeamMember(ctx context.Context, projectID, email, role string) (string, error) {
 	payload := map[string]interface{}{
 		"p_project_id": projectID,
 		"p_email":      email,
@@ -132,7 +185,7 @@ func (c *Client) InviteTeamMember(projectID, email, role string) (string, error)
 	}
 
 	var memberID string
-	if err := c.rpcCall("invite_team_member", payload, &memberID); err != nil {
+	if err := c.rpcCall(ctx, "invite_team_member", payload, &memberID); err != nil {
 		return "", err
 	}
 
@@ -140,14 +193,14 @@ func (c *Client) InviteTeamMember(projectID, email, role string) (string, error)
 }
 
 // RemoveTeamMember removes a team member from a project
-func (c *Client) RemoveTeamMember(projectID, userID string) (bool, error) {
+func (c *Client) RemoveTeamMember(ctx context.Context, projectID, userID string) (bool, error) {
 	payload := map[string]interface{}{
 		"p_project_id": projectID,
 		"p_user_id":    userID,
 	}
 
 	var success bool
-	if err := c.rpcCall("remove_team_member", payload, &success); err != nil {
+	if err := c.rpcCall(ctx, "remove_team_member", payload, &success); err != nil {
 		return false, err
 	}
 
@@ -155,13 +208,13 @@ func (c *Client) RemoveTeamMember(projectID, userID string) (bool, error) {
 }
 
 // ListTeamMembers retrieves all team members for a project
-func (c *Client) ListTeamMembers(projectID string) ([]TeamMember, error) {
+func (c *Client) ListTeamMembers(ctx context.Context, projectID string) ([]TeamMember, error) {
 	payload := map[string]interface{}{
 		"p_project_id": projectID,
 	}
 
 	var members []TeamMember
-	if err := c.rpcCall("list_team_members", payload, &members); err != nil {
+	if err := c.rpcCall(ctx, "list_team_members", payload, &members); err != nil {
 		return nil, err
 	}
 
@@ -169,32 +222,46 @@ func (c *Client) ListTeamMembers(projectID string) ([]TeamMember, error) {
 }
 
 // GetUserByEmail retrieves a user ID by email address
-func (c *Client) GetUserByEmail(email string) (string, error) {
+func (c *Client) GetUserByEmail(ctx context.Context, email string) (string, error) {
 	payload := map[string]interface{}{
 		"p_email": email,
 	}
 
 	var userID string
-	if err := c.rpcCall("get_user_by_email", payload, &userID); err != nil {
+	if err := c.rpcCall(ctx, "get_user_by_email", payload, &userID); err != nil {
 		return "", err
 	}
 
 	return userID, nil
 }
 
+// idempotentRPCs lists the RPC functions that only read state and are
+// therefore safe to retry after a network error or 5xx response
+var idempotentRPCs = map[string]bool{
+	"validate_cli_token":      true,
+	"pull_encrypted_blob":     true,
+	"list_team_members":       true,
+	"get_user_by_email":       true,
+	"get_current_user":        true,
+	"list_machine_tokens":     true,
+	"get_machine_token_scope": true,
+	"list_team_invitations":   true,
+	"list_my_invitations":     true,
+}
+
 // rpcCall makes an RPC function call to Supabase
-func (c *Client) rpcCall(functionName string, payload map[string]interface{}, result interface{}) error {
+func (c *Client) rpcCall(ctx context.Context, functionName string, payload map[string]interface{}, result interface{}) error {
 	url := fmt.Sprintf("/rest/v1/rpc/%s", functionName)
-	req, err := c.newRequest("POST", url, payload)
+	req, err := c.newRequest(ctx, "POST", url, payload)
 	if err != nil {
 		return err
 	}
 
-	return c.do(req, result)
+	return c.doWithRetry(req, idempotentRPCs[functionName], result)
 }
 
 // newRequest creates a new HTTP request
-func (c *Client) newRequest(method, path string, body interface{}) (*http.Request, error) {
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
 	url := c.baseURL + path
 
 	var bodyReader io.Reader
@@ -203,10 +270,10 @@ func (c *Client) newRequest(method, path string, body interface{}) (*http.Reques
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewBuffer(jsonBody)
+		bodyReader = bytes.NewReader(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -215,40 +282,97 @@ func (c *Client) newRequest(method, path string, body interface{}) (*http.Reques
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("apikey", c.apiKey)
 
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	authToken := c.authToken
+	if c.tokenSource != nil {
+		authToken, err = c.tokenSource(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if authToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 	}
 
 	return req, nil
 }
 
-// do executes an HTTP request and decodes the response
+// do executes an HTTP request and decodes the response. GET requests are
+// retried on transient failures.
 func (c *Client) do(req *http.Request, result interface{}) error {
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+	return c.doWithRetry(req, req.Method == http.MethodGet, result)
+}
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
+// doWithRetry executes a request, retrying according to the client's retry
+// policy. Non-idempotent requests are only retried when the server signals
+// that it did not process them (429, or 503 with Retry-After).
+func (c *Client) doWithRetry(req *http.Request, idempotent bool, result interface{}) error {
+	ctx := req.Context()
+	policy := c.retryPolicy
 
-	// Check for HTTP errors
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
-	}
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := cloneRequest(req)
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.httpClient.Do(attemptReq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("request cancelled: %w", ctx.Err())
+			}
+			if !idempotent || attempt >= policy.MaxAttempts {
+				return fmt.Errorf("request failed: %w", err)
+			}
+			if err := sleepContext(ctx, policy.backoff(attempt)); err != nil {
+				return fmt.Errorf("request cancelled: %w", err)
+			}
+			continue
+		}
 
-	// Decode response
-	if result != nil {
-		if err := json.Unmarshal(bodyBytes, result); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
+		// Read response body
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		// Check for HTTP errors
+		if resp.StatusCode >= 400 {
+			apiErr := parseError(resp.StatusCode, bodyBytes)
+
+			wait, retry := policy.retryDelay(resp, apiErr, attempt, idempotent)
+			if !retry || attempt >= policy.MaxAttempts {
+				return apiErr
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return fmt.Errorf("request cancelled: %w", err)
+			}
+			continue
+		}
+
+		// Decode response
+		if result != nil {
+			if err := json.Unmarshal(bodyBytes, result); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
 		}
+
+		return nil
 	}
+}
 
-	return nil
+// cloneRequest returns a copy of req with a fresh body so it can be resent
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
 }
 
 // Response types
@@ -258,13 +382,17 @@ type PushBlobResponse struct {
 	UploadedAt time.Time `json:"uploaded_at"`
 }
 
+// PullBlobResponse is the newest blob. Machine tokens limited to some
+// environments get Environments, holding only those, instead of the whole
+// blob in EncryptedData.
 type PullBlobResponse struct {
-	HasUpdate     bool      `json:"has_update"`
-	ID            string    `json:"id,omitempty"`
-	Version       int       `json:"version,omitempty"`
-	EncryptedData string    `json:"encrypted_data,omitempty"`
-	Checksum      string    `json:"checksum,omitempty"`
-	UploadedAt    time.Time `json:"uploaded_at,omitempty"`
+	HasUpdate     bool              `json:"has_update"`
+	ID            string            `json:"id,omitempty"`
+	Version       int               `json:"version,omitempty"`
+	EncryptedData string            `json:"encrypted_data,omitempty"`
+	Checksum      string            `json:"checksum,omitempty"`
+	Environments  []EnvironmentBlob `json:"environments,omitempty"`
+	UploadedAt    time.Time         `json:"uploaded_at,omitempty"`
 }
 
 type Project struct {
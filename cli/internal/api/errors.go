@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// PostgREST and PostgreSQL error codes the CLI cares about
+const (
+	CodeJWTExpired          = "PGRST301"
+	CodeJWTInvalid          = "PGRST302"
+	CodeNoRows              = "PGRST116"
+	CodeFunctionNotFound    = "PGRST202"
+	CodeInsufficientPrivs   = "42501"
+	CodeUniqueViolation     = "23505"
+	CodeForeignKeyViolation = "23503"
+	CodeRaiseException      = "P0001"
+)
+
+// Error is a structured error returned by the EnvVault backend. It carries the
+// PostgREST/Supabase error payload so callers can react to specific failures.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Details    string `json:"details"`
+	Hint       string `json:"hint"`
+	// Body holds the raw response body when it could not be parsed
+	Body string `json:"-"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = strings.TrimSpace(e.Body)
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Code)
+	}
+	if e.Details != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Details)
+	}
+
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, msg)
+}
+
+// parseError builds an Error from a non-2xx response body. It understands the
+// PostgREST format ({code, message, details, hint}) as well as the GoTrue and
+// API gateway formats ({error, error_description} / {msg}).
+func parseError(statusCode int, body []byte) *Error {
+	apiErr := &Error{StatusCode: statusCode}
+
+	var payload struct {
+		Code             json.RawMessage `json:"code"`
+		Message          string          `json:"message"`
+		Details          *string         `json:"details"`
+		Hint             *string         `json:"hint"`
+		Msg              string          `json:"msg"`
+		ErrorName        string          `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		apiErr.Body = string(body)
+		return apiErr
+	}
+
+	// GoTrue returns numeric codes, PostgREST returns strings
+	var code string
+	if err := json.Unmarshal(payload.Code, &code); err == nil {
+		apiErr.Code = code
+	}
+
+	switch {
+	case payload.Message != "":
+		apiErr.Message = payload.Message
+	case payload.ErrorDescription != "":
+		apiErr.Message = payload.ErrorDescription
+	case payload.Msg != "":
+		apiErr.Message = payload.Msg
+	case payload.ErrorName != "":
+		apiErr.Message = payload.ErrorName
+	default:
+		apiErr.Body = string(body)
+	}
+
+	if payload.Details != nil {
+		apiErr.Details = *payload.Details
+	}
+	if payload.Hint != nil {
+		apiErr.Hint = *payload.Hint
+	}
+
+	return apiErr
+}
+
+// AsError extracts an *Error from err's chain
+func AsError(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// messageContains reports whether the error message contains any of the given
+// phrases. Backend RPCs signal most failures with RAISE EXCEPTION, which
+// PostgREST reports as a generic P0001, so the message is the only signal.
+func (e *Error) messageContains(phrases ...string) bool {
+	msg := strings.ToLower(e.Message)
+	for _, phrase := range phrases {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnauthorized reports whether err means the credentials are missing,
+// invalid or expired and the user needs to log in again
+func IsUnauthorized(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusUnauthorized ||
+		apiErr.Code == CodeJWTExpired ||
+		apiErr.Code == CodeJWTInvalid ||
+		apiErr.messageContains("invalid or expired token", "jwt expired")
+}
+
+// IsForbidden reports whether err means the user is authenticated but not
+// allowed to perform the operation
+func IsForbidden(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusForbidden ||
+		apiErr.Code == CodeInsufficientPrivs ||
+		apiErr.messageContains("access denied", "permission denied")
+}
+
+// IsNotFound reports whether err means the requested resource does not
+// exist. A missing RPC function is not a missing resource; see
+// IsFunctionNotFound.
+func IsNotFound(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return (apiErr.StatusCode == http.StatusNotFound && apiErr.Code != CodeFunctionNotFound) ||
+		apiErr.Code == CodeNoRows
+}
+
+// IsFunctionNotFound reports whether err means the backend has no such RPC
+// function, usually because it runs a different version than the CLI
+func IsFunctionNotFound(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.Code == CodeFunctionNotFound
+}
+
+// IsUserNotFound reports whether err is get_user_by_email's error for an
+// email address without an account
+func IsUserNotFound(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.Code == CodeRaiseException && apiErr.messageContains("user not found")
+}
+
+// IsConflict reports whether err means the operation conflicts with existing
+// state, such as a duplicate record
+func IsConflict(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusConflict ||
+		apiErr.Code == CodeUniqueViolation
+}
+
+// IsRateLimited reports whether err means the request was rejected by the
+// backend's rate limiter
+func IsRateLimited(err error) bool {
+	apiErr, ok := AsError(err)
+	if !ok {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusTooManyRequests ||
+		apiErr.messageContains("rate limit exceeded")
+}
+
+// IsNetworkError reports whether err means the backend could not be reached
+// at all, as opposed to the backend rejecting the request
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := AsError(err); ok {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
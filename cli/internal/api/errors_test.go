@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   Error
+		text   string
+	}{
+		{
+			name:   "PostgREST",
+			status: http.StatusBadRequest,
+			body:   `{"code": "P0001", "message": "User not found", "details": null, "hint": "Ask them to sign up"}`,
+			want:   Error{StatusCode: 400, Code: "P0001", Message: "User not found", Hint: "Ask them to sign up"},
+			text:   "API error (status 400): User not found (P0001)",
+		},
+		{
+			name:   "PostgREST with details",
+			status: http.StatusConflict,
+			body:   `{"code": "23505", "message": "duplicate key value", "details": "Key (email) already exists."}`,
+			want:   Error{StatusCode: 409, Code: "23505", Message: "duplicate key value", Details: "Key (email) already exists."},
+			text:   "API error (status 409): duplicate key value (23505): Key (email) already exists.",
+		},
+		{
+			name:   "GoTrue",
+			status: http.StatusBadRequest,
+			body:   `{"error": "invalid_grant", "error_description": "Refresh token expired"}`,
+			want:   Error{StatusCode: 400, Message: "Refresh token expired"},
+			text:   "API error (status 400): Refresh token expired",
+		},
+		{
+			name:   "GoTrue numeric code",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"code": 422, "msg": "Email not confirmed"}`,
+			want:   Error{StatusCode: 422, Message: "Email not confirmed"},
+			text:   "API error (status 422): Email not confirmed",
+		},
+		{
+			name:   "gateway",
+			status: http.StatusTooManyRequests,
+			body:   `{"error": "rate_limited"}`,
+			want:   Error{StatusCode: 429, Message: "rate_limited"},
+			text:   "API error (status 429): rate_limited",
+		},
+		{
+			name:   "JSON without a message",
+			status: http.StatusInternalServerError,
+			body:   `{"status": "down"}`,
+			want:   Error{StatusCode: 500, Body: `{"status": "down"}`},
+			text:   `API error (status 500): {"status": "down"}`,
+		},
+		{
+			name:   "not JSON",
+			status: http.StatusBadGateway,
+			body:   "<html>Bad Gateway</html>\n",
+			want:   Error{StatusCode: 502, Body: "<html>Bad Gateway</html>\n"},
+			text:   "API error (status 502): <html>Bad Gateway</html>",
+		},
+		{
+			name:   "empty",
+			status: http.StatusServiceUnavailable,
+			want:   Error{StatusCode: 503},
+			text:   "API error (status 503): Service Unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseError(tt.status, []byte(tt.body))
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+			if got.Error() != tt.text {
+				t.Errorf("Error() = %q, want %q", got.Error(), tt.text)
+			}
+		})
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	type predicates struct {
+		unauthorized, forbidden, notFound, functionNotFound, userNotFound, conflict, rateLimited bool
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   predicates
+	}{
+		{
+			name:   "get_user_by_email without an account",
+			status: http.StatusBadRequest,
+			body:   `{"code": "P0001", "message": "User not found"}`,
+			want:   predicates{userNotFound: true},
+		},
+		{
+			name:   "other RAISE EXCEPTION mentioning a user",
+			status: http.StatusBadRequest,
+			body:   `{"code": "P0001", "message": "User is already a member"}`,
+		},
+		{
+			name:   "user not found with another code",
+			status: http.StatusNotFound,
+			body:   `{"code": "PGRST116", "message": "user not found"}`,
+			want:   predicates{notFound: true},
+		},
+		{
+			name:   "missing RPC function",
+			status: http.StatusNotFound,
+			body:   `{"code": "PGRST202", "message": "Could not find the function public.get_user_by_email(p_email) in the schema cache"}`,
+			want:   predicates{functionNotFound: true},
+		},
+		{
+			name:   "missing resource",
+			status: http.StatusNotFound,
+			body:   `{"message": "Not found"}`,
+			want:   predicates{notFound: true},
+		},
+		{
+			name:   "no rows",
+			status: http.StatusNotAcceptable,
+			body:   `{"code": "PGRST116", "message": "JSON object requested, multiple (or no) rows returned"}`,
+			want:   predicates{notFound: true},
+		},
+		{
+			name:   "expired JWT",
+			status: http.StatusUnauthorized,
+			body:   `{"code": "PGRST301", "message": "JWT expired"}`,
+			want:   predicates{unauthorized: true},
+		},
+		{
+			name:   "invalid token raised by an RPC",
+			status: http.StatusBadRequest,
+			body:   `{"code": "P0001", "message": "Invalid or expired token"}`,
+			want:   predicates{unauthorized: true},
+		},
+		{
+			name:   "insufficient role",
+			status: http.StatusForbidden,
+			body:   `{"code": "42501", "message": "Access denied: requires admin role"}`,
+			want:   predicates{forbidden: true},
+		},
+		{
+			name:   "access denied raised by an RPC",
+			status: http.StatusBadRequest,
+			body:   `{"code": "P0001", "message": "Access denied or project not found"}`,
+			want:   predicates{forbidden: true},
+		},
+		{
+			name:   "duplicate",
+			status: http.StatusConflict,
+			body:   `{"code": "23505", "message": "duplicate key value violates unique constraint"}`,
+			want:   predicates{conflict: true},
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			body:   `{"message": "Too many requests"}`,
+			want:   predicates{rateLimited: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStubServer(t, stubResponse{status: tt.status, body: tt.body})
+			client := New(srv.URL, "key")
+			client.SetRetryPolicy(NoRetry)
+
+			err := client.rpcCall(context.Background(), "get_user_by_email", map[string]interface{}{"p_email": "a@example.com"}, nil)
+			if err == nil {
+				t.Fatal("no error")
+			}
+
+			// Callers usually wrap the error before checking it
+			err = fmt.Errorf("failed to look up user: %w", err)
+
+			got := predicates{
+				unauthorized:     IsUnauthorized(err),
+				forbidden:        IsForbidden(err),
+				notFound:         IsNotFound(err),
+				functionNotFound: IsFunctionNotFound(err),
+				userNotFound:     IsUserNotFound(err),
+				conflict:         IsConflict(err),
+				rateLimited:      IsRateLimited(err),
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if IsNetworkError(err) {
+				t.Error("IsNetworkError() = true for an error response")
+			}
+		})
+	}
+}
+
+func TestErrorPredicatesOtherErrors(t *testing.T) {
+	for _, err := range []error{nil, errors.New("User not found (PGRST202)")} {
+		if IsUserNotFound(err) || IsFunctionNotFound(err) || IsNotFound(err) || IsUnauthorized(err) {
+			t.Errorf("%v was taken for an API error", err)
+		}
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	srv := newStubServer(t, stubResponse{})
+	client := New(srv.URL, "key")
+	client.SetRetryPolicy(NoRetry)
+
+	err := client.rpcCall(context.Background(), "get_current_user", map[string]interface{}{}, nil)
+	if !IsNetworkError(err) {
+		t.Errorf("IsNetworkError(%v) = false for a dropped connection", err)
+	}
+	if _, ok := AsError(err); ok {
+		t.Errorf("AsError(%v) found an API error in a network error", err)
+	}
+}
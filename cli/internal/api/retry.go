@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries transient failures
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt; it doubles each time
+	BaseDelay time.Duration
+	// MaxDelay caps both the exponential backoff and honoured Retry-After values
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created with New
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// NoRetry disables retries entirely
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// backoff returns the full-jitter exponential backoff for the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// retryDelay decides whether a failed response should be retried and how long
+// to wait first. Rate-limited requests are always safe to retry because they
+// were rejected before they ran; 5xx responses are only retried for idempotent
+// requests unless the server explicitly asked us to come back later.
+func (p RetryPolicy) retryDelay(resp *http.Response, apiErr *Error, attempt int, idempotent bool) (time.Duration, bool) {
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	switch {
+	case IsRateLimited(apiErr):
+	case resp.StatusCode == http.StatusServiceUnavailable && hasRetryAfter:
+	case resp.StatusCode >= 500 && idempotent:
+	default:
+		return 0, false
+	}
+
+	if hasRetryAfter {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			// The server wants us to wait longer than we are willing to
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		wait := time.Until(when)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
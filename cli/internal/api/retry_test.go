@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly but still honours Retry-After up to 5s
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// stubResponse is one canned response; status 0 drops the connection
+type stubResponse struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+// stubServer answers requests with responses in order, repeating the last
+// one, and records the body of every request it receives
+type stubServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []stubResponse
+	bodies    []string
+}
+
+func newStubServer(t *testing.T, responses ...stubResponse) *stubServer {
+	t.Helper()
+
+	s := &stubServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		resp := s.responses[min(len(s.bodies), len(s.responses)-1)]
+		s.bodies = append(s.bodies, string(body))
+		s.mu.Unlock()
+
+		if resp.status == 0 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		if resp.body == "" {
+			resp.body = "{}"
+		}
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *stubServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestDoWithRetry(t *testing.T) {
+	ok := stubResponse{status: http.StatusOK, body: `{"user_id": "u1"}`}
+	rateLimited := stubResponse{status: http.StatusTooManyRequests, body: `{"message": "Rate limit exceeded"}`}
+	unavailable := stubResponse{status: http.StatusServiceUnavailable}
+	serverError := stubResponse{status: http.StatusInternalServerError, body: `{"code": "XX000", "message": "Internal server error"}`}
+	dropped := stubResponse{}
+
+	tests := []struct {
+		name      string
+		function  string
+		responses []stubResponse
+		attempts  int
+		wantErr   bool
+	}{
+		{
+			name:      "idempotent 5xx is retried",
+			function:  "pull_encrypted_blob",
+			responses: []stubResponse{serverError, ok},
+			attempts:  2,
+		},
+		{
+			name:      "idempotent network error is retried",
+			function:  "list_team_members",
+			responses: []stubResponse{dropped, ok},
+			attempts:  2,
+		},
+		{
+			name:      "idempotent gives up after MaxAttempts",
+			function:  "pull_encrypted_blob",
+			responses: []stubResponse{unavailable},
+			attempts:  3,
+			wantErr:   true,
+		},
+		{
+			name:      "non-idempotent 5xx is not retried",
+			function:  "push_encrypted_blob",
+			responses: []stubResponse{serverError, ok},
+			attempts:  1,
+			wantErr:   true,
+		},
+		{
+			name:      "non-idempotent network error is not retried",
+			function:  "push_encrypted_blob",
+			responses: []stubResponse{dropped, ok},
+			attempts:  1,
+			wantErr:   true,
+		},
+		{
+			name:      "non-idempotent 429 is retried",
+			function:  "push_encrypted_blob",
+			responses: []stubResponse{rateLimited, ok},
+			attempts:  2,
+		},
+		{
+			name:      "non-idempotent 503 with Retry-After is retried",
+			function:  "invite_team_member",
+			responses: []stubResponse{{status: http.StatusServiceUnavailable, retryAfter: "0"}, ok},
+			attempts:  2,
+		},
+		{
+			name:      "Retry-After beyond MaxDelay is not waited for",
+			function:  "pull_encrypted_blob",
+			responses: []stubResponse{{status: http.StatusTooManyRequests, retryAfter: "3600"}, ok},
+			attempts:  1,
+			wantErr:   true,
+		},
+		{
+			name:      "client errors are not retried",
+			function:  "pull_encrypted_blob",
+			responses: []stubResponse{{status: http.StatusBadRequest, body: `{"code": "P0001", "message": "Access denied or project not found"}`}, ok},
+			attempts:  1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStubServer(t, tt.responses...)
+			client := New(srv.URL, "key")
+			client.SetRetryPolicy(testRetryPolicy)
+
+			payload := map[string]interface{}{"p_project_id": "p1", "p_encrypted_data": "blob"}
+			err := client.rpcCall(context.Background(), tt.function, payload, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tt.wantErr)
+			}
+
+			bodies := srv.requests()
+			if len(bodies) != tt.attempts {
+				t.Fatalf("made %d attempts, want %d", len(bodies), tt.attempts)
+			}
+			for i, body := range bodies {
+				if body != bodies[0] {
+					t.Errorf("attempt %d sent body %q, want the same body as the first %q", i+1, body, bodies[0])
+				}
+			}
+			if bodies[0] == "" {
+				t.Error("the request body is empty")
+			}
+		})
+	}
+}
+
+func TestDoWithRetryHonoursRetryAfter(t *testing.T) {
+	srv := newStubServer(t,
+		stubResponse{status: http.StatusTooManyRequests, retryAfter: "1"},
+		stubResponse{status: http.StatusOK, body: `{"user_id": "u1"}`},
+	)
+	client := New(srv.URL, "key")
+	client.SetRetryPolicy(testRetryPolicy)
+
+	start := time.Now()
+	userID, err := client.ValidateToken(context.Background(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "u1" {
+		t.Errorf("got user %q, want u1", userID)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s asked for in Retry-After", elapsed)
+	}
+}
+
+func TestDoWithRetryCancelled(t *testing.T) {
+	srv := newStubServer(t, stubResponse{status: http.StatusTooManyRequests, retryAfter: "5"})
+	client := New(srv.URL, "key")
+	client.SetRetryPolicy(testRetryPolicy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := client.rpcCall(ctx, "pull_encrypted_blob", map[string]interface{}{}, nil); err == nil {
+		t.Fatal("no error after the context was cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %v, want the wait cut short by the context", elapsed)
+	}
+}
+
+func TestDoRetriesGetOnly(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		srv := newStubServer(t,
+			stubResponse{status: http.StatusBadGateway},
+			stubResponse{status: http.StatusOK, body: `[]`},
+		)
+		client := New(srv.URL, "key")
+		client.SetRetryPolicy(testRetryPolicy)
+
+		req, err := client.newRequest(context.Background(), method, "/rest/v1/projects", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.do(req, nil)
+
+		want := 1
+		if method == http.MethodGet {
+			want = 2
+		}
+		if got := len(srv.requests()); got != want {
+			t.Errorf("%s made %d attempts, want %d (error %v)", method, got, want, err)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// An HTTP date in the future gives the time left until then
+	got, ok := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(date in a minute) = %v, %v; want about a minute", got, ok)
+	}
+}
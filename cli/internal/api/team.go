@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// Invitation is a pending invitation to join a project
+type Invitation struct {
+	ID             string    `json:"id"`
+	ProjectID      string    `json:"project_id"`
+	ProjectName    string    `json:"project_name,omitempty"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	InvitedBy      string    `json:"invited_by,omitempty"`
+	InvitedByEmail string    `json:"invited_by_email,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Expired reports whether the invitation can no longer be accepted
+func (i *Invitation) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// Membership describes the project joined by accepting an invitation
+type Membership struct {
+	MemberID    string `json:"member_id"`
+	ProjectID   string `json:"project_id"`
+	ProjectName string `json:"project_name,omitempty"`
+	Role        string `json:"role"`
+}
+
+// CreateInvitation invites an email to a project. The invitee accepts it
+// with AcceptInvitation; a zero ttl uses the server's default lifetime.
+func (c *Client) CreateInvitation(ctx context.Context, projectID, projectName, email, role string, ttl time.Duration) (*Invitation, error) {
+	payload := map[string]interface{}{
+		"p_project_id":         projectID,
+		"p_project_name":       projectName,
+		"p_email":              email,
+		"p_role":               role,
+		"p_expires_in_seconds": int64(ttl.Seconds()),
+	}
+
+	var invitation Invitation
+	if err := c.rpcCall(ctx, "create_team_invitation", payload, &invitation); err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// ListInvitations lists a project's pending invitations, including expired
+// ones
+func (c *Client) ListInvitations(ctx context.Context, projectID string) ([]Invitation, error) {
+	payload := map[string]interface{}{
+		"p_project_id": projectID,
+	}
+
+	var invitations []Invitation
+	if err := c.rpcCall(ctx, "list_team_invitations", payload, &invitations); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation withdraws one of a project's invitations
+func (c *Client) RevokeInvitation(ctx context.Context, projectID, invitationID string) (bool, error) {
+	payload := map[string]interface{}{
+		"p_project_id":    projectID,
+		"p_invitation_id": invitationID,
+	}
+
+	var revoked bool
+	if err := c.rpcCall(ctx, "revoke_team_invitation", payload, &revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// ListMyInvitations lists the unexpired invitations sent to the current
+// user's email
+func (c *Client) ListMyInvitations(ctx context.Context) ([]Invitation, error) {
+	var invitations []Invitation
+	if err := c.rpcCall(ctx, "list_my_invitations", map[string]interface{}{}, &invitations); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation joins the project of an invitation sent to the current
+// user
+func (c *Client) AcceptInvitation(ctx context.Context, invitationID string) (*Membership, error) {
+	payload := map[string]interface{}{
+		"p_invitation_id": invitationID,
+	}
+
+	var membership Membership
+	if err := c.rpcCall(ctx, "accept_team_invitation", payload, &membership); err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// UpdateTeamMemberRole changes a member's role in a project
+func (c *Client) UpdateTeamMemberRole(ctx context.Context, projectID, userID, role string) (bool, error) {
+	payload := map[string]interface{}{
+		"p_project_id": projectID,
+		"p_user_id":    userID,
+		"p_role":       role,
+	}
+
+	var changed bool
+	if err := c.rpcCall(ctx, "update_team_member_role", payload, &changed); err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// LeaveProject removes the current user from a project
+func (c *Client) LeaveProject(ctx context.Context, projectID string) (bool, error) {
+	payload := map[string]interface{}{
+		"p_project_id": projectID,
+	}
+
+	var left bool
+	if err := c.rpcCall(ctx, "leave_project", payload, &left); err != nil {
+		return false, err
+	}
+
+	return left, nil
+}
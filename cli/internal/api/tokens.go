@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// MachineTokenPrefix marks machine tokens, which are scoped to one project
+// and can only read it
+const MachineTokenPrefix = "envm_"
+
+// IsMachineToken reports whether token is a machine token rather than a
+// personal access token
+func IsMachineToken(token string) bool {
+	return strings.HasPrefix(token, MachineTokenPrefix)
+}
+
+// MachineToken describes a machine token. Token is only set when the token
+// has just been created; it cannot be retrieved later.
+type MachineToken struct {
+	ID           string     `json:"id"`
+	Token        string     `json:"token,omitempty"`
+	Name         string     `json:"name"`
+	ProjectID    string     `json:"project_id"`
+	Environments []string   `json:"environments"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Allows reports whether the token may read an environment. A token with no
+// environments listed may read all of them.
+func (t *MachineToken) Allows(environment string) bool {
+	if len(t.Environments) == 0 {
+		return true
+	}
+	for _, env := range t.Environments {
+		if env == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateMachineToken issues a read-only token for a project, limited to the
+// given environments (all if empty). Only project admins can create them.
+func (c *Client) CreateMachineToken(ctx context.Context, projectID, name string, environments []string, ttl time.Duration) (*MachineToken, error) {
+	if environments == nil {
+		environments = []string{}
+	}
+
+	payload := map[string]interface{}{
+		"p_project_id":         projectID,
+		"p_name":               name,
+		"p_environments":       environments,
+		"p_expires_in_seconds": int64(ttl.Seconds()),
+	}
+
+	var result MachineToken
+	if err := c.rpcCall(ctx, "create_machine_token", payload, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListMachineTokens lists a project's machine tokens
+func (c *Client) ListMachineTokens(ctx context.Context, projectID string) ([]MachineToken, error) {
+	payload := map[string]interface{}{
+		"p_project_id": projectID,
+	}
+
+	var tokens []MachineToken
+	if err := c.rpcCall(ctx, "list_machine_tokens", payload, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokeMachineToken revokes one of a project's machine tokens
+func (c *Client) RevokeMachineToken(ctx context.Context, projectID, tokenID string) (bool, error) {
+	payload := map[string]interface{}{
+		"p_project_id": projectID,
+		"p_token_id":   tokenID,
+	}
+
+	var revoked bool
+	if err := c.rpcCall(ctx, "revoke_machine_token", payload, &revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// GetMachineTokenScope describes the machine token the client authenticates
+// with
+func (c *Client) GetMachineTokenScope(ctx context.Context) (*MachineToken, error) {
+	var result MachineToken
+	if err := c.rpcCall(ctx, "get_machine_token_scope", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -2,25 +2,96 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/dj-pearson/envault/internal/config"
+	"github.com/dj-pearson/envault/internal/crypto"
 	"github.com/dj-pearson/envault/internal/models"
+	"github.com/zalando/go-keyring"
 )
 
-// Session represents an authenticated session
+// refreshMargin is how long before expiry a session is refreshed, so that a
+// token does not expire in the middle of a sync
+const refreshMargin = time.Minute
+
+// Session represents an authenticated session. The tokens are kept in the OS
+// keychain; only the non-secret fields are written to disk.
 type Session struct {
-	UserID      string    `json:"user_id"`
-	Email       string    `json:"email"`
-	AccessToken string    `json:"access_token"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	// Profile is the named account the session belongs to
+	Profile string `json:"-"`
+
+	UserID       string    `json:"user_id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name,omitempty"`
+	AccessToken  string    `json:"-"`
+	RefreshToken string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// sessionFile is the on-disk form of a session. Versions before the keychain
+// move also wrote the tokens here; they are migrated when first read.
+type sessionFile struct {
+	Session
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// sessionSecrets is the keychain form of a session's tokens
+type sessionSecrets struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// Expired reports whether the access token has expired. A zero ExpiresAt
+// means the token has no expiry of its own.
+func (s *Session) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+// NeedsRefresh reports whether the access token is about to expire and can
+// be renewed with the refresh token
+func (s *Session) NeedsRefresh() bool {
+	return s.RefreshToken != "" && !s.ExpiresAt.IsZero() &&
+		time.Now().Add(refreshMargin).After(s.ExpiresAt)
+}
+
+// sessionPath returns the path of a profile's session metadata file. The
+// default profile keeps the session.json used before profiles existed.
+func sessionPath(profile string) (string, error) {
+	cfg, err := config.New()
+	if err != nil {
+		return "", fmt.Errorf("failed to create config: %w", err)
+	}
+
+	if profile == DefaultProfile {
+		return filepath.Join(cfg.AuthDir, "session.json"), nil
+	}
+
+	return filepath.Join(cfg.AuthDir, "sessions", profile+".json"), nil
+}
+
+// sessionAccount is the keyring account holding a profile's tokens
+func sessionAccount(profile string) string {
+	if profile == DefaultProfile {
+		return "session"
+	}
+	return "session:" + profile
 }
 
-// SaveSession saves the authentication session to disk
+// SaveSession stores the session's tokens in the OS keychain and its
+// metadata on disk, under session.Profile
 func SaveSession(session *Session) error {
+	if session.Profile == "" {
+		session.Profile = DefaultProfile
+	}
+	if err := ValidateProfileName(session.Profile); err != nil {
+		return err
+	}
+
 	cfg, err := config.New()
 	if err != nil {
 		return fmt.Errorf("failed to create config: %w", err)
@@ -30,81 +101,209 @@ func SaveSession(session *Session) error {
 		return fmt.Errorf("failed to ensure directories: %w", err)
 	}
 
-	sessionPath := filepath.Join(cfg.AuthDir, "session.json")
+	path, err := sessionPath(session.Profile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	if err := saveSessionSecrets(session); err != nil {
+		return err
+	}
+
+	return writeSessionFile(path, session)
+}
 
+// saveSessionSecrets writes the session's tokens to the keychain
+func saveSessionSecrets(session *Session) error {
+	secrets, err := json.Marshal(sessionSecrets{
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if err := keyring.Set(crypto.KeyringService, sessionAccount(session.Profile), string(secrets)); err != nil {
+		return fmt.Errorf("failed to store session in keychain: %w", err)
+	}
+
+	return nil
+}
+
+// writeSessionFile writes the session's non-secret fields
+func writeSessionFile(path string, session *Session) error {
 	data, err := json.MarshalIndent(session, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	if err := os.WriteFile(sessionPath, data, 0600); err != nil {
+	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write session file: %w", err)
 	}
 
 	return nil
 }
 
-// LoadSession loads the authentication session from disk
-func LoadSession() (*Session, error) {
-	cfg, err := config.New()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create config: %w", err)
+// LoadSession loads a profile's session metadata from disk and its tokens
+// from the OS keychain
+func LoadSession(profile string) (*Session, error) {
+	if err := ValidateProfileName(profile); err != nil {
+		return nil, err
 	}
 
-	sessionPath := filepath.Join(cfg.AuthDir, "session.json")
+	path, err := sessionPath(profile)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if session file exists
-	if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("not logged in (run 'envault login' first)")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, notLoggedIn(profile)
 	}
 
-	data, err := os.ReadFile(sessionPath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read session file: %w", err)
 	}
 
-	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
+	var file sessionFile
+	if err := json.Unmarshal(data, &file); err != nil {
 		return nil, fmt.Errorf("failed to parse session file: %w", err)
 	}
 
-	// Check if session is expired
-	if time.Now().After(session.ExpiresAt) {
-		return nil, fmt.Errorf("session expired (run 'envault login' again)")
+	session := file.Session
+	session.Profile = profile
+
+	if file.AccessToken != "" {
+		// Written by an older version: move the tokens into the keychain. If
+		// the keychain is unavailable the file is left alone and the
+		// migration is retried next time.
+		session.AccessToken = file.AccessToken
+		session.RefreshToken = file.RefreshToken
+		_ = migrateSessionFile(path, &session)
+	} else {
+		secrets, err := loadSessionSecrets(profile)
+		if err != nil {
+			return nil, err
+		}
+		session.AccessToken = secrets.AccessToken
+		session.RefreshToken = secrets.RefreshToken
+	}
+
+	// Check if session is expired; sessions with a refresh token are renewed
+	// by the caller instead
+	if session.Expired() && session.RefreshToken == "" {
+		return nil, fmt.Errorf("session expired (run '%s' again)", LoginCommand(profile))
 	}
 
 	return &session, nil
 }
 
-// ClearSession removes the authentication session
-func ClearSession() error {
-	cfg, err := config.New()
+// notLoggedIn is the error for a profile without a session
+func notLoggedIn(profile string) error {
+	return fmt.Errorf("not logged in (run '%s' first)", LoginCommand(profile))
+}
+
+// LoginCommand is the command that logs in to a profile
+func LoginCommand(profile string) string {
+	if profile == DefaultProfile || profile == "" {
+		return "envault login"
+	}
+	return "envault login --profile " + profile
+}
+
+// loadSessionSecrets reads a profile's tokens from the keychain
+func loadSessionSecrets(profile string) (*sessionSecrets, error) {
+	data, err := keyring.Get(crypto.KeyringService, sessionAccount(profile))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("not logged in (session credentials missing from keychain; run '%s')", LoginCommand(profile))
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create config: %w", err)
+		return nil, fmt.Errorf("failed to read session from keychain: %w", err)
 	}
 
-	sessionPath := filepath.Join(cfg.AuthDir, "session.json")
+	var secrets sessionSecrets
+	if err := json.Unmarshal([]byte(data), &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse session from keychain: %w", err)
+	}
 
-	if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove session file: %w", err)
+	return &secrets, nil
+}
+
+// migrateSessionFile moves a legacy session's tokens into the keychain and
+// strips them from the file
+func migrateSessionFile(path string, session *Session) error {
+	if err := saveSessionSecrets(session); err != nil {
+		return err
 	}
 
-	return nil
+	return writeSessionFile(path, session)
 }
 
-// IsLoggedIn checks if there is a valid session
-func IsLoggedIn() bool {
-	session, err := LoadSession()
+// MigrateLegacySession moves the tokens of a session file written by an older
+// version into the keychain. It does nothing when there is no such file.
+func MigrateLegacySession() error {
+	path, err := sessionPath(DefaultProfile)
 	if err != nil {
-		return false
+		return err
 	}
 
-	return time.Now().Before(session.ExpiresAt)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var file sessionFile
+	if err := json.Unmarshal(data, &file); err != nil || file.AccessToken == "" {
+		return nil
+	}
+
+	session := file.Session
+	session.Profile = DefaultProfile
+	session.AccessToken = file.AccessToken
+	session.RefreshToken = file.RefreshToken
+
+	return migrateSessionFile(path, &session)
+}
+
+// ClearSession removes a profile's session from disk and the keychain
+func ClearSession(profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+
+	path, err := sessionPath(profile)
+	if err != nil {
+		return err
+	}
+
+	if err := keyring.Delete(crypto.KeyringService, sessionAccount(profile)); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to remove session from keychain: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session file: %w", err)
+	}
+
+	return nil
+}
+
+// IsLoggedIn checks if a profile has a valid session
+func IsLoggedIn(profile string) bool {
+	_, err := LoadSession(profile)
+	return err == nil
 }
 
-// GetCurrentUser returns the current authenticated user
-func GetCurrentUser() (*models.AuthSession, error) {
-	session, err := LoadSession()
+// GetCurrentUser returns the user a profile is logged in as
+func GetCurrentUser(profile string) (*models.AuthSession, error) {
+	session, err := LoadSession(profile)
 	if err != nil {
 		return nil, err
 	}
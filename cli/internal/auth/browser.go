@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// PKCE holds a proof key for a browser login (RFC 7636)
+type PKCE struct {
+	Verifier  string
+	Challenge string
+}
+
+// NewPKCE generates a random verifier and its S256 challenge
+func NewPKCE() (*PKCE, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return &PKCE{
+		Verifier:  verifier,
+		Challenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// randomString returns n random bytes encoded as unpadded base64url
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CallbackServer receives the authorization code from the browser on a
+// loopback address
+type CallbackServer struct {
+	listener net.Listener
+	server   *http.Server
+	state    string
+	result   chan callbackResult
+}
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// NewCallbackServer starts listening on a random port on 127.0.0.1
+func NewCallbackServer() (*CallbackServer, error) {
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start login callback server: %w", err)
+	}
+
+	s := &CallbackServer{
+		listener: listener,
+		state:    state,
+		result:   make(chan callbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", s.handleCallback)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go s.server.Serve(listener)
+
+	return s, nil
+}
+
+// RedirectURI is the URL the browser is sent back to
+func (s *CallbackServer) RedirectURI() string {
+	return fmt.Sprintf("http://%s/callback", s.listener.Addr().String())
+}
+
+// State is the anti-forgery value the browser must echo back
+func (s *CallbackServer) State() string {
+	return s.state
+}
+
+// Wait blocks until the browser delivers a code or ctx is done
+func (s *CallbackServer) Wait(ctx context.Context) (string, error) {
+	select {
+	case result := <-s.result:
+		return result.code, result.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close stops the server
+func (s *CallbackServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// handleCallback validates the redirect and hands the code to Wait
+func (s *CallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var result callbackResult
+	switch {
+	case subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(s.state)) != 1:
+		// Not our login; ignore it rather than failing the real one
+		http.Error(w, "Invalid login state. Start the login again from the terminal.", http.StatusBadRequest)
+		return
+	case query.Get("error") != "":
+		result.err = fmt.Errorf("login was not completed: %s", firstNonEmpty(query.Get("error_description"), query.Get("error")))
+	case query.Get("code") == "":
+		result.err = fmt.Errorf("login callback did not include an authorization code")
+	default:
+		result.code = query.Get("code")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if result.err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, callbackPage("Login failed", "Return to your terminal for details."))
+	} else {
+		fmt.Fprint(w, callbackPage("Login complete", "You can close this tab and return to your terminal."))
+	}
+
+	select {
+	case s.result <- result:
+	default:
+	}
+}
+
+// callbackPage renders the page shown in the browser after the redirect
+func callbackPage(title, message string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>EnvVault CLI</title></head>
+<body style="font-family: system-ui, sans-serif; text-align: center; padding-top: 15vh">
+<h1>%s</h1><p>%s</p>
+</body></html>`, title, message)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// AuthorizeURL builds the dashboard URL that starts a browser login
+func AuthorizeURL(dashboardURL string, pkce *PKCE, server *CallbackServer, clientName string) string {
+	query := url.Values{}
+	query.Set("redirect_uri", server.RedirectURI())
+	query.Set("state", server.State())
+	query.Set("code_challenge", pkce.Challenge)
+	query.Set("code_challenge_method", "S256")
+	query.Set("client_name", clientName)
+
+	return dashboardURL + "/cli/authorize?" + query.Encode()
+}
+
+// CanOpenBrowser reports whether a browser can be opened on this machine.
+// SSH sessions and Linux machines without a display cannot.
+func CanOpenBrowser() bool {
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return false
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "freebsd" {
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+
+	return true
+}
+
+// OpenBrowser opens url in the user's default browser
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open browser: %w", err)
+	}
+
+	// Reap the launcher without blocking the login
+	go cmd.Wait()
+
+	return nil
+}
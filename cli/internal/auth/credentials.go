@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dj-pearson/envault/internal/crypto"
+	"github.com/zalando/go-keyring"
+)
+
+// RemoteCredentials holds the secrets needed to talk to a sync remote. Only
+// the fields relevant to the remote's kind are set.
+type RemoteCredentials struct {
+	// APIKey is the anon key for EnvVault cloud or an envault-server
+	APIKey string `json:"api_key,omitempty"`
+
+	// S3 access keys
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+}
+
+// IsEmpty reports whether no credential is set
+func (c *RemoteCredentials) IsEmpty() bool {
+	return c == nil || *c == RemoteCredentials{}
+}
+
+// remoteAccount is the keyring account for a project's remote
+func remoteAccount(projectID, remote string) string {
+	return fmt.Sprintf("remote:%s:%s", projectID, remote)
+}
+
+// SaveRemoteCredentials stores a remote's credentials in the OS keychain
+func SaveRemoteCredentials(projectID, remote string, creds *RemoteCredentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+
+	if err := keyring.Set(crypto.KeyringService, remoteAccount(projectID, remote), string(data)); err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
+	}
+
+	return nil
+}
+
+// LoadRemoteCredentials returns a remote's stored credentials, or nil if
+// none were stored
+func LoadRemoteCredentials(projectID, remote string) (*RemoteCredentials, error) {
+	data, err := keyring.Get(crypto.KeyringService, remoteAccount(projectID, remote))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var creds RemoteCredentials
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+
+	return &creds, nil
+}
+
+// DeleteRemoteCredentials removes a remote's stored credentials
+func DeleteRemoteCredentials(projectID, remote string) error {
+	err := keyring.Delete(crypto.KeyringService, remoteAccount(projectID, remote))
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete credentials: %w", err)
+	}
+
+	return nil
+}
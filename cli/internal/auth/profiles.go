@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dj-pearson/envault/internal/config"
+)
+
+// DefaultProfile is the profile used when none has been chosen. It keeps the
+// session paths used before profiles existed.
+const DefaultProfile = "default"
+
+// profileNamePattern restricts profile names to something safe to use as a
+// file name and keychain account
+var profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// ValidateProfileName checks that a profile name is well formed
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// activeProfilePath returns the path of the file recording the active profile
+func activeProfilePath() (string, error) {
+	cfg, err := config.New()
+	if err != nil {
+		return "", fmt.Errorf("failed to create config: %w", err)
+	}
+
+	return filepath.Join(cfg.AuthDir, "active_profile"), nil
+}
+
+// ActiveProfile returns the profile selected with 'envault account switch',
+// or DefaultProfile when none has been selected
+func ActiveProfile() string {
+	path, err := activeProfilePath()
+	if err != nil {
+		return DefaultProfile
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DefaultProfile
+	}
+
+	name := strings.TrimSpace(string(data))
+	if ValidateProfileName(name) != nil {
+		return DefaultProfile
+	}
+
+	return name
+}
+
+// HasActiveProfile reports whether a profile has been explicitly selected
+func HasActiveProfile() bool {
+	path, err := activeProfilePath()
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// SetActiveProfile selects the profile used when no other is requested
+func SetActiveProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	if err := cfg.EnsureDirectories(); err != nil {
+		return fmt.Errorf("failed to ensure directories: %w", err)
+	}
+
+	path := filepath.Join(cfg.AuthDir, "active_profile")
+	if err := os.WriteFile(path, []byte(name+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write active profile: %w", err)
+	}
+
+	return nil
+}
+
+// ListProfiles returns the names of all profiles with a saved session
+func ListProfiles() ([]string, error) {
+	path, err := sessionPath(DefaultProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []string
+	if _, err := os.Stat(path); err == nil {
+		profiles = append(profiles, DefaultProfile)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(path), "sessions"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || name == DefaultProfile {
+			continue
+		}
+		if ValidateProfileName(name) == nil {
+			profiles = append(profiles, name)
+		}
+	}
+
+	sort.Strings(profiles)
+	return profiles, nil
+}
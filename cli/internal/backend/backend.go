@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+)
+
+// Blob is one version of a project's encrypted snapshot. EncryptedData is
+// base64 encoded and Checksum is the SHA-256 of EncryptedData; backends store
+// both verbatim and never see plaintext. API backends pulled with a machine
+// token limited to some environments set Environments instead.
+type Blob struct {
+	Version       int                   `json:"version"`
+	EncryptedData string                `json:"encrypted_data"`
+	Checksum      string                `json:"checksum"`
+	Environments  []api.EnvironmentBlob `json:"environments,omitempty"`
+	UploadedAt    time.Time             `json:"uploaded_at"`
+}
+
+// SyncBackend stores versioned encrypted blobs for projects
+type SyncBackend interface {
+	// Name returns a short human readable description of the backend
+	Name() string
+
+	// Push stores a new version of the project's blob and returns the
+	// version number it was assigned
+	Push(ctx context.Context, projectID, encryptedData, checksum string) (int, error)
+
+	// Pull returns the newest blob for the project. If sinceVersion is set
+	// and nothing newer exists, Pull returns nil.
+	Pull(ctx context.Context, projectID string, sinceVersion *int) (*Blob, error)
+}
+
+// MetadataPusher is implemented by backends that also record the project's
+// name and environments, so that they can be listed before cloning and
+// served one by one to machine tokens limited to some of them
+type MetadataPusher interface {
+	PushWithMetadata(ctx context.Context, projectID, encryptedData, checksum string, meta api.PushMetadata) (int, error)
+}
+
+// PushSnapshot pushes a blob, passing meta on to backends that record it
+func PushSnapshot(ctx context.Context, b SyncBackend, projectID, encryptedData, checksum string, meta api.PushMetadata) (int, error) {
+	if pusher, ok := b.(MetadataPusher); ok {
+		return pusher.PushWithMetadata(ctx, projectID, encryptedData, checksum, meta)
+	}
+	return b.Push(ctx, projectID, encryptedData, checksum)
+}
+
+// Backend kinds accepted in remote URLs
+const (
+	KindSupabase  = "supabase"
+	KindDirectory = "file"
+	KindS3        = "s3"
+	KindGit       = "git"
+)
+
+// ErrUnavailable is returned when a backend cannot be reached, for example
+// because the network is down or a shared drive is not mounted
+var ErrUnavailable = errors.New("sync backend unavailable")
+
+// IsUnavailable reports whether err means the backend could not be reached,
+// as opposed to it rejecting the operation
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable) || api.IsNetworkError(err)
+}
+
+// unavailable wraps err so that IsUnavailable reports true for it
+func unavailable(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnavailable, fmt.Sprintf(format, args...))
+}
+
+// Options holds settings and credentials for opening a backend
+type Options struct {
+	// CacheDir is where backends such as git keep working copies
+	CacheDir string
+
+	// S3 credentials; the AWS_* environment variables are used when unset
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Transport carries HTTP requests for backends that use HTTP, so that
+	// proxy and CA settings apply; http.DefaultTransport when nil
+	Transport http.RoundTripper
+}
+
+// Kind returns the backend kind for a remote URL. An empty URL selects
+// the hosted Supabase backend.
+func Kind(rawURL string) (string, error) {
+	switch {
+	case rawURL == "", rawURL == KindSupabase:
+		return KindSupabase, nil
+	case strings.HasPrefix(rawURL, "https://"), strings.HasPrefix(rawURL, "http://"):
+		return KindSupabase, nil
+	case strings.HasPrefix(rawURL, "file://"), filepath.IsAbs(rawURL):
+		return KindDirectory, nil
+	case strings.HasPrefix(rawURL, "s3://"):
+		return KindS3, nil
+	case strings.HasPrefix(rawURL, "git+"):
+		return KindGit, nil
+	}
+
+	return "", fmt.Errorf("unsupported sync backend %q (expected supabase, file://, s3:// or git+ URL)", rawURL)
+}
+
+// Open creates a backend from a remote URL. Supabase backends need an
+// authenticated API client and are created with NewSupabase instead.
+//
+// Supported forms:
+//
+//	file:///mnt/share/envault          shared directory, NFS or USB drive
+//	s3://bucket/prefix?region=eu-west-1&endpoint=https://minio:9000
+//	git+ssh://git@host/org/secrets.git#main
+//	git+https://host/org/secrets.git
+//	git+file:///srv/git/secrets.git
+func Open(rawURL string, opts Options) (SyncBackend, error) {
+	kind, err := Kind(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case KindDirectory:
+		path := rawURL
+		if strings.HasPrefix(rawURL, "file://") {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid directory URL: %w", err)
+			}
+			path = u.Path
+		}
+		return NewDirectory(path), nil
+
+	case KindS3:
+		return NewS3FromURL(rawURL, opts)
+
+	case KindGit:
+		return NewGitFromURL(rawURL, opts.CacheDir)
+	}
+
+	return nil, fmt.Errorf("%s backend requires an API client", kind)
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Backends without a server store each version as its own JSON file named
+// v0000000001.json, v0000000002.json, ... so that creating the next version
+// can be done atomically with create-if-absent semantics.
+
+const blobFileExt = ".json"
+
+// projectIDPattern matches project IDs that are safe as a single path
+// component or object key segment: UUIDs and similar slugs
+var projectIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// checkProjectID rejects project IDs, for example from a crafted .envault
+// file, that would reach outside the backend's directory or prefix
+func checkProjectID(projectID string) error {
+	if !projectIDPattern.MatchString(projectID) {
+		return fmt.Errorf("invalid project ID %q", projectID)
+	}
+	return nil
+}
+
+// blobFileName returns the file name used for a version
+func blobFileName(version int) string {
+	return fmt.Sprintf("v%010d%s", version, blobFileExt)
+}
+
+// parseBlobFileName returns the version encoded in a blob file name
+func parseBlobFileName(name string) (int, bool) {
+	if !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, blobFileExt) {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), blobFileExt))
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// encodeBlob serialises a blob for storage
+func encodeBlob(blob *Blob) ([]byte, error) {
+	data, err := json.MarshalIndent(blob, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode blob: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// decodeBlob parses a stored blob and checks it holds the expected version
+func decodeBlob(data []byte, version int) (*Blob, error) {
+	var blob Blob
+	if err := json.Unmarshal(data, &blob); err != nil {
+		return nil, fmt.Errorf("failed to decode blob version %d: %w", version, err)
+	}
+
+	if version > 0 && blob.Version != version {
+		return nil, fmt.Errorf("blob file for version %d contains version %d", version, blob.Version)
+	}
+
+	return &blob, nil
+}
+
+// newerThan reports whether version should be returned for sinceVersion
+func newerThan(version int, sinceVersion *int) bool {
+	return sinceVersion == nil || version > *sinceVersion
+}
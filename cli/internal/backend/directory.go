@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxPushAttempts bounds how often a push retries after losing a race with
+// another writer for the same version
+const maxPushAttempts = 5
+
+// Directory syncs through a plain directory such as a shared drive, an NFS
+// mount or a USB stick. Each project gets a sub-directory holding one file
+// per version.
+type Directory struct {
+	root string
+}
+
+// NewDirectory creates a directory backend rooted at root
+func NewDirectory(root string) *Directory {
+	return &Directory{root: root}
+}
+
+// Name implements SyncBackend
+func (d *Directory) Name() string {
+	return "directory " + d.root
+}
+
+// Push implements SyncBackend
+func (d *Directory) Push(ctx context.Context, projectID, encryptedData, checksum string) (int, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return 0, err
+	}
+	if err := d.checkRoot(); err != nil {
+		return 0, err
+	}
+
+	projectDir := filepath.Join(d.root, projectID)
+	if err := os.MkdirAll(projectDir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	for attempt := 0; attempt < maxPushAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		latest, err := d.latestVersion(projectDir)
+		if err != nil {
+			return 0, err
+		}
+
+		blob := &Blob{
+			Version:       latest + 1,
+			EncryptedData: encryptedData,
+			Checksum:      checksum,
+			UploadedAt:    time.Now().UTC(),
+		}
+
+		data, err := encodeBlob(blob)
+		if err != nil {
+			return 0, err
+		}
+
+		err = writeExclusive(filepath.Join(projectDir, blobFileName(blob.Version)), data)
+		if errors.Is(err, os.ErrExist) {
+			// Another machine pushed the same version first; try the next one
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		return blob.Version, nil
+	}
+
+	return 0, fmt.Errorf("failed to push: too many concurrent writers")
+}
+
+// Pull implements SyncBackend
+func (d *Directory) Pull(ctx context.Context, projectID string, sinceVersion *int) (*Blob, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return nil, err
+	}
+	if err := d.checkRoot(); err != nil {
+		return nil, err
+	}
+
+	projectDir := filepath.Join(d.root, projectID)
+	latest, err := d.latestVersion(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == 0 || !newerThan(latest, sinceVersion) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(projectDir, blobFileName(latest)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+
+	return decodeBlob(data, latest)
+}
+
+// checkRoot distinguishes an unmounted drive from other failures
+func (d *Directory) checkRoot() error {
+	info, err := os.Stat(d.root)
+	if err != nil {
+		return unavailable("%s is not accessible: %v", d.root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("sync backend %s is not a directory", d.root)
+	}
+	return nil
+}
+
+// latestVersion returns the highest version stored in projectDir, or 0
+func (d *Directory) latestVersion(projectDir string) (int, error) {
+	entries, err := os.ReadDir(projectDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		if version, ok := parseBlobFileName(entry.Name()); ok && version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// writeExclusive writes data to path, failing with os.ErrExist if path
+// already exists. The data is written to a temporary file first and then
+// hard-linked into place so readers never observe a partial file.
+func writeExclusive(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".envault-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close blob: %w", err)
+	}
+
+	err = os.Link(tmpPath, path)
+	if err == nil || errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	// Some file systems (FAT-formatted USB drives, some SMB shares) do not
+	// support hard links; fall back to an exclusive create
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	return file.Close()
+}
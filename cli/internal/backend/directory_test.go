@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectoryPushPull(t *testing.T) {
+	ctx := context.Background()
+	d := NewDirectory(t.TempDir())
+	projectID := "7d1c4a2e-0000-4000-8000-000000000001"
+
+	for want := 1; want <= 2; want++ {
+		version, err := d.Push(ctx, projectID, "ZGF0YQ==", "checksum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want {
+			t.Errorf("pushed version %d, want %d", version, want)
+		}
+	}
+
+	blob, err := d.Pull(ctx, projectID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob == nil || blob.Version != 2 || blob.EncryptedData != "ZGF0YQ==" {
+		t.Errorf("pulled %+v, want version 2", blob)
+	}
+
+	since := 2
+	if blob, err := d.Pull(ctx, projectID, &since); err != nil || blob != nil {
+		t.Errorf("pull since the latest version gave %+v, %v; want nothing", blob, err)
+	}
+}
+
+func TestDirectoryRejectsUnsafeProjectIDs(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	root := filepath.Join(parent, "sync")
+	if err := os.Mkdir(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDirectory(root)
+
+	for _, projectID := range []string{"", ".", "..", "../escape", "a/b", `..\escape`, "/tmp/x", ".hidden", "id\n"} {
+		if _, err := d.Push(ctx, projectID, "ZGF0YQ==", "checksum"); err == nil {
+			t.Errorf("Push(%q) succeeded, want an error", projectID)
+		}
+		if _, err := d.Pull(ctx, projectID, nil); err == nil {
+			t.Errorf("Pull(%q) succeeded, want an error", projectID)
+		}
+	}
+
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("the parent of the sync directory has %d entries, want only the sync directory", len(entries))
+	}
+}
+
+func TestCheckProjectID(t *testing.T) {
+	for _, projectID := range []string{"7d1c4a2e-0000-4000-8000-000000000001", "my_project-2", "A1"} {
+		if err := checkProjectID(projectID); err != nil {
+			t.Errorf("checkProjectID(%q) = %v, want nil", projectID, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitBlobFile is the file holding a project's blob inside the repository.
+// Unlike the directory and S3 backends only the latest version is kept in
+// the tree; older versions live in the git history.
+const gitBlobFile = "blob.json"
+
+// Git syncs through a git repository. A working copy is kept in the cache
+// directory and every push becomes a commit; losing a push race is detected
+// by git rejecting a non-fast-forward push.
+type Git struct {
+	Remote string
+	Branch string
+
+	workDir string
+}
+
+// NewGitFromURL creates a git backend from a URL of the form
+// git+ssh://host/repo.git#branch. The git+ prefix is stripped before the URL
+// is handed to git, and the branch defaults to main.
+func NewGitFromURL(rawURL, cacheDir string) (*Git, error) {
+	remote := strings.TrimPrefix(rawURL, "git+")
+	branch := "main"
+	if i := strings.LastIndex(remote, "#"); i >= 0 {
+		remote, branch = remote[:i], remote[i+1:]
+	}
+
+	if remote == "" || branch == "" {
+		return nil, fmt.Errorf("invalid git URL %q", rawURL)
+	}
+
+	if cacheDir == "" {
+		return nil, fmt.Errorf("git backend requires a cache directory")
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git backend requires git to be installed: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(remote))
+	return &Git{
+		Remote:  remote,
+		Branch:  branch,
+		workDir: filepath.Join(cacheDir, "git", hex.EncodeToString(sum[:8])),
+	}, nil
+}
+
+// Name implements SyncBackend
+func (g *Git) Name() string {
+	return "git " + g.Remote + "#" + g.Branch
+}
+
+// Push implements SyncBackend
+func (g *Git) Push(ctx context.Context, projectID, encryptedData, checksum string) (int, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return 0, err
+	}
+
+	for attempt := 0; attempt < maxPushAttempts; attempt++ {
+		if err := g.sync(ctx); err != nil {
+			return 0, err
+		}
+
+		current, err := g.readBlob(projectID)
+		if err != nil {
+			return 0, err
+		}
+
+		blob := &Blob{
+			Version:       1,
+			EncryptedData: encryptedData,
+			Checksum:      checksum,
+			UploadedAt:    time.Now().UTC(),
+		}
+		if current != nil {
+			blob.Version = current.Version + 1
+		}
+
+		data, err := encodeBlob(blob)
+		if err != nil {
+			return 0, err
+		}
+
+		relPath := filepath.Join(projectID, gitBlobFile)
+		absPath := filepath.Join(g.workDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(absPath), 0700); err != nil {
+			return 0, fmt.Errorf("failed to create project directory: %w", err)
+		}
+		if err := os.WriteFile(absPath, data, 0600); err != nil {
+			return 0, fmt.Errorf("failed to write blob: %w", err)
+		}
+
+		if _, err := g.git(ctx, "add", "--", filepath.ToSlash(relPath)); err != nil {
+			return 0, err
+		}
+
+		message := fmt.Sprintf("envault: push %s version %d", projectID, blob.Version)
+		if _, err := g.git(ctx, append(g.identity(ctx), "commit", "--quiet", "-m", message)...); err != nil {
+			return 0, err
+		}
+
+		if _, err := g.git(ctx, "push", "--quiet", "origin", "HEAD:refs/heads/"+g.Branch); err != nil {
+			switch {
+			case lostPushRace(err):
+				// Someone else pushed first; refetch and try again
+				continue
+			case strings.Contains(err.Error(), "rejected"):
+				// Hooks and branch protection reject every attempt
+				return 0, fmt.Errorf("the git remote rejected the push: %v", err)
+			}
+			return 0, unavailable("git push failed: %v", err)
+		}
+
+		return blob.Version, nil
+	}
+
+	return 0, fmt.Errorf("failed to push: too many concurrent writers")
+}
+
+// Pull implements SyncBackend
+func (g *Git) Pull(ctx context.Context, projectID string, sinceVersion *int) (*Blob, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return nil, err
+	}
+	if err := g.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	blob, err := g.readBlob(projectID)
+	if err != nil || blob == nil {
+		return nil, err
+	}
+
+	if !newerThan(blob.Version, sinceVersion) {
+		return nil, nil
+	}
+
+	return blob, nil
+}
+
+// sync makes the working copy match the remote branch, cloning it first if
+// needed
+func (g *Git) sync(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.workDir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(g.workDir, 0700); err != nil {
+			return fmt.Errorf("failed to create git cache: %w", err)
+		}
+		if _, err := g.git(ctx, "init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := g.git(ctx, "remote", "add", "origin", g.Remote); err != nil {
+			return err
+		}
+	}
+
+	if _, err := g.git(ctx, "fetch", "--quiet", "origin"); err != nil {
+		return unavailable("git fetch failed: %v", err)
+	}
+
+	remoteRef := "refs/remotes/origin/" + g.Branch
+	if _, err := g.git(ctx, "rev-parse", "--verify", "--quiet", remoteRef); err != nil {
+		// Empty repository or new branch: start from an empty tree
+		_, err := g.git(ctx, "checkout", "--quiet", "--orphan", g.Branch)
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			return err
+		}
+		return nil
+	}
+
+	if _, err := g.git(ctx, "checkout", "--quiet", "-B", g.Branch, remoteRef); err != nil {
+		return err
+	}
+	if _, err := g.git(ctx, "reset", "--quiet", "--hard", remoteRef); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readBlob reads the project's blob from the working copy, or nil if the
+// project has never been pushed
+func (g *Git) readBlob(projectID string) (*Blob, error) {
+	data, err := os.ReadFile(filepath.Join(g.workDir, projectID, gitBlobFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+
+	return decodeBlob(data, 0)
+}
+
+// identity supplies a committer identity when the user has none configured
+func (g *Git) identity(ctx context.Context) []string {
+	if email, err := g.git(ctx, "config", "user.email"); err == nil && email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=EnvVault CLI", "-c", "user.email=envault@localhost"}
+}
+
+// lostPushRace reports whether a push failed only because the branch moved
+// since it was fetched, or was being moved by another push at the same
+// moment ("cannot lock ref"), as opposed to a hook or branch protection on
+// the remote
+func lostPushRace(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "(fetch first)") || strings.Contains(msg, "(non-fast-forward)") ||
+		strings.Contains(msg, "cannot lock ref")
+}
+
+// git runs a git command in the working copy and returns its trimmed output
+func (g *Git) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.workDir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,415 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 syncs through an S3-compatible bucket (AWS S3, MinIO, R2, B2, ...).
+// Each version is stored as its own object under <prefix>/<projectID>/ and
+// created with If-None-Match so two pushes cannot claim the same version.
+type S3 struct {
+	Bucket    string
+	Prefix    string
+	Region    string
+	Endpoint  string
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	httpClient *http.Client
+}
+
+// NewS3FromURL creates an S3 backend from a URL of the form
+// s3://bucket/prefix?region=REGION&endpoint=URL&path_style=true.
+// Credentials come from opts, falling back to AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func NewS3FromURL(rawURL string, opts Options) (*S3, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URL: %w", err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q: missing bucket name", rawURL)
+	}
+
+	query := u.Query()
+	s := &S3{
+		Bucket:          u.Host,
+		Prefix:          strings.Trim(u.Path, "/"),
+		Region:          query.Get("region"),
+		Endpoint:        strings.TrimSuffix(query.Get("endpoint"), "/"),
+		AccessKeyID:     opts.AccessKeyID,
+		SecretAccessKey: opts.SecretAccessKey,
+		SessionToken:    opts.SessionToken,
+		httpClient:      &http.Client{Transport: opts.Transport, Timeout: 30 * time.Second},
+	}
+
+	if s.AccessKeyID == "" {
+		s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	if s.Region == "" {
+		s.Region = os.Getenv("AWS_REGION")
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+
+	// Custom endpoints (MinIO and friends) usually only support path-style
+	s.PathStyle = s.Endpoint != ""
+	if v := query.Get("path_style"); v != "" {
+		s.PathStyle = v == "true" || v == "1"
+	}
+
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 backend requires credentials (envault remote add --access-key-id, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+
+	return s, nil
+}
+
+// Name implements SyncBackend
+func (s *S3) Name() string {
+	if s.Prefix == "" {
+		return "s3://" + s.Bucket
+	}
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+// Push implements SyncBackend
+func (s *S3) Push(ctx context.Context, projectID, encryptedData, checksum string) (int, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return 0, err
+	}
+
+	for attempt := 0; attempt < maxPushAttempts; attempt++ {
+		latest, err := s.latestVersion(ctx, projectID)
+		if err != nil {
+			return 0, err
+		}
+
+		blob := &Blob{
+			Version:       latest + 1,
+			EncryptedData: encryptedData,
+			Checksum:      checksum,
+			UploadedAt:    time.Now().UTC(),
+		}
+
+		data, err := encodeBlob(blob)
+		if err != nil {
+			return 0, err
+		}
+
+		headers := map[string]string{
+			"Content-Type":  "application/json",
+			"If-None-Match": "*",
+		}
+
+		resp, err := s.do(ctx, http.MethodPut, s.objectKey(projectID, blobFileName(blob.Version)), nil, headers, data)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusPreconditionFailed, resp.StatusCode == http.StatusConflict:
+			// Another machine pushed the same version first; try the next one
+			continue
+		case resp.StatusCode >= 300:
+			return 0, fmt.Errorf("failed to upload blob: S3 returned status %d", resp.StatusCode)
+		}
+
+		return blob.Version, nil
+	}
+
+	return 0, fmt.Errorf("failed to push: too many concurrent writers")
+}
+
+// Pull implements SyncBackend
+func (s *S3) Pull(ctx context.Context, projectID string, sinceVersion *int) (*Blob, error) {
+	if err := checkProjectID(projectID); err != nil {
+		return nil, err
+	}
+
+	latest, err := s.latestVersion(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == 0 || !newerThan(latest, sinceVersion) {
+		return nil, nil
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.objectKey(projectID, blobFileName(latest)), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download blob: S3 returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, unavailable("failed to read blob: %v", err)
+	}
+
+	return decodeBlob(data, latest)
+}
+
+// listBucketResult is the subset of ListObjectsV2 output we need
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// latestVersion lists the project's objects and returns the highest version
+func (s *S3) latestVersion(ctx context.Context, projectID string) (int, error) {
+	prefix := s.objectKey(projectID, "")
+	latest := 0
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, unavailable("failed to read bucket listing: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("failed to list bucket: S3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return 0, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			if version, ok := parseBlobFileName(path.Base(obj.Key)); ok && version > latest {
+				latest = version
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return latest, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// objectKey returns the key for a file in the project's folder
+func (s *S3) objectKey(projectID, name string) string {
+	key := projectID + "/" + name
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	return key
+}
+
+// do sends a signed request for key (or the bucket itself if key is empty)
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, headers map[string]string, body []byte) (*http.Response, error) {
+	var host, basePath, scheme string
+	switch {
+	case s.Endpoint != "":
+		endpoint, err := url.Parse(s.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+		}
+		scheme, host, basePath = endpoint.Scheme, endpoint.Host, strings.TrimSuffix(endpoint.Path, "/")
+	default:
+		scheme, host = "https", fmt.Sprintf("s3.%s.amazonaws.com", s.Region)
+	}
+
+	objectPath := basePath + "/"
+	if s.PathStyle {
+		objectPath += s.Bucket + "/"
+	} else {
+		host = s.Bucket + "." + host
+	}
+	objectPath += key
+
+	reqURL := &url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     objectPath,
+		RawPath:  s3EscapePath(objectPath),
+		RawQuery: s3CanonicalQuery(query),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request cancelled: %w", ctx.Err())
+		}
+		return nil, unavailable("%v", err)
+	}
+
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	// Sign every header we set plus host
+	signed := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower != "host" {
+			signed = append(signed, lower)
+		}
+	}
+	sort.Strings(signed)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature,
+	))
+	req.Header.Del("Host")
+}
+
+// s3Escape percent-encodes everything except the unreserved characters, as
+// required by SigV4
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3EscapePath escapes each path segment but keeps the slashes
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes query parameters sorted by key
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/dj-pearson/envault/internal/api"
+)
+
+// Supabase syncs through the hosted EnvVault API
+type Supabase struct {
+	client *api.Client
+}
+
+// NewSupabase wraps an authenticated API client as a sync backend
+func NewSupabase(client *api.Client) *Supabase {
+	return &Supabase{client: client}
+}
+
+// Name implements SyncBackend
+func (s *Supabase) Name() string {
+	return "EnvVault cloud"
+}
+
+// Push implements SyncBackend
+func (s *Supabase) Push(ctx context.Context, projectID, encryptedData, checksum string) (int, error) {
+	return s.PushWithMetadata(ctx, projectID, encryptedData, checksum, api.PushMetadata{})
+}
+
+// PushWithMetadata implements MetadataPusher
+func (s *Supabase) PushWithMetadata(ctx context.Context, projectID, encryptedData, checksum string, meta api.PushMetadata) (int, error) {
+	resp, err := s.client.PushEncryptedBlob(ctx, projectID, encryptedData, checksum, meta)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+// Pull implements SyncBackend
+func (s *Supabase) Pull(ctx context.Context, projectID string, sinceVersion *int) (*Blob, error) {
+	resp, err := s.client.PullEncryptedBlob(ctx, projectID, sinceVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.HasUpdate {
+		return nil, nil
+	}
+
+	return &Blob{
+		Version:       resp.Version,
+		EncryptedData: resp.EncryptedData,
+		Checksum:      resp.Checksum,
+		Environments:  resp.Environments,
+		UploadedAt:    resp.UploadedAt,
+	}, nil
+}
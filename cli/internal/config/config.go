@@ -15,6 +15,7 @@ const (
 	AuthFile    = "auth/session.json"
 	KeysDir     = "auth/keys"
 	CacheDir    = "cache"
+	RunDir      = "run"
 )
 
 // Config holds the global configuration
@@ -25,6 +26,7 @@ type Config struct {
 	AuthDir    string
 	KeysDir    string
 	CacheDir   string
+	RunDir     string
 	ConfigFile string
 	DBPath     string
 }
@@ -45,6 +47,7 @@ func New() (*Config, error) {
 		AuthDir:    filepath.Join(baseDir, "auth"),
 		KeysDir:    filepath.Join(baseDir, "auth", "keys"),
 		CacheDir:   filepath.Join(baseDir, "cache"),
+		RunDir:     filepath.Join(baseDir, "run"),
 		ConfigFile: filepath.Join(baseDir, ConfigFile),
 		DBPath:     filepath.Join(baseDir, "data", DBFile),
 	}
@@ -60,6 +63,7 @@ func (c *Config) EnsureDirectories() error {
 		c.AuthDir,
 		c.KeysDir,
 		c.CacheDir,
+		c.RunDir,
 	}
 
 	for _, dir := range dirs {
@@ -0,0 +1,318 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/utils"
+)
+
+// Status describes the state of a running sync watcher. It is what the
+// status socket returns to `envault daemon status`.
+type Status struct {
+	PID        int        `json:"pid"`
+	ProjectID  string     `json:"project_id"`
+	Project    string     `json:"project"`
+	State      string     `json:"state"`
+	Interval   string     `json:"interval"`
+	StartedAt  time.Time  `json:"started_at"`
+	LastPullAt *time.Time `json:"last_pull_at,omitempty"`
+	LastPushAt *time.Time `json:"last_push_at,omitempty"`
+	NextPollAt *time.Time `json:"next_poll_at,omitempty"`
+	Version    int        `json:"version"`
+	Pending    int        `json:"pending"`
+	Failures   int        `json:"failures"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Watcher states
+const (
+	StateIdle     = "idle"
+	StateSyncing  = "syncing"
+	StateBackoff  = "backoff"
+	StateStopping = "stopping"
+)
+
+// State is a concurrency-safe holder for the watcher status
+type State struct {
+	mu     sync.Mutex
+	status Status
+}
+
+// NewState creates the initial state for a watcher
+func NewState(projectID, projectName string, interval time.Duration) *State {
+	return &State{
+		status: Status{
+			PID:       os.Getpid(),
+			ProjectID: projectID,
+			Project:   projectName,
+			State:     StateIdle,
+			Interval:  interval.String(),
+			StartedAt: time.Now(),
+		},
+	}
+}
+
+// Update applies fn to the status under the lock
+func (s *State) Update(fn func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+}
+
+// Snapshot returns a copy of the current status
+func (s *State) Snapshot() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Paths returns the PID file and status socket paths for a project
+func Paths(runDir, projectID string) (pidPath, socketPath string) {
+	return filepath.Join(runDir, projectID+".pid"), filepath.Join(runDir, projectID+".sock")
+}
+
+// ErrNotRunning is returned when no watcher is running for a project
+var ErrNotRunning = errors.New("sync watcher is not running")
+
+// pidFileStartGrace is how long an empty PID file is taken to belong to a
+// watcher that has created it but not yet written its PID
+const pidFileStartGrace = 5 * time.Second
+
+// AcquirePIDFile writes the current PID to path. The file is created
+// exclusively, so of two watchers started at the same time only one gets
+// it. It fails if another live process holds the file; a file left by a
+// watcher that has exited is removed and the creation retried once.
+func AcquirePIDFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), utils.SecureDirMode); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	content := strconv.Itoa(os.Getpid()) + "\n"
+	for attempt := 0; ; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, utils.SecureFileMode)
+		if err == nil {
+			_, err = file.WriteString(content)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return fmt.Errorf("failed to write PID file: %w", err)
+			}
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create PID file: %w", err)
+		}
+
+		if attempt > 0 {
+			return fmt.Errorf("sync watcher is starting for this project; try again")
+		}
+		if err := removeStalePIDFile(path); err != nil {
+			return err
+		}
+	}
+}
+
+// removeStalePIDFile removes the PID file at path if the process it names
+// has exited. It fails if the process is alive, or if the file is empty
+// because a watcher is still writing it.
+func removeStalePIDFile(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 && time.Since(info.ModTime()) < pidFileStartGrace {
+		return fmt.Errorf("sync watcher is starting for this project; try again")
+	}
+	if pid, err := ReadPIDFile(path); err == nil {
+		return fmt.Errorf("sync watcher already running for this project (pid %d)", pid)
+	}
+
+	// Only remove the file if another watcher hasn't replaced it meanwhile
+	if current, err := os.ReadFile(path); err == nil && string(current) == string(data) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale PID file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ReadPIDFile returns the PID of a live watcher, or ErrNotRunning if the file
+// is missing or refers to a process that has exited
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, ErrNotRunning
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, ErrNotRunning
+	}
+
+	if !processAlive(pid) {
+		return 0, ErrNotRunning
+	}
+
+	return pid, nil
+}
+
+// ReleasePIDFile removes the PID file if it still belongs to this process
+func ReleasePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
+
+// Server answers status queries on a local socket
+type Server struct {
+	listener net.Listener
+	path     string
+	state    *State
+	stop     func()
+	wg       sync.WaitGroup
+}
+
+// Listen starts the status socket at path. Sending "stop" to the socket
+// calls stop, which should cancel the watcher. Callers should hold the PID
+// file so that two watchers never race for the socket.
+func Listen(path string, state *State, stop func()) (*Server, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		// A socket left behind by a crashed watcher blocks Listen. Only
+		// remove it once nothing answers on it, or we would take the
+		// socket away from a live watcher.
+		if !isStaleSocket(path) {
+			return nil, fmt.Errorf("failed to open status socket: %w", err)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale status socket: %w", err)
+		}
+		if listener, err = net.Listen("unix", path); err != nil {
+			return nil, fmt.Errorf("failed to open status socket: %w", err)
+		}
+	}
+
+	if err := os.Chmod(path, utils.SecureFileMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to secure status socket: %w", err)
+	}
+
+	s := &Server{
+		listener: listener,
+		path:     path,
+		state:    state,
+		stop:     stop,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// isStaleSocket reports whether path is a socket nobody is listening on
+func isStaleSocket(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		// A watcher too busy to accept in time is still alive
+		var netErr net.Error
+		return !(errors.As(err, &netErr) && netErr.Timeout())
+	}
+	conn.Close()
+	return false
+}
+
+// Close stops accepting connections and removes the socket
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	switch strings.TrimSpace(line) {
+	case "stop":
+		s.state.Update(func(st *Status) { st.State = StateStopping })
+		json.NewEncoder(conn).Encode(s.state.Snapshot())
+		s.stop()
+	default:
+		json.NewEncoder(conn).Encode(s.state.Snapshot())
+	}
+}
+
+// Query sends a command ("status" or "stop") to the watcher listening on
+// socketPath and returns its status
+func Query(socketPath, command string) (*Status, error) {
+	conn, err := net.DialTimeout("unix", socketPath, 2*time.Second)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return nil, fmt.Errorf("failed to query sync watcher: %w", err)
+	}
+
+	var status Status
+	if err := json.NewDecoder(conn).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to read sync watcher status: %w", err)
+	}
+
+	return &status, nil
+}
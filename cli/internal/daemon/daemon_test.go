@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListenKeepsLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.sock")
+
+	first, err := Listen(path, NewState("p", "app", time.Minute), func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if _, err := Listen(path, NewState("p", "app", time.Minute), func() {}); err == nil {
+		t.Fatal("a second Listen on a live socket succeeded")
+	}
+
+	status, err := Query(path, "status")
+	if err != nil {
+		t.Fatalf("the first watcher's socket stopped answering: %v", err)
+	}
+	if status.PID != os.Getpid() {
+		t.Errorf("got status for pid %d, want %d", status.PID, os.Getpid())
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.sock")
+
+	// A listener closed without unlinking leaves the socket file behind,
+	// like a crashed watcher
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("the stale socket is gone: %v", err)
+	}
+
+	server, err := Listen(path, NewState("p", "app", time.Minute), func() {})
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	defer server.Close()
+
+	if _, err := Query(path, "status"); err != nil {
+		t.Errorf("the new socket does not answer: %v", err)
+	}
+}
+
+func TestListenLeavesOtherFilesAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.sock")
+	if err := os.WriteFile(path, []byte("not a socket"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Listen(path, NewState("p", "app", time.Minute), func() {}); err == nil {
+		t.Fatal("Listen replaced a regular file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "not a socket" {
+		t.Errorf("the file was changed: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID exists (Unix)
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// Signal 0 performs error checking only
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
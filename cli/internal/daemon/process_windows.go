@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import "syscall"
+
+const processQueryLimitedInformation = 0x1000
+
+// processAlive reports whether a process with the given PID exists (Windows)
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+
+	// STILL_ACTIVE
+	return exitCode == 259
+}
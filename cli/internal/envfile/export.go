@@ -0,0 +1,167 @@
+// Package envfile reads and writes variables in the file formats other
+// tools use: dotenv, shell scripts, Kubernetes manifests and so on. Keys
+// are expected to be valid environment variable names; values may contain
+// anything and are quoted so that the target tool reads back exactly the
+// same value, or rejected where the format cannot represent them.
+package envfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Options are settings some formats need
+type Options struct {
+	// Name and Namespace go into the metadata of Kubernetes manifests
+	Name      string
+	Namespace string
+}
+
+// lineFormat writes one KEY=value line
+type lineFormat func(key, value string) (string, error)
+
+// lineFormats are the formats with one line (or quoted block) per variable
+var lineFormats = map[string]lineFormat{
+	"dotenv":     formatDotenv,
+	"docker":     formatDocker,
+	"systemd":    formatSystemd,
+	"tfvars":     formatTfvars,
+	"bash":       formatBash,
+	"fish":       formatFish,
+	"powershell": formatPowerShell,
+	"properties": formatProperties,
+	"toml":       formatTOML,
+}
+
+// documentFormat writes a whole document
+type documentFormat func(vars map[string]string, opts Options) ([]byte, error)
+
+var documentFormats = map[string]documentFormat{
+	"json":          exportJSON,
+	"yaml":          exportYAML,
+	"k8s-secret":    exportKubernetesSecret,
+	"k8s-configmap": exportKubernetesConfigMap,
+}
+
+// aliases are other names accepted for formats
+var aliases = map[string]string{
+	"env":        "dotenv",
+	".env":       "dotenv",
+	"yml":        "yaml",
+	"export":     "bash",
+	"sh":         "bash",
+	"zsh":        "bash",
+	"pwsh":       "powershell",
+	"ps1":        "powershell",
+	"terraform":  "tfvars",
+	"java":       "properties",
+	"secret":     "k8s-secret",
+	"configmap":  "k8s-configmap",
+	"docker-env": "docker",
+}
+
+// Formats returns the names of the supported export formats
+func Formats() []string {
+	names := make([]string, 0, len(lineFormats)+len(documentFormats))
+	for name := range lineFormats {
+		names = append(names, name)
+	}
+	for name := range documentFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Canonical returns the canonical name of a format, resolving aliases
+func Canonical(format string) (string, error) {
+	format = strings.ToLower(format)
+	if name, ok := aliases[format]; ok {
+		format = name
+	}
+
+	if _, ok := lineFormats[format]; ok {
+		return format, nil
+	}
+	if _, ok := documentFormats[format]; ok {
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats(), ", "))
+}
+
+// Export writes vars in the given format, sorted by key
+func Export(format string, vars map[string]string, opts Options) ([]byte, error) {
+	format, err := Canonical(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if document, ok := documentFormats[format]; ok {
+		return document(vars, opts)
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	line := lineFormats[format]
+	var buf bytes.Buffer
+	for _, key := range keys {
+		out, err := line(key, vars[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(out)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// FormatVar formats a single variable. JSON and YAML give the fragment for
+// the variable, e.g. "KEY": "value"; Kubernetes manifests are only written
+// whole.
+func FormatVar(format, key, value string) (string, error) {
+	format, err := Canonical(format)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "json":
+		keyJSON, _ := json.Marshal(key)
+		valueJSON, _ := json.Marshal(value)
+		return fmt.Sprintf("%s: %s", keyJSON, valueJSON), nil
+	case "yaml":
+		out, err := marshalYAML(map[string]string{key: value})
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(out), "\n"), nil
+	case "k8s-secret", "k8s-configmap":
+		return "", fmt.Errorf("%s is only available for whole environments (see 'envault export')", format)
+	}
+
+	return lineFormats[format](key, value)
+}
+
+// exportJSON writes a JSON object
+func exportJSON(vars map[string]string, opts Options) ([]byte, error) {
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// exportYAML writes a YAML mapping; the encoder quotes values that would
+// otherwise read back as numbers, booleans or null
+func exportYAML(vars map[string]string, opts Options) ([]byte, error) {
+	return marshalYAML(vars)
+}
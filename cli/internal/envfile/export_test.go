@@ -0,0 +1,241 @@
+package envfile
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenVars are values that need quoting or escaping in at least one
+// format
+var goldenVars = map[string]string{
+	"PLAIN":              "postgres://db.internal:5432/app",
+	"EMPTY":              "",
+	"SPACES":             "hello world",
+	"LEADING_SPACE":      "  padded",
+	"MULTILINE":          "-----BEGIN KEY-----\nabc\ndef\n-----END KEY-----\n",
+	"DOUBLE_QUOTES":      `say "hi"`,
+	"QUOTED":             `"quoted"`,
+	"SINGLE_QUOTE":       "it's",
+	"TYPOGRAPHIC_QUOTE":  "it’s",
+	"DOLLAR":             "pa$$word",
+	"TEMPLATE":           "${HOME}/bin and %{ if x }",
+	"BACKTICK":           "`whoami`",
+	"BACKSLASHES":        `C:\Users\me\new`,
+	"TRAILING_BACKSLASH": `ends with \`,
+	"HASH":               "value #not-a-comment",
+	"TAB":                "a\tb",
+	"NON_ASCII":          "héllo wörld ✓ 🔑",
+	"YAML_SPECIAL":       "true",
+}
+
+// singleLine returns the vars without line breaks
+func singleLine(vars map[string]string) map[string]string {
+	out := make(map[string]string)
+	for key, value := range vars {
+		if !strings.ContainsAny(value, "\r\n") {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func TestExportGolden(t *testing.T) {
+	opts := Options{Name: "my-app-production", Namespace: "staging"}
+
+	tests := []struct {
+		format string
+		vars   map[string]string
+	}{
+		{"dotenv", goldenVars},
+		{"docker", singleLine(goldenVars)},
+		{"systemd", goldenVars},
+		{"tfvars", goldenVars},
+		{"bash", goldenVars},
+		{"fish", goldenVars},
+		{"powershell", goldenVars},
+		{"properties", goldenVars},
+		{"toml", goldenVars},
+		{"json", goldenVars},
+		{"yaml", goldenVars},
+		{"k8s-secret", goldenVars},
+		{"k8s-configmap", goldenVars},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Export(tt.format, tt.vars, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "export", tt.format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("output differs from %s (run go test -update to rewrite it):\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestExportGoldenCoversEveryFormat(t *testing.T) {
+	for _, format := range Formats() {
+		if _, err := os.Stat(filepath.Join("testdata", "export", format+".golden")); err != nil {
+			t.Errorf("no golden file for %s", format)
+		}
+	}
+}
+
+// TestExportRoundTrip reads back the formats envault also imports
+func TestExportRoundTrip(t *testing.T) {
+	opts := Options{Name: "app"}
+
+	for _, format := range []string{"dotenv", "json", "yaml", "k8s-secret", "k8s-configmap"} {
+		t.Run(format, func(t *testing.T) {
+			data, err := Export(format, goldenVars, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := Parse(format, "", data, ParseOptions{})
+			if err != nil {
+				t.Fatalf("reading back %s: %v", format, err)
+			}
+
+			for key, want := range goldenVars {
+				if got[key] != want {
+					t.Errorf("%s: got %q, want %q", key, got[key], want)
+				}
+			}
+			if len(got) != len(goldenVars) {
+				t.Errorf("got %d variables, want %d", len(got), len(goldenVars))
+			}
+		})
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		vars   map[string]string
+		opts   Options
+		want   string
+	}{
+		{
+			name:   "docker line break",
+			format: "docker",
+			vars:   map[string]string{"KEY": "a\nb"},
+			want:   "KEY: Docker env files cannot hold values with line breaks",
+		},
+		{
+			name:   "dotenv unwritable value",
+			format: "dotenv",
+			vars:   map[string]string{"KEY": "\"it's\"\nquoted"},
+			want:   "KEY: the value cannot be written to a dotenv file",
+		},
+		{
+			name:   "unknown format",
+			format: "ini",
+			vars:   goldenVars,
+			want:   `unknown format "ini"`,
+		},
+		{
+			name:   "invalid Kubernetes name",
+			format: "k8s-secret",
+			vars:   goldenVars,
+			opts:   Options{Name: "My App"},
+			want:   `invalid Kubernetes name "My App"`,
+		},
+		{
+			name:   "invalid Kubernetes namespace",
+			format: "k8s-configmap",
+			vars:   goldenVars,
+			opts:   Options{Name: "app", Namespace: "Prod_1"},
+			want:   `invalid Kubernetes namespace "Prod_1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Export(tt.format, tt.vars, tt.opts)
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := map[string]string{
+		"env":       "dotenv",
+		"DOTENV":    "dotenv",
+		"zsh":       "bash",
+		"pwsh":      "powershell",
+		"terraform": "tfvars",
+		"java":      "properties",
+		"configmap": "k8s-configmap",
+		"yml":       "yaml",
+	}
+
+	for format, want := range tests {
+		got, err := Canonical(format)
+		if err != nil || got != want {
+			t.Errorf("Canonical(%q) = %q, %v; want %q", format, got, err, want)
+		}
+	}
+}
+
+func TestFormatVar(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", `"KEY": "a \"b\"\n"`},
+		{"yaml", "KEY: |\n  a \"b\""},
+		{"bash", `export KEY='a "b"` + "\n'"},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatVar(tt.format, "KEY", "a \"b\"\n")
+		if err != nil || got != tt.want {
+			t.Errorf("FormatVar(%q) = %q, %v; want %q", tt.format, got, err, tt.want)
+		}
+	}
+
+	if _, err := FormatVar("k8s-secret", "KEY", "value"); err == nil {
+		t.Error("FormatVar(k8s-secret) succeeded, want an error")
+	}
+}
+
+func TestKubernetesName(t *testing.T) {
+	tests := map[string]string{
+		"My App production":      "my-app-production",
+		"api":                    "api",
+		"--Web__Frontend--":      "web-frontend",
+		"café":                   "caf",
+		strings.Repeat("a", 300): strings.Repeat("a", 253),
+	}
+
+	for name, want := range tests {
+		if got := KubernetesName(name); got != want {
+			t.Errorf("KubernetesName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
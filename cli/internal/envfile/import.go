@@ -0,0 +1,398 @@
+package envfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseOptions control how structured files become variables
+type ParseOptions struct {
+	// Separator joins the keys of nested JSON and YAML objects, so
+	// {"db": {"host": ...}} becomes db_host (default "_")
+	Separator string
+	// Service picks one service of a docker-compose file; by default the
+	// environments of all services are merged
+	Service string
+}
+
+// ImportFormats are the formats Parse reads
+var ImportFormats = []string{"dotenv", "json", "yaml", "k8s-secret", "compose", "raw"}
+
+// importAliases are other names accepted for import formats
+var importAliases = map[string]string{
+	"env":            "dotenv",
+	".env":           "dotenv",
+	"yml":            "yaml",
+	"secret":         "k8s-secret",
+	"k8s-configmap":  "k8s-secret",
+	"configmap":      "k8s-secret",
+	"docker-compose": "compose",
+	"heroku":         "raw",
+	"vercel":         "raw",
+}
+
+// rawLinePattern matches KEY=VALUE and KEY: VALUE lines of raw dumps
+var rawLinePattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(=|:\s*)(.*)$`)
+
+// Parse reads variables from data in the given format, or detects the
+// format if it is "" or "auto". name is the file name, used for detection.
+// It returns the format that was used.
+func Parse(format, name string, data []byte, opts ParseOptions) (map[string]string, string, error) {
+	if opts.Separator == "" {
+		opts.Separator = "_"
+	}
+
+	format = strings.ToLower(format)
+	if format == "" || format == "auto" {
+		format = Detect(name, data)
+	}
+	if alias, ok := importAliases[format]; ok {
+		format = alias
+	}
+
+	var vars map[string]string
+	var err error
+	switch format {
+	case "dotenv":
+		vars, err = godotenv.Unmarshal(string(data))
+	case "json":
+		vars, err = parseJSON(data, opts)
+	case "yaml":
+		vars, err = parseYAML(data, opts)
+	case "k8s-secret":
+		vars, err = parseKubernetes(data)
+	case "compose":
+		vars, err = parseCompose(data, opts)
+	case "raw":
+		vars, err = parseRaw(data)
+	default:
+		return nil, "", fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("invalid %s: %w", format, err)
+	}
+
+	return vars, format, nil
+}
+
+// Detect guesses the format of a file from its name and content
+func Detect(name string, data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	base := strings.ToLower(filepath.Base(name))
+
+	if bytes.HasPrefix(trimmed, []byte("{")) || strings.HasSuffix(base, ".json") {
+		return "json"
+	}
+
+	ext := filepath.Ext(base)
+	if ext == ".yml" || ext == ".yaml" || yamlDocument(trimmed) {
+		var doc struct {
+			Kind     string                 `yaml:"kind"`
+			Services map[string]interface{} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(data, &doc); err == nil {
+			switch {
+			case doc.Kind == "Secret" || doc.Kind == "ConfigMap":
+				return "k8s-secret"
+			case doc.Services != nil:
+				return "compose"
+			}
+		}
+		if ext == ".yml" || ext == ".yaml" {
+			return "yaml"
+		}
+	}
+
+	// heroku config prints a "=== app Config Vars" header and KEY: value,
+	// and heroku config --shell quotes values as a shell would
+	if bytes.HasPrefix(trimmed, []byte("===")) {
+		return "raw"
+	}
+	if _, err := godotenv.Unmarshal(string(data)); err != nil {
+		if _, err := parseRaw(data); err == nil {
+			return "raw"
+		}
+	}
+
+	return "dotenv"
+}
+
+// yamlDocument reports whether data looks like a YAML manifest rather than
+// a list of variables
+func yamlDocument(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("---")) || bytes.HasPrefix(data, []byte("apiVersion:")) ||
+		bytes.HasPrefix(data, []byte("services:")) || bytes.HasPrefix(data, []byte("version:"))
+}
+
+// parseJSON reads a JSON object, flattening nested objects and arrays
+func parseJSON(data []byte, opts ParseOptions) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	if err := flatten(vars, "", doc, opts.Separator); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseYAML reads a YAML mapping, flattening nested mappings and lists
+func parseYAML(data []byte, opts ParseOptions) (map[string]string, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	if err := flatten(vars, "", doc, opts.Separator); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// flatten stores value under prefix, joining nested keys with sep
+func flatten(vars map[string]string, prefix string, value interface{}, sep string) error {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + sep + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if err := flatten(vars, join(key), child, sep); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if err := flatten(vars, join(strconv.Itoa(i)), child, sep); err != nil {
+				return err
+			}
+		}
+	default:
+		if prefix == "" {
+			return fmt.Errorf("expected an object at the top level")
+		}
+		if _, ok := vars[prefix]; ok {
+			return fmt.Errorf("%s is defined twice after flattening", prefix)
+		}
+		vars[prefix] = scalarString(v)
+	}
+
+	return nil
+}
+
+// scalarString formats a JSON or YAML scalar as a variable value
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseKubernetes reads the data (base64) and stringData of a Secret, or
+// the data of a ConfigMap
+func parseKubernetes(data []byte) (map[string]string, error) {
+	var doc struct {
+		Kind       string            `yaml:"kind"`
+		Data       map[string]string `yaml:"data"`
+		StringData map[string]string `yaml:"stringData"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	switch doc.Kind {
+	case "Secret":
+		for key, encoded := range doc.Data {
+			value, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not valid base64: %w", key, err)
+			}
+			vars[key] = string(value)
+		}
+		for key, value := range doc.StringData {
+			vars[key] = value
+		}
+	case "ConfigMap":
+		for key, value := range doc.Data {
+			vars[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("expected a Secret or ConfigMap, not %q", doc.Kind)
+	}
+
+	return vars, nil
+}
+
+// parseCompose reads the environment: blocks of a docker-compose file,
+// written either as a mapping or as a list of KEY=VALUE
+func parseCompose(data []byte, opts ParseOptions) (map[string]string, error) {
+	var doc struct {
+		Services map[string]struct {
+			Environment yaml.Node `yaml:"environment"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(doc.Services))
+	for name := range doc.Services {
+		if opts.Service == "" || name == opts.Service {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		if opts.Service != "" {
+			return nil, fmt.Errorf("no service named %q", opts.Service)
+		}
+		return nil, fmt.Errorf("no services found")
+	}
+	sort.Strings(names)
+
+	vars := make(map[string]string)
+	from := make(map[string]string)
+	for _, name := range names {
+		service := doc.Services[name]
+		env, err := composeEnvironment(&service.Environment)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		for key, value := range env {
+			if existing, ok := vars[key]; ok && existing != value {
+				return nil, fmt.Errorf("%s differs between services %s and %s (pick one with --service)", key, from[key], name)
+			}
+			vars[key] = value
+			from[key] = name
+		}
+	}
+
+	return vars, nil
+}
+
+// composeEnvironment reads one service's environment. Variables listed
+// without a value take it from the shell in Compose and are skipped here.
+func composeEnvironment(node *yaml.Node) (map[string]string, error) {
+	env := make(map[string]string)
+
+	switch node.Kind {
+	case 0:
+		// No environment block
+	case yaml.MappingNode:
+		var values map[string]interface{}
+		if err := node.Decode(&values); err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			if value != nil {
+				env[key] = scalarString(value)
+			}
+		}
+	case yaml.SequenceNode:
+		var entries []string
+		if err := node.Decode(&entries); err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				env[key] = value
+			}
+		}
+	default:
+		return nil, fmt.Errorf("environment must be a mapping or a list")
+	}
+
+	return env, nil
+}
+
+// parseRaw reads KEY=VALUE or KEY: VALUE lines as copied from Heroku,
+// Vercel and other dashboards. Values are literal, except that one layer of
+// surrounding quotes is removed: single quotes as a shell would, double
+// quotes with \n, \" and \\ escapes.
+func parseRaw(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "===") {
+			continue
+		}
+
+		match := rawLinePattern.FindStringSubmatch(strings.TrimPrefix(trimmed, "export "))
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		vars[match[1]] = unquoteRaw(match[3])
+	}
+
+	return vars, scanner.Err()
+}
+
+// unquoteRaw removes one layer of quotes from a raw value
+func unquoteRaw(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+
+	switch {
+	case value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], `'\''`, "'")
+	case value[0] == '"' && value[len(value)-1] == '"':
+		replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\"`, `"`, `\\`, `\`)
+		return replacer.Replace(value[1 : len(value)-1])
+	}
+
+	return value
+}
+
+// FromEnviron returns the variables of environ (as from os.Environ) whose
+// names match the glob pattern, e.g. MYAPP_*
+func FromEnviron(environ []string, pattern string) (map[string]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	vars := make(map[string]string)
+	for _, entry := range environ {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			vars[key] = value
+		}
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,100 @@
+package envfile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubernetesNamePattern matches valid object names (DNS subdomains)
+var kubernetesNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// kubernetesObject is the part of a Secret or ConfigMap manifest envault
+// writes
+type kubernetesObject struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Type       string             `yaml:"type,omitempty"`
+	Data       map[string]string  `yaml:"data"`
+}
+
+type kubernetesMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// KubernetesName turns a name such as "My App production" into a valid
+// object name ("my-app-production")
+func KubernetesName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	out := strings.TrimSuffix(b.String(), "-")
+	if len(out) > 253 {
+		out = strings.TrimSuffix(out[:253], "-")
+	}
+	return out
+}
+
+// exportKubernetesSecret writes an Opaque Secret with base64 data, which
+// carries any value unchanged
+func exportKubernetesSecret(vars map[string]string, opts Options) ([]byte, error) {
+	data := make(map[string]string, len(vars))
+	for key, value := range vars {
+		data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return kubernetesManifest("Secret", "Opaque", data, opts)
+}
+
+// exportKubernetesConfigMap writes a ConfigMap with the values as plain
+// strings
+func exportKubernetesConfigMap(vars map[string]string, opts Options) ([]byte, error) {
+	return kubernetesManifest("ConfigMap", "", vars, opts)
+}
+
+// kubernetesManifest writes a v1 object with string data
+func kubernetesManifest(kind, objectType string, data map[string]string, opts Options) ([]byte, error) {
+	if !kubernetesNamePattern.MatchString(opts.Name) || len(opts.Name) > 253 {
+		return nil, fmt.Errorf("invalid Kubernetes name %q (use lowercase letters, digits, '-' and '.')", opts.Name)
+	}
+	if opts.Namespace != "" && !kubernetesNamePattern.MatchString(opts.Namespace) {
+		return nil, fmt.Errorf("invalid Kubernetes namespace %q", opts.Namespace)
+	}
+
+	return marshalYAML(kubernetesObject{
+		APIVersion: "v1",
+		Kind:       kind,
+		Metadata:   kubernetesMetadata{Name: opts.Name, Namespace: opts.Namespace},
+		Type:       objectType,
+		Data:       data,
+	})
+}
+
+// marshalYAML encodes v with the two-space indentation Kubernetes and most
+// YAML files use
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,353 @@
+package envfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Entry is an item of a password manager export
+type Entry struct {
+	Title    string
+	Folder   string // Folder or group path, joined with /
+	Tags     []string
+	Username string
+	Password string
+	URL      string
+	Notes    string
+	Fields   map[string]string // Custom fields
+}
+
+// Value returns the secret of an entry: its password, or the notes of
+// entries without one, such as secure notes holding an API key
+func (e Entry) Value() string {
+	if e.Password != "" {
+		return e.Password
+	}
+	return e.Notes
+}
+
+// PasswordManagerFormats are the exports ParseEntries reads
+var PasswordManagerFormats = []string{"1password-csv", "bitwarden-json", "keepass-xml"}
+
+// keyNameInvalid matches runs of characters that can't be in a key
+var keyNameInvalid = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// KeyName turns a name such as "Stripe API key" into a valid environment
+// variable name such as STRIPE_API_KEY
+func KeyName(name string) string {
+	key := strings.Trim(keyNameInvalid.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key
+}
+
+// ParseEntries reads the entries of a password manager export
+func ParseEntries(format string, data []byte) ([]Entry, error) {
+	var entries []Entry
+	var err error
+	switch strings.ToLower(format) {
+	case "1password-csv":
+		entries, err = parseOnePasswordCSV(data)
+	case "bitwarden-json":
+		entries, err = parseBitwardenJSON(data)
+	case "keepass-xml":
+		entries, err = parseKeePassXML(data)
+	default:
+		return nil, fmt.Errorf("unknown export %q (supported: %s)", format, strings.Join(PasswordManagerFormats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s export: %w", format, err)
+	}
+	return entries, nil
+}
+
+// onePasswordColumns maps the column names of 1Password CSV exports (the
+// fixed columns of 1Password 8 and the usual ones of 1Password 7) to fields
+var onePasswordColumns = map[string]string{
+	"title":      "title",
+	"name":       "title",
+	"url":        "url",
+	"urls":       "url",
+	"website":    "url",
+	"username":   "username",
+	"password":   "password",
+	"notes":      "notes",
+	"notesplain": "notes",
+	"tags":       "tags",
+	"vault":      "folder",
+	"folder":     "folder",
+	// Columns that are not secrets
+	"otpauth":  "",
+	"favorite": "",
+	"archived": "",
+	"type":     "",
+}
+
+// parseOnePasswordCSV reads a 1Password CSV export. The first row must name
+// the columns; columns other than the known ones become custom fields.
+func parseOnePasswordCSV(data []byte) ([]Entry, error) {
+	// Excel and some 1Password versions start the file with a byte order mark
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+
+	header := records[0]
+	columns := make([]string, len(header))
+	hasTitle := false
+	for i, name := range header {
+		field, known := onePasswordColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !known {
+			field = "field:" + strings.TrimSpace(name)
+		}
+		columns[i] = field
+		hasTitle = hasTitle || field == "title"
+	}
+	if !hasTitle {
+		return nil, fmt.Errorf("the first row must name the columns, including Title")
+	}
+
+	entries := make([]Entry, 0, len(records)-1)
+	for _, record := range records[1:] {
+		entry := Entry{Fields: make(map[string]string)}
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			switch column := columns[i]; column {
+			case "title":
+				entry.Title = value
+			case "url":
+				entry.URL = value
+			case "username":
+				entry.Username = value
+			case "password":
+				entry.Password = value
+			case "notes":
+				entry.Notes = value
+			case "tags":
+				entry.Tags = splitTags(value)
+			case "folder":
+				entry.Folder = value
+			case "":
+			default:
+				if value != "" {
+					entry.Fields[strings.TrimPrefix(column, "field:")] = value
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// splitTags splits a list of tags separated by commas or semicolons
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// bitwardenExport is the unencrypted JSON export of a Bitwarden vault or
+// organization
+type bitwardenExport struct {
+	Encrypted   bool             `json:"encrypted"`
+	Folders     []bitwardenGroup `json:"folders"`
+	Collections []bitwardenGroup `json:"collections"`
+	Items       []struct {
+		Name          string   `json:"name"`
+		Notes         string   `json:"notes"`
+		FolderID      string   `json:"folderId"`
+		CollectionIDs []string `json:"collectionIds"`
+		Login         *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			URIs     []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+		} `json:"login"`
+		Fields []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+			Type  int    `json:"type"`
+		} `json:"fields"`
+	} `json:"items"`
+}
+
+// bitwardenGroup is a folder or collection
+type bitwardenGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// bitwardenLinkedField is the type of fields that point at another field
+const bitwardenLinkedField = 3
+
+// parseBitwardenJSON reads a Bitwarden JSON export. Folders become the
+// folder and collections of organization exports become tags.
+func parseBitwardenJSON(data []byte) ([]Entry, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("the export is encrypted; export it again as unencrypted JSON")
+	}
+
+	folders := make(map[string]string)
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+	collections := make(map[string]string)
+	for _, collection := range export.Collections {
+		collections[collection.ID] = collection.Name
+	}
+
+	entries := make([]Entry, 0, len(export.Items))
+	for _, item := range export.Items {
+		entry := Entry{
+			Title:  item.Name,
+			Folder: folders[item.FolderID],
+			Notes:  item.Notes,
+			Fields: make(map[string]string),
+		}
+		for _, id := range item.CollectionIDs {
+			if name, ok := collections[id]; ok {
+				entry.Tags = append(entry.Tags, name)
+			}
+		}
+		if item.Login != nil {
+			entry.Username = item.Login.Username
+			entry.Password = item.Login.Password
+			if len(item.Login.URIs) > 0 {
+				entry.URL = item.Login.URIs[0].URI
+			}
+		}
+		for _, field := range item.Fields {
+			if field.Type != bitwardenLinkedField && field.Name != "" {
+				entry.Fields[field.Name] = field.Value
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// keepassGroup is a group of a KeePass 2 XML export
+type keepassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+// keepassEntry is an entry; its History holds old versions and is ignored
+type keepassEntry struct {
+	Tags    string `xml:"Tags"`
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+// parseKeePassXML reads a KeePass 2 XML export. The group path below the
+// root group becomes the folder; the recycle bin is skipped.
+func parseKeePassXML(data []byte) ([]Entry, error) {
+	var export struct {
+		XMLName xml.Name `xml:"KeePassFile"`
+		Meta    struct {
+			RecycleBinEnabled string `xml:"RecycleBinEnabled"`
+			RecycleBinUUID    string `xml:"RecycleBinUUID"`
+		} `xml:"Meta"`
+		Root struct {
+			Groups []keepassGroup `xml:"Group"`
+		} `xml:"Root"`
+	}
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	recycleBin := ""
+	if !strings.EqualFold(export.Meta.RecycleBinEnabled, "False") {
+		recycleBin = export.Meta.RecycleBinUUID
+	}
+
+	var entries []Entry
+	var walk func(group keepassGroup, path []string)
+	walk = func(group keepassGroup, path []string) {
+		if recycleBin != "" && group.UUID == recycleBin {
+			return
+		}
+		for _, item := range group.Entries {
+			entries = append(entries, keepassToEntry(item, strings.Join(path, "/")))
+		}
+		for _, child := range group.Groups {
+			walk(child, append(path[:len(path):len(path)], child.Name))
+		}
+	}
+
+	// The top-level group is the database itself
+	for _, root := range export.Root.Groups {
+		walk(root, nil)
+	}
+
+	return entries, nil
+}
+
+// keepassToEntry converts a KeePass entry; strings other than the standard
+// ones are custom fields
+func keepassToEntry(item keepassEntry, folder string) Entry {
+	entry := Entry{
+		Folder: folder,
+		Tags:   splitTags(item.Tags),
+		Fields: make(map[string]string),
+	}
+	for _, s := range item.Strings {
+		switch s.Key {
+		case "Title":
+			entry.Title = s.Value
+		case "UserName":
+			entry.Username = s.Value
+		case "Password":
+			entry.Password = s.Value
+		case "URL":
+			entry.URL = s.Value
+		case "Notes":
+			entry.Notes = s.Value
+		default:
+			if s.Value != "" {
+				entry.Fields[s.Key] = s.Value
+			}
+		}
+	}
+	return entry
+}
+
+// FieldNames returns the names of an entry's custom fields, sorted
+func (e Entry) FieldNames() []string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
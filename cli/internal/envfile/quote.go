@@ -0,0 +1,236 @@
+package envfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// plainValue matches values every line format can leave unquoted
+var plainValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,-]+$`)
+
+// formatDotenv writes KEY=value as dotenv parsers (godotenv, python-dotenv,
+// Docker Compose) read it: double quotes with backslash escapes, and $
+// escaped so it is not expanded. godotenv cannot read a double-quoted
+// value that starts or ends with a quote or ends with a backslash, so those
+// are single-quoted or left bare where possible.
+func formatDotenv(key, value string) (string, error) {
+	if value == "" || plainValue.MatchString(value) {
+		return key + "=" + value, nil
+	}
+
+	if !strings.HasPrefix(value, `"`) && !strings.HasSuffix(value, `"`) && !strings.HasSuffix(value, `\`) {
+		replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+		return key + `="` + replacer.Replace(value) + `"`, nil
+	}
+
+	if !strings.Contains(value, "'") && !strings.HasSuffix(value, `\`) {
+		return key + "='" + value + "'", nil
+	}
+
+	// Bare values are taken literally up to the end of the line
+	if !strings.ContainsAny(value, "\r\n$") && !strings.Contains(value, " #") &&
+		strings.TrimSpace(value) == value && !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+		return key + "=" + value, nil
+	}
+
+	return "", fmt.Errorf("%s: the value cannot be written to a dotenv file; use another format such as json", key)
+}
+
+// formatDocker writes a line for docker run --env-file, which takes
+// everything after = literally and has no quoting, so values cannot span
+// lines
+func formatDocker(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%s: Docker env files cannot hold values with line breaks", key)
+	}
+	if !utf8.ValidString(value) {
+		return "", fmt.Errorf("%s: Docker env files must be valid UTF-8", key)
+	}
+	return key + "=" + value, nil
+}
+
+// formatSystemd writes a line for a systemd EnvironmentFile. Double-quoted
+// values may span lines; \, ", ` and $ are escaped. systemd ignores values
+// with other control characters or invalid UTF-8.
+func formatSystemd(key, value string) (string, error) {
+	if !utf8.ValidString(value) || strings.IndexFunc(value, isSystemdControl) >= 0 {
+		return "", fmt.Errorf("%s: systemd cannot hold values with control characters other than tabs and line breaks", key)
+	}
+
+	if value == "" || plainValue.MatchString(value) {
+		return key + "=" + value, nil
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+	return key + `="` + replacer.Replace(value) + `"`, nil
+}
+
+// isSystemdControl reports whether systemd rejects r in a value
+func isSystemdControl(r rune) bool {
+	return (r < 0x20 && r != '\t' && r != '\n') || r == 0x7f
+}
+
+// formatTfvars writes a Terraform variable assignment. Besides the usual
+// escapes, ${ and %{ are doubled so they are not read as template
+// sequences.
+func formatTfvars(key, value string) (string, error) {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteString(` = "`)
+
+	for i, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteRune(r)
+			if strings.HasPrefix(value[i+1:], "{") {
+				b.WriteRune(r)
+			}
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	b.WriteString(`"`)
+	return b.String(), nil
+}
+
+// formatBash writes an export statement for bash, zsh and other POSIX
+// shells. Single quotes keep everything literal, and each quote in the
+// value closes the string, adds an escaped quote and reopens it.
+func formatBash(key, value string) (string, error) {
+	return "export " + key + "=" + shellQuote(value), nil
+}
+
+// shellQuote single-quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// formatFish writes a set statement for fish, whose single quotes only
+// treat \' and \\ specially
+func formatFish(key, value string) (string, error) {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "set -gx " + key + " '" + replacer.Replace(value) + "'", nil
+}
+
+// formatPowerShell writes an $env: assignment. In single-quoted strings
+// PowerShell only treats quotes specially, including the typographic
+// ones, which are doubled.
+func formatPowerShell(key, value string) (string, error) {
+	var b strings.Builder
+	b.WriteString("$env:" + key + " = '")
+	for _, r := range value {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("'")
+	return b.String(), nil
+}
+
+// formatProperties writes a Java .properties entry the way
+// Properties.store does, so it reads back with Properties.load in any
+// encoding: non-ASCII characters become \uXXXX escapes
+func formatProperties(key, value string) (string, error) {
+	return propertiesEscape(key, true) + "=" + propertiesEscape(value, false), nil
+}
+
+// propertiesEscape escapes a key or value for .properties files. Spaces are
+// escaped everywhere in keys, and only at the start of values.
+func propertiesEscape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteRune(r)
+			}
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				writeUTF16Escapes(&b, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeUTF16Escapes writes r as \uXXXX escapes of its UTF-16 code units, as
+// Java expects
+func writeUTF16Escapes(b *strings.Builder, r rune) {
+	if r > 0xFFFF {
+		r -= 0x10000
+		fmt.Fprintf(b, `\u%04X\u%04X`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		return
+	}
+	fmt.Fprintf(b, `\u%04X`, r)
+}
+
+// formatTOML writes a TOML key with a basic string value
+func formatTOML(key, value string) (string, error) {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteString(` = "`)
+
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	b.WriteString(`"`)
+	return b.String(), nil
+}
@@ -0,0 +1,139 @@
+package envfile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		want   string
+	}{
+		{"dotenv", "plain-value_1.2", `KEY=plain-value_1.2`},
+		{"dotenv", "", `KEY=`},
+		{"dotenv", "a b", `KEY="a b"`},
+		{"dotenv", "line\nbreak", `KEY="line\nbreak"`},
+		{"dotenv", `say "hi" to $USER and ${HOME}`, `KEY="say \"hi\" to \$USER and \${HOME}"`},
+		{"dotenv", `C:\dir\new`, `KEY="C:\\dir\\new"`},
+		{"dotenv", `"quoted"`, `KEY='"quoted"'`},
+		{"dotenv", `trailing \`, `KEY=trailing \`},
+		{"dotenv", "héllo", `KEY="héllo"`},
+
+		{"docker", `"$HOME" \n`, `KEY="$HOME" \n`},
+		{"docker", "héllo", `KEY=héllo`},
+
+		{"systemd", "plain", `KEY=plain`},
+		{"systemd", "a\nb", "KEY=\"a\nb\""},
+		{"systemd", "`cmd` $HOME ${X} \"q\" \\", "KEY=\"\\`cmd\\` \\$HOME \\${X} \\\"q\\\" \\\\\""},
+		{"systemd", "a\tb", "KEY=\"a\tb\""},
+
+		{"tfvars", "${var.x} %{if} $x %x", `KEY = "$${var.x} %%{if} $x %x"`},
+		{"tfvars", "a\nb\t\"c\"\\", `KEY = "a\nb\t\"c\"\\"`},
+		{"tfvars", "bell\a", `KEY = "bell\u0007"`},
+		{"tfvars", "héllo", `KEY = "héllo"`},
+
+		{"bash", "it's $HOME", `export KEY='it'\''s $HOME'`},
+		{"bash", "a\nb", "export KEY='a\nb'"},
+		{"bash", `trailing \`, `export KEY='trailing \'`},
+		{"bash", "", `export KEY=''`},
+
+		{"fish", `it's \ $HOME`, `set -gx KEY 'it\'s \\ $HOME'`},
+		{"fish", `trailing \`, `set -gx KEY 'trailing \\'`},
+
+		{"powershell", "it's $env:HOME", `$env:KEY = 'it''s $env:HOME'`},
+		{"powershell", "it’s", `$env:KEY = 'it’’s'`},
+		{"powershell", `trailing \`, `$env:KEY = 'trailing \'`},
+
+		{"properties", "a=b:c #d !e", `KEY=a\=b\:c \#d \!e`},
+		{"properties", " leading and inner space", `KEY=\ leading and inner space`},
+		{"properties", "a\nb\\", `KEY=a\nb\\`},
+		{"properties", "héllo 🔑", `KEY=h\u00E9llo \uD83D\uDD11`},
+
+		{"toml", "a\nb\t\"c\"\\ $x ${y}", `KEY = "a\nb\t\"c\"\\ $x ${y}"`},
+		{"toml", "esc\x1b", `KEY = "esc\u001B"`},
+		{"toml", "héllo", `KEY = "héllo"`},
+	}
+
+	for _, tt := range tests {
+		got, err := lineFormats[tt.format]("KEY", tt.value)
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.format, tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %q:\n got %s\nwant %s", tt.format, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPropertiesKeyEscapes(t *testing.T) {
+	got, err := formatProperties("my key=1", "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `my\ key\=1=v`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestLineFormatErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		want   string
+	}{
+		{"docker", "a\nb", "line breaks"},
+		{"docker", "a\rb", "line breaks"},
+		{"docker", "\xff", "valid UTF-8"},
+		{"dotenv", "\"it's\"\nquoted", "cannot be written to a dotenv file"},
+		{"dotenv", "'single' and \"double\" \\", "cannot be written to a dotenv file"},
+		{"systemd", "a\r\nb", "control characters"},
+		{"systemd", "esc\x1b", "control characters"},
+		{"systemd", "\xff", "control characters"},
+	}
+
+	for _, tt := range tests {
+		_, err := lineFormats[tt.format]("KEY", tt.value)
+		if err == nil {
+			t.Errorf("%s %q: no error, want %q", tt.format, tt.value, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), "KEY: ") {
+			t.Errorf("%s %q: got %q, want it to name the key and contain %q", tt.format, tt.value, err, tt.want)
+		}
+	}
+}
+
+// TestBashRoundTrip sources the bash output and checks the shell sees the
+// exact values
+func TestBashRoundTrip(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+
+	for key, want := range goldenVars {
+		line, err := formatBash(key, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		script := filepath.Join(t.TempDir(), "env.sh")
+		if err := os.WriteFile(script, []byte(line+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command(bash, "-c", `. "$1" && printf %s "$`+key+`"`, "bash", script).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if string(out) != want {
+			t.Errorf("%s: got %q, want %q", key, out, want)
+		}
+	}
+}
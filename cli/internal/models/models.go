@@ -63,6 +63,45 @@ type SecretHistory struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// Outbox operations recorded while changes are waiting to be synced
+const (
+	OutboxSecretSet          = "secret_set"
+	OutboxSecretDeleted      = "secret_deleted"
+	OutboxEnvironmentCreated = "environment_created"
+	OutboxEnvironmentDeleted = "environment_deleted"
+)
+
+// OutboxEntry represents a local change that has not been pushed yet
+type OutboxEntry struct {
+	ID             int64     `json:"id"`
+	ProjectID      string    `json:"project_id"`
+	Operation      string    `json:"operation"`
+	Environment    string    `json:"environment"`
+	Key            string    `json:"key,omitempty"`
+	EncryptedValue []byte    `json:"encrypted_value,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SyncMetadata tracks the last blob version a project was synced with on a
+// remote
+type SyncMetadata struct {
+	ProjectID  string     `json:"project_id"`
+	Remote     string     `json:"remote,omitempty"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+	Version    int        `json:"version"`
+	Checksum   string     `json:"checksum,omitempty"`
+}
+
+// ProjectRole is a user's role in a project on a remote, as last reported
+// by the server. An empty Role means the server did not list the user.
+type ProjectRole struct {
+	ProjectID string    `json:"project_id"`
+	Remote    string    `json:"remote,omitempty"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
 // AuthSession represents an authenticated session
 type AuthSession struct {
 	UserID      string    `json:"user_id"`
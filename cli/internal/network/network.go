@@ -0,0 +1,268 @@
+// Package network builds HTTP clients that work on locked-down corporate
+// networks: explicit or environment proxies, private CA bundles, client
+// certificates for mutual TLS and optional certificate pinning.
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options configures outbound HTTPS connections. The zero value behaves like
+// http.DefaultTransport, including HTTPS_PROXY/NO_PROXY support.
+type Options struct {
+	// Proxy is an explicit proxy URL. When empty, HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY from the environment are used.
+	Proxy string
+
+	// NoProxy lists hosts that bypass an explicit Proxy: host names, domain
+	// suffixes (.corp.example.com), IPs, CIDR ranges or "*"
+	NoProxy string
+
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+
+	// ClientCert and ClientKey are PEM files presented for mutual TLS
+	ClientCert string
+	ClientKey  string
+
+	// Pins are SHA-256 hashes of trusted public keys, as "sha256/BASE64"
+	// or hex. When set, a connection is only accepted if some certificate
+	// in the server's chain matches a pin.
+	Pins []string
+}
+
+// NewTransport returns a transport configured with opts
+func NewTransport(opts Options) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := proxyFunc(opts.Proxy, opts.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// NewClient returns an HTTP client configured with opts
+func NewClient(opts Options, timeout time.Duration) (*http.Client, error) {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// tlsConfig builds the TLS settings for opts
+func tlsConfig(opts Options) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be configured together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.Pins) > 0 {
+		pins, err := parsePins(opts.Pins)
+		if err != nil {
+			return nil, err
+		}
+
+		// Runs after normal chain verification, so pinning narrows trust
+		// rather than replacing it
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, cert := range state.PeerCertificates {
+				if pins[spkiHash(cert)] {
+					return nil
+				}
+			}
+			return fmt.Errorf("certificate pinning failed: no certificate in the server chain matches a configured pin")
+		}
+	}
+
+	return config, nil
+}
+
+// parsePins decodes pins into a set of raw SHA-256 hashes
+func parsePins(values []string) (map[string]bool, error) {
+	pins := make(map[string]bool)
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		var raw []byte
+		var err error
+		if b64 := strings.TrimPrefix(value, "sha256/"); b64 != value {
+			raw, err = base64.StdEncoding.DecodeString(b64)
+		} else {
+			raw, err = hex.DecodeString(strings.ReplaceAll(value, ":", ""))
+		}
+
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %q (expected sha256/BASE64 or a hex SHA-256)", value)
+		}
+		pins[string(raw)] = true
+	}
+
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("no valid certificate pins configured")
+	}
+
+	return pins, nil
+}
+
+// spkiHash returns the SHA-256 of a certificate's public key info
+func spkiHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return string(sum[:])
+}
+
+// PinFor returns the pin for a certificate in "sha256/BASE64" form
+func PinFor(cert *x509.Certificate) string {
+	return "sha256/" + base64.StdEncoding.EncodeToString([]byte(spkiHash(cert)))
+}
+
+// proxyFunc returns the transport's proxy selector
+func proxyFunc(proxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		// Accept bare host:port like curl does
+		proxyURL, err = url.Parse("http://" + proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+	}
+
+	bypass := parseNoProxy(noProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypass.matches(req.URL) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// noProxyList holds parsed NO_PROXY entries
+type noProxyList struct {
+	all      bool
+	hosts    []string
+	networks []*net.IPNet
+}
+
+// parseNoProxy parses a comma separated NO_PROXY value
+func parseNoProxy(value string) noProxyList {
+	var list noProxyList
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			list.all = true
+		default:
+			if _, network, err := net.ParseCIDR(entry); err == nil {
+				list.networks = append(list.networks, network)
+				continue
+			}
+			list.hosts = append(list.hosts, entry)
+		}
+	}
+
+	return list
+}
+
+// matches reports whether u should bypass the proxy
+func (l noProxyList) matches(u *url.URL) bool {
+	if l.all {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	hostPort := host + ":" + port
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		for _, network := range l.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	} else if host == "localhost" {
+		return true
+	}
+
+	for _, entry := range l.hosts {
+		if strings.Contains(entry, ":") && !strings.Contains(entry, "]") && strings.Count(entry, ":") == 1 {
+			if entry == hostPort {
+				return true
+			}
+			continue
+		}
+
+		suffix := strings.TrimPrefix(entry, "*")
+		if host == strings.TrimPrefix(suffix, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(suffix, ".")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,665 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// decodeParams parses RPC parameters into dst
+func decodeParams(params json.RawMessage, dst interface{}) error {
+	if err := json.Unmarshal(params, dst); err != nil {
+		return &rpcError{Status: http.StatusBadRequest, Code: "PGRST102", Message: "Invalid parameters: " + err.Error()}
+	}
+	return nil
+}
+
+// requireRole checks that userID holds at least role in the project. Unknown
+// projects and non-members get the same error so project IDs can't be probed.
+func (s *Server) requireRole(projectID, userID, role string) (string, error) {
+	actual, err := s.store.GetRole(projectID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return "", raise("Access denied or project not found")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if roleRank[actual] < roleRank[role] {
+		return "", &rpcError{
+			Status:  http.StatusForbidden,
+			Code:    "42501",
+			Message: fmt.Sprintf("Access denied: requires %s role", role),
+			Hint:    fmt.Sprintf("Your role in this project is %s", actual),
+		}
+	}
+
+	return actual, nil
+}
+
+// audit records an action, logging rather than failing the request on error
+func (s *Server) audit(projectID, userID, action string, metadata map[string]interface{}) {
+	data, _ := json.Marshal(metadata)
+	if err := s.store.CreateAuditLog(projectID, userID, action, string(data)); err != nil && s.logger != nil {
+		s.logger.Printf("warning: %v", err)
+	}
+}
+
+func (s *Server) validateCLIToken(ctx context.Context, _ string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Token string `json:"p_token"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.store.ValidateToken(p.Token)
+	if errors.Is(err, ErrNotFound) {
+		return nil, raise("Invalid or expired token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"user_id": userID}, nil
+}
+
+func (s *Server) pushEncryptedBlob(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID        string            `json:"p_project_id"`
+		EncryptedData    string            `json:"p_encrypted_data"`
+		Checksum         string            `json:"p_checksum"`
+		ProjectName      string            `json:"p_project_name"`
+		Environments     []string          `json:"p_environments"`
+		EnvironmentBlobs []EnvironmentBlob `json:"p_environment_blobs"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if p.ProjectID == "" || p.EncryptedData == "" || p.Checksum == "" {
+		return nil, raise("p_project_id, p_encrypted_data and p_checksum are required")
+	}
+
+	// The first push of a project registers it with the pusher as admin
+	created, err := s.store.EnsureProject(p.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		s.audit(p.ProjectID, userID, "project_created", nil)
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleDeveloper); err != nil {
+		return nil, err
+	}
+
+	for _, env := range p.EnvironmentBlobs {
+		if env.Environment == "" || env.EncryptedData == "" || env.Checksum == "" {
+			return nil, raise("every p_environment_blobs entry needs environment, encrypted_data and checksum")
+		}
+	}
+
+	blob, err := s.store.PushBlob(p.ProjectID, p.EncryptedData, p.Checksum, userID, p.EnvironmentBlobs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names let members find the project and its environments before they
+	// clone it; older CLIs don't send them
+	if p.ProjectName != "" {
+		if err := s.store.SetProjectName(p.ProjectID, p.ProjectName, true); err != nil {
+			return nil, err
+		}
+	}
+	if p.Environments != nil {
+		if err := s.store.SetEnvironments(p.ProjectID, p.Environments); err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit(p.ProjectID, userID, "blob_pushed", map[string]interface{}{
+		"version":  blob.Version,
+		"checksum": blob.Checksum,
+	})
+
+	return map[string]interface{}{
+		"id":          blob.ID,
+		"version":     blob.Version,
+		"uploaded_at": blob.UploadedAt,
+	}, nil
+}
+
+func (s *Server) pullEncryptedBlob(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID    string `json:"p_project_id"`
+		SinceVersion *int   `json:"p_since_version"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	machineToken := machineTokenFrom(ctx)
+	if machineToken != nil {
+		if machineToken.ProjectID != p.ProjectID {
+			return nil, raise("Access denied or project not found")
+		}
+	} else if _, err := s.requireRole(p.ProjectID, userID, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	blob, err := s.store.PullBlob(p.ProjectID, p.SinceVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if blob == nil {
+		return map[string]bool{"has_update": false}, nil
+	}
+
+	// A token limited to some environments never sees the whole blob, only
+	// the environments it may read
+	if machineToken != nil && len(machineToken.Environments) > 0 {
+		return s.pullScopedBlob(machineToken, p.ProjectID, blob)
+	}
+
+	if machineToken != nil {
+		s.audit(p.ProjectID, "", "machine_token_pulled", map[string]interface{}{
+			"token_id": machineToken.ID,
+			"name":     machineToken.Name,
+			"version":  blob.Version,
+		})
+	}
+
+	return struct {
+		HasUpdate bool `json:"has_update"`
+		*Blob
+	}{true, blob}, nil
+}
+
+// pullScopedBlob returns the environments of blob that machineToken may read
+func (s *Server) pullScopedBlob(machineToken *MachineToken, projectID string, blob *Blob) (interface{}, error) {
+	all, err := s.store.ListEnvironmentBlobs(blob.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, raise("Version %d was pushed by an older EnvVault CLI that does not support tokens limited to some environments; push the project again", blob.Version)
+	}
+
+	environments := []EnvironmentBlob{}
+	var names []string
+	for _, env := range all {
+		if machineToken.Allows(env.Environment) {
+			environments = append(environments, env)
+			names = append(names, env.Environment)
+		}
+	}
+
+	s.audit(projectID, "", "machine_token_pulled", map[string]interface{}{
+		"token_id":     machineToken.ID,
+		"name":         machineToken.Name,
+		"version":      blob.Version,
+		"environments": names,
+	})
+
+	return struct {
+		HasUpdate    bool              `json:"has_update"`
+		ID           string            `json:"id"`
+		Version      int               `json:"version"`
+		Environments []EnvironmentBlob `json:"environments"`
+		UploadedAt   time.Time         `json:"uploaded_at"`
+	}{true, blob.ID, blob.Version, environments, blob.UploadedAt}, nil
+}
+
+func (s *Server) inviteTeamMember(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+		Email     string `json:"p_email"`
+		Role      string `json:"p_role"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if p.Role == "" {
+		p.Role = RoleViewer
+	}
+	if _, ok := roleRank[p.Role]; !ok {
+		return nil, raise("Invalid role: %s (expected viewer, developer or admin)", p.Role)
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	member, err := s.store.GetUserByEmail(p.Email)
+	if errors.Is(err, ErrNotFound) {
+		return nil, raise("User not found with email: %s", p.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID, err := s.store.GetProjectOwner(p.ProjectID); err == nil && ownerID == member.ID && p.Role != RoleAdmin {
+		return nil, raise("Access denied: the project owner must remain an admin")
+	}
+
+	memberID, err := s.store.AddMember(p.ProjectID, member.ID, p.Role, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(p.ProjectID, userID, "member_invited", map[string]interface{}{
+		"email": p.Email,
+		"role":  p.Role,
+	})
+
+	return memberID, nil
+}
+
+func (s *Server) removeTeamMember(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+		UserID    string `json:"p_user_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if ownerID, err := s.store.GetProjectOwner(p.ProjectID); err == nil && ownerID == p.UserID {
+		return nil, raise("Access denied: the project owner cannot be removed")
+	}
+
+	removed, err := s.store.RemoveMember(p.ProjectID, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if removed {
+		s.audit(p.ProjectID, userID, "member_removed", map[string]interface{}{
+			"user_id": p.UserID,
+		})
+	}
+
+	return removed, nil
+}
+
+func (s *Server) listTeamMembers(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.store.ListMembers(p.ProjectID)
+}
+
+// Invitations expire after a week unless another lifetime is requested, and
+// never last longer than a month
+const (
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	maxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+func (s *Server) createTeamInvitation(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID        string `json:"p_project_id"`
+		ProjectName      string `json:"p_project_name"`
+		Email            string `json:"p_email"`
+		Role             string `json:"p_role"`
+		ExpiresInSeconds int64  `json:"p_expires_in_seconds"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	p.Email = strings.TrimSpace(p.Email)
+	if !strings.Contains(p.Email, "@") {
+		return nil, raise("Invalid email address: %s", p.Email)
+	}
+
+	if p.Role == "" {
+		p.Role = RoleViewer
+	}
+	if _, ok := roleRank[p.Role]; !ok {
+		return nil, raise("Invalid role: %s (expected viewer, developer or admin)", p.Role)
+	}
+
+	ttl := time.Duration(p.ExpiresInSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultInvitationTTL
+	}
+	if ttl < 0 || ttl > maxInvitationTTL {
+		return nil, raise("Invitations must expire within 30 days")
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if user, err := s.store.GetUserByEmail(p.Email); err == nil {
+		if _, err := s.store.GetRole(p.ProjectID, user.ID); err == nil {
+			return nil, &rpcError{Status: http.StatusConflict, Code: "23505", Message: fmt.Sprintf("%s is already a member of this project", p.Email)}
+		}
+	}
+
+	invitation, err := s.store.CreateInvitation(p.ProjectID, p.ProjectName, p.Email, p.Role, userID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.ProjectName != "" {
+		if err := s.store.SetProjectName(p.ProjectID, p.ProjectName, false); err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit(p.ProjectID, userID, "invitation_created", map[string]interface{}{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"expires_at":    invitation.ExpiresAt,
+	})
+
+	return invitation, nil
+}
+
+func (s *Server) listTeamInvitations(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.store.ListInvitations(p.ProjectID)
+}
+
+func (s *Server) revokeTeamInvitation(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID    string `json:"p_project_id"`
+		InvitationID string `json:"p_invitation_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.store.DeleteInvitation(p.ProjectID, p.InvitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		s.audit(p.ProjectID, userID, "invitation_revoked", map[string]interface{}{
+			"invitation_id": p.InvitationID,
+		})
+	}
+
+	return revoked, nil
+}
+
+func (s *Server) listMyInvitations(ctx context.Context, userID string, _ json.RawMessage) (interface{}, error) {
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.ListInvitationsForEmail(user.Email)
+}
+
+func (s *Server) acceptTeamInvitation(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		InvitationID string `json:"p_invitation_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other people's invitations are reported as missing
+	invitation, err := s.store.GetInvitation(p.InvitationID)
+	if errors.Is(err, ErrNotFound) || (err == nil && !strings.EqualFold(invitation.Email, user.Email)) {
+		return nil, raise("Invitation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, raise("Invitation has expired; ask a project admin to invite you again")
+	}
+
+	memberID, err := s.store.AcceptInvitation(invitation, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(invitation.ProjectID, userID, "invitation_accepted", map[string]interface{}{
+		"invitation_id": invitation.ID,
+		"role":          invitation.Role,
+	})
+
+	return struct {
+		MemberID    string `json:"member_id"`
+		ProjectID   string `json:"project_id"`
+		ProjectName string `json:"project_name,omitempty"`
+		Role        string `json:"role"`
+	}{memberID, invitation.ProjectID, invitation.ProjectName, invitation.Role}, nil
+}
+
+func (s *Server) updateTeamMemberRole(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+		UserID    string `json:"p_user_id"`
+		Role      string `json:"p_role"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, ok := roleRank[p.Role]; !ok {
+		return nil, raise("Invalid role: %s (expected viewer, developer or admin)", p.Role)
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if ownerID, err := s.store.GetProjectOwner(p.ProjectID); err == nil && ownerID == p.UserID && p.Role != RoleAdmin {
+		return nil, raise("Access denied: the project owner must remain an admin")
+	}
+
+	changed, err := s.store.SetMemberRole(p.ProjectID, p.UserID, p.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		s.audit(p.ProjectID, userID, "member_role_changed", map[string]interface{}{
+			"user_id": p.UserID,
+			"role":    p.Role,
+		})
+	}
+
+	return changed, nil
+}
+
+func (s *Server) leaveProject(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleViewer); err != nil {
+		return nil, err
+	}
+
+	if ownerID, err := s.store.GetProjectOwner(p.ProjectID); err == nil && ownerID == userID {
+		return nil, raise("The project owner cannot leave the project")
+	}
+
+	left, err := s.store.RemoveMember(p.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if left {
+		s.audit(p.ProjectID, userID, "member_left", map[string]interface{}{
+			"user_id": userID,
+		})
+	}
+
+	return left, nil
+}
+
+func (s *Server) getUserByEmail(ctx context.Context, _ string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Email string `json:"p_email"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByEmail(p.Email)
+	if errors.Is(err, ErrNotFound) {
+		return nil, raise("User not found with email: %s", p.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ID, nil
+}
+
+func (s *Server) getCurrentUser(ctx context.Context, userID string, _ json.RawMessage) (interface{}, error) {
+	return s.store.GetUser(userID)
+}
+
+// maxMachineTokenTTL caps machine token lifetimes so forgotten CI tokens
+// eventually stop working
+const maxMachineTokenTTL = 365 * 24 * time.Hour
+
+func (s *Server) createMachineToken(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID        string   `json:"p_project_id"`
+		Name             string   `json:"p_name"`
+		Environments     []string `json:"p_environments"`
+		ExpiresInSeconds int64    `json:"p_expires_in_seconds"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return nil, raise("p_name is required")
+	}
+
+	ttl := time.Duration(p.ExpiresInSeconds) * time.Second
+	if ttl <= 0 || ttl > maxMachineTokenTTL {
+		return nil, raise("Machine tokens must expire within 365 days")
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	plaintext, token, err := s.store.CreateMachineToken(p.ProjectID, p.Name, p.Environments, userID, ttl)
+	if errors.Is(err, ErrConflict) {
+		return nil, &rpcError{Status: http.StatusConflict, Code: "23505", Message: fmt.Sprintf("A machine token named %s already exists", p.Name)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(p.ProjectID, userID, "machine_token_created", map[string]interface{}{
+		"token_id":     token.ID,
+		"name":         token.Name,
+		"environments": token.Environments,
+		"expires_at":   token.ExpiresAt,
+	})
+
+	return struct {
+		*MachineToken
+		Token string `json:"token"`
+	}{token, plaintext}, nil
+}
+
+func (s *Server) listMachineTokens(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.store.ListMachineTokens(p.ProjectID)
+}
+
+func (s *Server) revokeMachineToken(ctx context.Context, userID string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProjectID string `json:"p_project_id"`
+		TokenID   string `json:"p_token_id"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireRole(p.ProjectID, userID, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.store.RevokeMachineToken(p.ProjectID, p.TokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		s.audit(p.ProjectID, userID, "machine_token_revoked", map[string]interface{}{
+			"token_id": p.TokenID,
+		})
+	}
+
+	return revoked, nil
+}
+
+func (s *Server) getMachineTokenScope(ctx context.Context, _ string, _ json.RawMessage) (interface{}, error) {
+	token := machineTokenFrom(ctx)
+	if token == nil {
+		return nil, raise("Not authenticated with a machine token")
+	}
+
+	return token, nil
+}
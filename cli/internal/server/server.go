@@ -0,0 +1,345 @@
+// Package server implements envault-server, a self-hostable backend that
+// speaks the same HTTP contract as the hosted EnvVault API so the CLI can
+// use either without changes.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRequestBody bounds request bodies; blobs are a few hundred KB at most
+const maxRequestBody = 32 << 20
+
+// Options configures a Server
+type Options struct {
+	// APIKey, if set, must be sent in the apikey header of every request
+	APIKey string
+
+	// Logger receives one line per request; nil disables request logging
+	Logger *log.Logger
+}
+
+// Server serves the EnvVault RPC API
+type Server struct {
+	store  *Store
+	apiKey string
+	logger *log.Logger
+	rpcs   map[string]rpcHandler
+}
+
+// rpcHandler handles one RPC. userID is empty for anonymous RPCs and for
+// callers using a machine token, which only RPCs marked machine accept; the
+// token is then available from machineTokenFrom.
+type rpcHandler struct {
+	anonymous bool
+	machine   bool
+	handle    func(ctx context.Context, userID string, params json.RawMessage) (interface{}, error)
+}
+
+// machineTokenKey is the context key for the caller's machine token
+type machineTokenKey struct{}
+
+// machineTokenFrom returns the machine token a request was made with, or nil
+// for user tokens
+func machineTokenFrom(ctx context.Context) *MachineToken {
+	token, _ := ctx.Value(machineTokenKey{}).(*MachineToken)
+	return token
+}
+
+// New creates a server backed by store
+func New(store *Store, opts Options) *Server {
+	s := &Server{
+		store:  store,
+		apiKey: opts.APIKey,
+		logger: opts.Logger,
+	}
+
+	s.rpcs = map[string]rpcHandler{
+		"validate_cli_token":  {anonymous: true, handle: s.validateCLIToken},
+		"push_encrypted_blob": {handle: s.pushEncryptedBlob},
+		"pull_encrypted_blob": {machine: true, handle: s.pullEncryptedBlob},
+		"invite_team_member":  {handle: s.inviteTeamMember},
+		"remove_team_member":  {handle: s.removeTeamMember},
+		"list_team_members":   {handle: s.listTeamMembers},
+		"get_user_by_email":   {handle: s.getUserByEmail},
+		"get_current_user":    {handle: s.getCurrentUser},
+
+		"create_team_invitation":  {handle: s.createTeamInvitation},
+		"list_team_invitations":   {handle: s.listTeamInvitations},
+		"revoke_team_invitation":  {handle: s.revokeTeamInvitation},
+		"list_my_invitations":     {handle: s.listMyInvitations},
+		"accept_team_invitation":  {handle: s.acceptTeamInvitation},
+		"update_team_member_role": {handle: s.updateTeamMemberRole},
+		"leave_project":           {handle: s.leaveProject},
+
+		"create_machine_token":    {handle: s.createMachineToken},
+		"list_machine_tokens":     {handle: s.listMachineTokens},
+		"revoke_machine_token":    {handle: s.revokeMachineToken},
+		"get_machine_token_scope": {machine: true, handle: s.getMachineTokenScope},
+	}
+
+	return s
+}
+
+// Handler returns the HTTP handler for the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /rest/v1/rpc/{function}", s.handleRPC)
+	mux.HandleFunc("GET /rest/v1/projects", s.handleListProjects)
+	mux.HandleFunc("GET /rest/v1/environments", s.handleListEnvironments)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	return s.logRequests(mux)
+}
+
+// handleRPC authenticates the caller and dispatches to the named RPC
+func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAPIKey(w, r) {
+		return
+	}
+
+	name := r.PathValue("function")
+	rpc, ok := s.rpcs[name]
+	if !ok {
+		writeError(w, &rpcError{
+			Status:  http.StatusNotFound,
+			Code:    "PGRST202",
+			Message: fmt.Sprintf("Could not find the function public.%s in the schema cache", name),
+		})
+		return
+	}
+
+	ctx := r.Context()
+	userID := ""
+	if !rpc.anonymous {
+		var machineToken *MachineToken
+		var err error
+		userID, machineToken, err = s.authenticate(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if machineToken != nil {
+			if !rpc.machine {
+				writeError(w, &rpcError{
+					Status:  http.StatusForbidden,
+					Code:    "42501",
+					Message: "Access denied: machine tokens are read-only",
+					Hint:    "Use a personal token for " + name,
+				})
+				return
+			}
+			ctx = context.WithValue(ctx, machineTokenKey{}, machineToken)
+		}
+	}
+
+	params := json.RawMessage("{}")
+	if r.ContentLength != 0 {
+		body := http.MaxBytesReader(w, r.Body, maxRequestBody)
+		if err := json.NewDecoder(body).Decode(&params); err != nil {
+			writeError(w, &rpcError{Status: http.StatusBadRequest, Code: "PGRST102", Message: "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+
+	result, err := rpc.handle(ctx, userID, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleListProjects lists the projects the caller is a member of, like a
+// select on the hosted projects table. Names are those sent with the newest
+// push or invitation, and empty for projects pushed by older CLIs.
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAPIKey(w, r) {
+		return
+	}
+
+	userID, machineToken, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var projects []*Project
+	if machineToken != nil {
+		projects, err = s.store.ListProjects("", machineToken.ProjectID)
+	} else {
+		projects, err = s.store.ListProjects(userID, "")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// handleListEnvironments lists a project's environments, like a select on
+// the hosted environments table filtered with project_id=eq.ID. As with row
+// level security, projects the caller can't read give an empty list, and a
+// machine token only sees the environments it was issued for.
+func (s *Server) handleListEnvironments(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAPIKey(w, r) {
+		return
+	}
+
+	userID, machineToken, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	projectID, ok := strings.CutPrefix(r.URL.Query().Get("project_id"), "eq.")
+	if !ok || projectID == "" {
+		writeError(w, &rpcError{Status: http.StatusBadRequest, Code: "PGRST100", Message: "A project_id=eq.ID filter is required"})
+		return
+	}
+
+	environments := []*Environment{}
+	if machineToken != nil {
+		if machineToken.ProjectID != projectID {
+			writeJSON(w, http.StatusOK, environments)
+			return
+		}
+	} else if _, err := s.store.GetRole(projectID, userID); errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusOK, environments)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	all, err := s.store.ListEnvironments(projectID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, environment := range all {
+		if machineToken == nil || machineToken.Allows(environment.Name) {
+			environments = append(environments, environment)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, environments)
+}
+
+// checkAPIKey rejects requests without the configured API key
+func (s *Server) checkAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	if s.apiKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("apikey")), []byte(s.apiKey)) != 1 {
+		writeError(w, &rpcError{Status: http.StatusUnauthorized, Code: "PGRST301", Message: "Invalid API key"})
+		return false
+	}
+	return true
+}
+
+// authenticate resolves the bearer token to a user ID, or to a machine
+// token for tokens with MachineTokenPrefix
+func (s *Server) authenticate(r *http.Request) (string, *MachineToken, error) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if header == "" || token == "" || token == header {
+		return "", nil, &rpcError{Status: http.StatusUnauthorized, Code: "PGRST301", Message: "Missing bearer token"}
+	}
+
+	invalid := &rpcError{Status: http.StatusUnauthorized, Code: "PGRST301", Message: "Invalid or expired token"}
+
+	if strings.HasPrefix(token, MachineTokenPrefix) {
+		machineToken, err := s.store.ValidateMachineToken(token)
+		if errors.Is(err, ErrNotFound) {
+			return "", nil, invalid
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return "", machineToken, nil
+	}
+
+	userID, err := s.store.ValidateToken(token)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil, invalid
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return userID, nil, nil
+}
+
+// logRequests writes one log line per request
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	if s.logger == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		s.logger.Printf("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// statusRecorder captures the response status for logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// rpcError is an error reported to the client in PostgREST's format
+type rpcError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// raise mimics a RAISE EXCEPTION in a Postgres function, which PostgREST
+// reports as a 400 with code P0001
+func raise(format string, args ...interface{}) error {
+	return &rpcError{Status: http.StatusBadRequest, Code: "P0001", Message: fmt.Sprintf(format, args...)}
+}
+
+// writeError writes err as a PostgREST error response. Errors that are not
+// rpcErrors are logged and reported as internal errors without details.
+func writeError(w http.ResponseWriter, err error) {
+	var rpcErr *rpcError
+	if !errors.As(err, &rpcErr) {
+		log.Printf("internal error: %v", err)
+		rpcErr = &rpcError{Status: http.StatusInternalServerError, Code: "XX000", Message: "Internal server error"}
+	}
+
+	writeJSON(w, rpcErr.Status, rpcErr)
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/api"
+)
+
+const (
+	testAPIKey    = "test-anon-key"
+	testProjectID = "7d1f6a52-3c1e-4a0b-9d7e-2f8c4b6a1e90"
+)
+
+// testServer is an envault-server on an httptest listener
+type testServer struct {
+	store *Store
+	url   string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	store, err := OpenStore(filepath.Join(t.TempDir(), "server.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	srv := httptest.NewServer(New(store, Options{APIKey: testAPIKey}).Handler())
+	t.Cleanup(srv.Close)
+
+	return &testServer{store: store, url: srv.URL}
+}
+
+// client returns an api.Client that sends token, without retries
+func (ts *testServer) client(token string) *api.Client {
+	client := api.New(ts.url, testAPIKey)
+	client.SetRetryPolicy(api.NoRetry)
+	client.SetAuthToken(token)
+	return client
+}
+
+// user creates an account and returns it with a client logged in as it
+func (ts *testServer) user(t *testing.T, email string) (*User, *api.Client) {
+	t.Helper()
+
+	user, err := ts.store.CreateUser(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := ts.store.CreateToken(user.ID, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, ts.client(token)
+}
+
+// push pushes a blob with one environment blob per name
+func push(client *api.Client, projectID string, environments ...string) (*api.PushBlobResponse, error) {
+	meta := api.PushMetadata{ProjectName: "app", Environments: environments}
+	for _, env := range environments {
+		meta.EnvironmentBlobs = append(meta.EnvironmentBlobs, api.EnvironmentBlob{
+			Environment:   env,
+			EncryptedData: "encrypted-" + env,
+			Checksum:      "checksum-" + env,
+		})
+	}
+
+	return client.PushEncryptedBlob(context.Background(), projectID, "encrypted-all", "checksum-all", meta)
+}
+
+func TestAuthenticate(t *testing.T) {
+	ts := newTestServer(t)
+	owner, ownerClient := ts.user(t, "owner@example.com")
+	if _, err := push(ownerClient, testProjectID, "production"); err != nil {
+		t.Fatal(err)
+	}
+	machineToken, _, err := ts.store.CreateMachineToken(testProjectID, "ci", nil, owner.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrongKey := api.New(ts.url, "wrong-key")
+	wrongKey.SetRetryPolicy(api.NoRetry)
+
+	tests := []struct {
+		name   string
+		client *api.Client
+		check  func(error) bool
+	}{
+		{"user token", ownerClient, func(err error) bool { return err == nil }},
+		{"no token", ts.client(""), api.IsUnauthorized},
+		{"unknown token", ts.client(TokenPrefix + "0123456789abcdef"), api.IsUnauthorized},
+		{"unknown machine token", ts.client(MachineTokenPrefix + "0123456789abcdef"), api.IsUnauthorized},
+		{"wrong API key", wrongKey, api.IsUnauthorized},
+		{"machine token on a user RPC", ts.client(machineToken), api.IsForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.client.GetCurrentUser(context.Background())
+			if !tt.check(err) {
+				t.Errorf("got %v", err)
+			}
+		})
+	}
+}
+
+func TestPushRequiresDeveloper(t *testing.T) {
+	ts := newTestServer(t)
+	owner, ownerClient := ts.user(t, "owner@example.com")
+	viewer, viewerClient := ts.user(t, "viewer@example.com")
+	developer, developerClient := ts.user(t, "developer@example.com")
+	_, strangerClient := ts.user(t, "stranger@example.com")
+
+	if _, err := push(ownerClient, testProjectID, "production"); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+	if _, err := ts.store.AddMember(testProjectID, viewer.ID, RoleViewer, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.store.AddMember(testProjectID, developer.ID, RoleDeveloper, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := push(viewerClient, testProjectID, "production")
+	if !api.IsForbidden(err) {
+		t.Errorf("viewer push: got %v, want a forbidden error", err)
+	}
+	if apiErr, ok := api.AsError(err); !ok || apiErr.Hint != "Your role in this project is viewer" {
+		t.Errorf("viewer push: got %#v, want a hint naming the role", err)
+	}
+
+	if _, err := push(strangerClient, testProjectID, "production"); err == nil || !strings.Contains(err.Error(), "Access denied or project not found") {
+		t.Errorf("non-member push: got %v, want access denied", err)
+	}
+
+	resp, err := push(developerClient, testProjectID, "production")
+	if err != nil {
+		t.Fatalf("developer push: %v", err)
+	}
+	if resp.Version != 2 {
+		t.Errorf("developer push stored version %d, want 2", resp.Version)
+	}
+}
+
+func TestPullRequiresMembership(t *testing.T) {
+	ts := newTestServer(t)
+	owner, ownerClient := ts.user(t, "owner@example.com")
+	viewer, viewerClient := ts.user(t, "viewer@example.com")
+	_, strangerClient := ts.user(t, "stranger@example.com")
+
+	if _, err := push(ownerClient, testProjectID, "production"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.store.AddMember(testProjectID, viewer.ID, RoleViewer, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// Non-members and unknown projects get the same error
+	for _, projectID := range []string{testProjectID, "00000000-0000-0000-0000-000000000000"} {
+		_, err := strangerClient.PullEncryptedBlob(ctx, projectID, nil)
+		if err == nil || !strings.Contains(err.Error(), "Access denied or project not found") {
+			t.Errorf("non-member pull of %s: got %v, want access denied", projectID, err)
+		}
+	}
+
+	resp, err := viewerClient.PullEncryptedBlob(ctx, testProjectID, nil)
+	if err != nil {
+		t.Fatalf("viewer pull: %v", err)
+	}
+	if !resp.HasUpdate || resp.EncryptedData != "encrypted-all" {
+		t.Errorf("viewer pull: got %+v, want the whole blob", resp)
+	}
+}
+
+func TestMachineTokenPull(t *testing.T) {
+	ts := newTestServer(t)
+	owner, ownerClient := ts.user(t, "owner@example.com")
+	if _, err := push(ownerClient, testProjectID, "development", "staging", "production"); err != nil {
+		t.Fatal(err)
+	}
+
+	otherProjectID := "5b0e2c9d-8f4a-4e61-a3d2-9c7b1f0e6d48"
+	if _, err := push(ownerClient, otherProjectID, "production"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := func(t *testing.T, projectID string, environments ...string) *api.Client {
+		t.Helper()
+		plaintext, _, err := ts.store.CreateMachineToken(projectID, t.Name(), environments, owner.ID, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts.client(plaintext)
+	}
+
+	ctx := context.Background()
+
+	t.Run("all environments", func(t *testing.T) {
+		resp, err := token(t, testProjectID).PullEncryptedBlob(ctx, testProjectID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.EncryptedData != "encrypted-all" || len(resp.Environments) != 0 {
+			t.Errorf("got %+v, want the whole blob", resp)
+		}
+	})
+
+	t.Run("some environments", func(t *testing.T) {
+		resp, err := token(t, testProjectID, "staging", "preview").PullEncryptedBlob(ctx, testProjectID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.EncryptedData != "" {
+			t.Errorf("got the whole blob %q, want only the allowed environments", resp.EncryptedData)
+		}
+		want := []api.EnvironmentBlob{{Environment: "staging", EncryptedData: "encrypted-staging", Checksum: "checksum-staging"}}
+		if len(resp.Environments) != 1 || resp.Environments[0] != want[0] {
+			t.Errorf("got environments %+v, want %+v", resp.Environments, want)
+		}
+	})
+
+	t.Run("no matching environment", func(t *testing.T) {
+		resp, err := token(t, testProjectID, "preview").PullEncryptedBlob(ctx, testProjectID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.EncryptedData != "" || len(resp.Environments) != 0 {
+			t.Errorf("got %+v, want nothing to read", resp)
+		}
+	})
+
+	t.Run("other project", func(t *testing.T) {
+		_, err := token(t, testProjectID).PullEncryptedBlob(ctx, otherProjectID, nil)
+		if err == nil || !strings.Contains(err.Error(), "Access denied or project not found") {
+			t.Errorf("got %v, want access denied", err)
+		}
+	})
+
+	t.Run("creator left the project", func(t *testing.T) {
+		developer, _ := ts.user(t, "developer@example.com")
+		if _, err := ts.store.AddMember(testProjectID, developer.ID, RoleDeveloper, owner.ID); err != nil {
+			t.Fatal(err)
+		}
+		plaintext, _, err := ts.store.CreateMachineToken(testProjectID, "ci", nil, developer.ID, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ts.store.RemoveMember(testProjectID, developer.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ts.client(plaintext).PullEncryptedBlob(ctx, testProjectID, nil)
+		if !api.IsUnauthorized(err) {
+			t.Errorf("got %v, want the token rejected", err)
+		}
+	})
+}
@@ -0,0 +1,1141 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// Roles a user can hold in a project, from least to most privileged
+const (
+	RoleViewer    = "viewer"
+	RoleDeveloper = "developer"
+	RoleAdmin     = "admin"
+)
+
+// roleRank orders roles so permission checks can compare them
+var roleRank = map[string]int{
+	RoleViewer:    1,
+	RoleDeveloper: 2,
+	RoleAdmin:     3,
+}
+
+// TokenPrefix marks CLI tokens issued by the server
+const TokenPrefix = "envt_"
+
+// MachineTokenPrefix marks read-only machine tokens scoped to one project
+const MachineTokenPrefix = "envm_"
+
+// ErrNotFound is returned when a row does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a row with the same unique key exists
+var ErrConflict = errors.New("already exists")
+
+const serverSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS cli_tokens (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	last_used_at DATETIME,
+	expires_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS machine_tokens (
+	id TEXT PRIMARY KEY,
+	project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	environments TEXT NOT NULL DEFAULT '[]',
+	created_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	last_used_at DATETIME,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(project_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS projects (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	owner_id TEXT NOT NULL REFERENCES users(id),
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS environments (
+	id TEXT PRIMARY KEY,
+	project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(project_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+	id TEXT PRIMARY KEY,
+	project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role TEXT NOT NULL,
+	invited_by TEXT REFERENCES users(id),
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(project_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS team_invitations (
+	id TEXT PRIMARY KEY,
+	project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	project_name TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL COLLATE NOCASE,
+	role TEXT NOT NULL,
+	invited_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(project_id, email)
+);
+
+CREATE TABLE IF NOT EXISTS encrypted_blobs (
+	id TEXT PRIMARY KEY,
+	project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	encrypted_data TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	uploaded_by TEXT NOT NULL REFERENCES users(id),
+	uploaded_at DATETIME NOT NULL,
+	UNIQUE(project_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS encrypted_environment_blobs (
+	blob_id TEXT NOT NULL REFERENCES encrypted_blobs(id) ON DELETE CASCADE,
+	environment TEXT NOT NULL,
+	encrypted_data TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	PRIMARY KEY(blob_id, environment)
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+	id TEXT PRIMARY KEY,
+	project_id TEXT,
+	user_id TEXT,
+	action TEXT NOT NULL,
+	metadata TEXT,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_user ON team_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_team_invitations_email ON team_invitations(email);
+CREATE INDEX IF NOT EXISTS idx_encrypted_blobs_project ON encrypted_blobs(project_id, version DESC);
+CREATE INDEX IF NOT EXISTS idx_server_audit_logs_project ON audit_logs(project_id, created_at);
+`
+
+// User is an account on the server
+type User struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Token describes an issued CLI token. The token itself is only returned
+// once, when it is created.
+type Token struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// MachineToken describes a read-only token scoped to one project and,
+// optionally, some of its environments. The token itself is only returned
+// once, when it is created.
+type MachineToken struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	ProjectID    string     `json:"project_id"`
+	Environments []string   `json:"environments"`
+	CreatedBy    string     `json:"created_by"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Allows reports whether the token may read an environment. A token with no
+// environments listed may read all of them.
+func (t *MachineToken) Allows(environment string) bool {
+	if len(t.Environments) == 0 {
+		return true
+	}
+	for _, env := range t.Environments {
+		if env == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// Project is a project the server holds snapshots for
+type Project struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Environment is an environment of a project, as named in its newest
+// snapshot. The server only knows the name, not the secrets in it.
+type Environment struct {
+	ID        string    `json:"id"`
+	ProjectID string    `json:"project_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Member is a user's membership in a project
+type Member struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `json:"user_id"`
+}
+
+// Invitation is a pending invitation to join a project. Invitations are
+// addressed to an email, so the invitee does not need an account yet.
+type Invitation struct {
+	ID             string    `json:"id"`
+	ProjectID      string    `json:"project_id"`
+	ProjectName    string    `json:"project_name,omitempty"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	InvitedBy      string    `json:"invited_by"`
+	InvitedByEmail string    `json:"invited_by_email,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Blob is a stored version of a project's encrypted snapshot
+type Blob struct {
+	ID            string    `json:"id"`
+	Version       int       `json:"version"`
+	EncryptedData string    `json:"encrypted_data"`
+	Checksum      string    `json:"checksum"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+}
+
+// EnvironmentBlob is one environment of a blob, encrypted on its own so that
+// machine tokens limited to some environments can be given only those
+type EnvironmentBlob struct {
+	Environment   string `json:"environment"`
+	EncryptedData string `json:"encrypted_data"`
+	Checksum      string `json:"checksum"`
+}
+
+// Store is the server's SQLite database
+type Store struct {
+	conn *sql.DB
+}
+
+// OpenStore opens (and if needed creates) the server database
+func OpenStore(dbPath string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite doesn't support concurrent writes
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(serverSchema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	// Databases created before projects had names lack the column
+	if err := addColumn(conn, "projects", "name", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := os.Chmod(dbPath, 0600); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set database permissions: %w", err)
+	}
+
+	return &Store{conn: conn}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.conn.Close()
+}
+
+// addColumn adds a column to a table unless it already has it
+func addColumn(conn *sql.DB, table, column, definition string) error {
+	var count int
+	err := conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := conn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// CreateUser adds a user account
+func (s *Store) CreateUser(email string) (*User, error) {
+	user := &User{
+		ID:        uuid.New().String(),
+		Email:     strings.TrimSpace(email),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err := s.conn.Exec(`INSERT INTO users (id, email, created_at) VALUES (?, ?, ?)`,
+		user.ID, user.Email, user.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail looks up a user by email, ignoring case
+func (s *Store) GetUserByEmail(email string) (*User, error) {
+	var user User
+	err := s.conn.QueryRow(`SELECT id, email, created_at FROM users WHERE email = ?`, strings.TrimSpace(email)).
+		Scan(&user.ID, &user.Email, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUser returns a user by ID
+func (s *Store) GetUser(id string) (*User, error) {
+	var user User
+	err := s.conn.QueryRow(`SELECT id, email, created_at FROM users WHERE id = ?`, id).
+		Scan(&user.ID, &user.Email, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// ListUsers returns all users ordered by email
+func (s *Store) ListUsers() ([]*User, error) {
+	rows, err := s.conn.Query(`SELECT id, email, created_at FROM users ORDER BY email`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Email, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// CreateToken issues a CLI token for a user. A zero ttl never expires. The
+// returned plaintext token is not stored and cannot be recovered later.
+func (s *Store) CreateToken(userID, name string, ttl time.Duration) (string, *Token, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	plaintext := TokenPrefix + hex.EncodeToString(raw)
+
+	token := &Token{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expires := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expires
+	}
+
+	_, err := s.conn.Exec(`
+		INSERT INTO cli_tokens (id, user_id, token_hash, name, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, token.ID, token.UserID, hashToken(plaintext), token.Name, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return plaintext, token, nil
+}
+
+// ValidateToken returns the user a token belongs to and records its use
+func (s *Store) ValidateToken(plaintext string) (string, error) {
+	var tokenID, userID string
+	var expiresAt sql.NullTime
+
+	err := s.conn.QueryRow(`SELECT id, user_id, expires_at FROM cli_tokens WHERE token_hash = ?`, hashToken(plaintext)).
+		Scan(&tokenID, &userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return "", ErrNotFound
+	}
+
+	if _, err := s.conn.Exec(`UPDATE cli_tokens SET last_used_at = ? WHERE id = ?`, time.Now().UTC(), tokenID); err != nil {
+		return "", fmt.Errorf("failed to update token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ListTokens returns the tokens issued to a user
+func (s *Store) ListTokens(userID string) ([]*Token, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, user_id, name, last_used_at, expires_at, created_at
+		FROM cli_tokens
+		WHERE user_id = ?
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*Token
+	for rows.Next() {
+		var token Token
+		var lastUsedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &lastUsedAt, &expiresAt, &token.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan token: %w", err)
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		if expiresAt.Valid {
+			token.ExpiresAt = &expiresAt.Time
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeToken deletes a token
+func (s *Store) RevokeToken(tokenID string) error {
+	result, err := s.conn.Exec(`DELETE FROM cli_tokens WHERE id = ?`, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateMachineToken issues a machine token for a project. The returned
+// plaintext token is not stored and cannot be recovered later.
+func (s *Store) CreateMachineToken(projectID, name string, environments []string, createdBy string, ttl time.Duration) (string, *MachineToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	plaintext := MachineTokenPrefix + hex.EncodeToString(raw)
+
+	if environments == nil {
+		environments = []string{}
+	}
+	envs, err := json.Marshal(environments)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode environments: %w", err)
+	}
+
+	token := &MachineToken{
+		ID:           uuid.New().String(),
+		Name:         name,
+		ProjectID:    projectID,
+		Environments: environments,
+		CreatedBy:    createdBy,
+		CreatedAt:    time.Now().UTC(),
+	}
+	expires := token.CreatedAt.Add(ttl)
+	token.ExpiresAt = &expires
+
+	_, err = s.conn.Exec(`
+		INSERT INTO machine_tokens (id, project_id, token_hash, name, environments, created_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, token.ID, projectID, hashToken(plaintext), name, string(envs), createdBy, expires, token.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return "", nil, ErrConflict
+		}
+		return "", nil, fmt.Errorf("failed to create machine token: %w", err)
+	}
+
+	return plaintext, token, nil
+}
+
+// ValidateMachineToken returns the machine token matching plaintext and
+// records its use. Tokens stop working once they expire or their creator
+// leaves the project.
+func (s *Store) ValidateMachineToken(plaintext string) (*MachineToken, error) {
+	row := s.conn.QueryRow(`
+		SELECT mt.id, mt.name, mt.project_id, mt.environments, mt.created_by, mt.last_used_at, mt.expires_at, mt.created_at
+		FROM machine_tokens mt
+		JOIN team_members tm ON tm.project_id = mt.project_id AND tm.user_id = mt.created_by
+		WHERE mt.token_hash = ?
+	`, hashToken(plaintext))
+
+	token, err := scanMachineToken(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate machine token: %w", err)
+	}
+
+	if time.Now().After(*token.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	if _, err := s.conn.Exec(`UPDATE machine_tokens SET last_used_at = ? WHERE id = ?`, time.Now().UTC(), token.ID); err != nil {
+		return nil, fmt.Errorf("failed to update machine token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListMachineTokens returns a project's machine tokens
+func (s *Store) ListMachineTokens(projectID string) ([]*MachineToken, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, name, project_id, environments, created_by, last_used_at, expires_at, created_at
+		FROM machine_tokens
+		WHERE project_id = ?
+		ORDER BY created_at
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list machine tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*MachineToken{}
+	for rows.Next() {
+		token, err := scanMachineToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan machine token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeMachineToken deletes one of a project's machine tokens and reports
+// whether it existed
+func (s *Store) RevokeMachineToken(projectID, tokenID string) (bool, error) {
+	result, err := s.conn.Exec(`DELETE FROM machine_tokens WHERE project_id = ? AND id = ?`, projectID, tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke machine token: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// scanMachineToken reads a machine_tokens row
+func scanMachineToken(row interface{ Scan(...interface{}) error }) (*MachineToken, error) {
+	var token MachineToken
+	var envs string
+	var lastUsedAt sql.NullTime
+	var expiresAt time.Time
+
+	if err := row.Scan(&token.ID, &token.Name, &token.ProjectID, &envs, &token.CreatedBy, &lastUsedAt, &expiresAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(envs), &token.Environments); err != nil {
+		return nil, fmt.Errorf("invalid environments: %w", err)
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	token.ExpiresAt = &expiresAt
+
+	return &token, nil
+}
+
+// EnsureProject creates a project owned by userID if it does not exist yet
+// and reports whether it was created
+func (s *Store) EnsureProject(projectID, userID string) (bool, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM projects WHERE id = ?`, projectID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check project: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`INSERT INTO projects (id, owner_id, created_at) VALUES (?, ?, ?)`, projectID, userID, now); err != nil {
+		return false, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO team_members (id, project_id, user_id, role, invited_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), projectID, userID, RoleAdmin, userID, now); err != nil {
+		return false, fmt.Errorf("failed to add project owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit project: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListProjects returns the projects a user is a member of, or the single
+// project with projectID when userID is empty. UpdatedAt is the time of the
+// newest snapshot.
+func (s *Store) ListProjects(userID, projectID string) ([]*Project, error) {
+	rows, err := s.conn.Query(`
+		SELECT p.id, p.name, p.owner_id, p.created_at, b.uploaded_at
+		FROM projects p
+		LEFT JOIN encrypted_blobs b ON b.project_id = p.id
+			AND b.version = (SELECT MAX(version) FROM encrypted_blobs WHERE project_id = p.id)
+		WHERE (? = '' OR p.id IN (SELECT project_id FROM team_members WHERE user_id = ?))
+			AND (? = '' OR p.id = ?)
+		ORDER BY p.created_at
+	`, userID, userID, projectID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []*Project{}
+	for rows.Next() {
+		var project Project
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&project.ID, &project.Name, &project.OwnerID, &project.CreatedAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		project.UpdatedAt = project.CreatedAt
+		if updatedAt.Valid {
+			project.UpdatedAt = updatedAt.Time
+		}
+		projects = append(projects, &project)
+	}
+
+	return projects, rows.Err()
+}
+
+// SetProjectName records a project's name. Unless replace is set, a name
+// the project already has is kept.
+func (s *Store) SetProjectName(projectID, name string, replace bool) error {
+	query := `UPDATE projects SET name = ? WHERE id = ? AND name = ''`
+	if replace {
+		query = `UPDATE projects SET name = ? WHERE id = ?`
+	}
+
+	if _, err := s.conn.Exec(query, strings.TrimSpace(name), projectID); err != nil {
+		return fmt.Errorf("failed to set project name: %w", err)
+	}
+
+	return nil
+}
+
+// SetEnvironments makes names the project's environments, keeping the IDs
+// of environments it already had
+func (s *Store) SetEnvironments(projectID string, names []string) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	keep := make(map[string]bool, len(names))
+	now := time.Now().UTC()
+	for _, name := range names {
+		if name == "" || keep[name] {
+			continue
+		}
+		keep[name] = true
+
+		if _, err := tx.Exec(`
+			INSERT INTO environments (id, project_id, name, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(project_id, name) DO NOTHING
+		`, uuid.New().String(), projectID, name, now); err != nil {
+			return fmt.Errorf("failed to add environment: %w", err)
+		}
+	}
+
+	rows, err := tx.Query(`SELECT name FROM environments WHERE project_id = ?`, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to list environments: %w", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan environment: %w", err)
+		}
+		if !keep[name] {
+			stale = append(stale, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list environments: %w", err)
+	}
+
+	for _, name := range stale {
+		if _, err := tx.Exec(`DELETE FROM environments WHERE project_id = ? AND name = ?`, projectID, name); err != nil {
+			return fmt.Errorf("failed to remove environment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit environments: %w", err)
+	}
+
+	return nil
+}
+
+// ListEnvironments returns a project's environments ordered by name
+func (s *Store) ListEnvironments(projectID string) ([]*Environment, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, project_id, name, created_at
+		FROM environments
+		WHERE project_id = ?
+		ORDER BY name
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	defer rows.Close()
+
+	environments := []*Environment{}
+	for rows.Next() {
+		var environment Environment
+		if err := rows.Scan(&environment.ID, &environment.ProjectID, &environment.Name, &environment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan environment: %w", err)
+		}
+		environments = append(environments, &environment)
+	}
+
+	return environments, rows.Err()
+}
+
+// GetProjectOwner returns the owner of a project
+func (s *Store) GetProjectOwner(projectID string) (string, error) {
+	var ownerID string
+	err := s.conn.QueryRow(`SELECT owner_id FROM projects WHERE id = ?`, projectID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get project: %w", err)
+	}
+
+	return ownerID, nil
+}
+
+// GetRole returns a user's role in a project
+func (s *Store) GetRole(projectID, userID string) (string, error) {
+	var role string
+	err := s.conn.QueryRow(`SELECT role FROM team_members WHERE project_id = ? AND user_id = ?`, projectID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return role, nil
+}
+
+// AddMember adds a user to a project, or changes their role if they are
+// already a member
+func (s *Store) AddMember(projectID, userID, role, invitedBy string) (string, error) {
+	memberID := uuid.New().String()
+
+	err := s.conn.QueryRow(`
+		INSERT INTO team_members (id, project_id, user_id, role, invited_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(project_id, user_id) DO UPDATE SET role = excluded.role
+		RETURNING id
+	`, memberID, projectID, userID, role, invitedBy, time.Now().UTC()).Scan(&memberID)
+	if err != nil {
+		return "", fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return memberID, nil
+}
+
+// RemoveMember removes a user from a project and reports whether they were
+// a member
+func (s *Store) RemoveMember(projectID, userID string) (bool, error) {
+	result, err := s.conn.Exec(`DELETE FROM team_members WHERE project_id = ? AND user_id = ?`, projectID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ListMembers returns a project's members ordered by when they joined
+func (s *Store) ListMembers(projectID string) ([]*Member, error) {
+	rows, err := s.conn.Query(`
+		SELECT tm.id, u.email, tm.role, tm.created_at, tm.user_id
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.project_id = ?
+		ORDER BY tm.created_at
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.ID, &member.Email, &member.Role, &member.CreatedAt, &member.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, &member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetMemberRole changes a member's role and reports whether they are a
+// member
+func (s *Store) SetMemberRole(projectID, userID, role string) (bool, error) {
+	result, err := s.conn.Exec(`UPDATE team_members SET role = ? WHERE project_id = ? AND user_id = ?`, role, projectID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to change role: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// invitationColumns are the columns read by scanInvitation
+const invitationColumns = `
+	i.id, i.project_id, i.project_name, i.email, i.role, i.invited_by,
+	COALESCE(u.email, ''), i.expires_at, i.created_at
+`
+
+// CreateInvitation invites an email to a project, replacing any earlier
+// invitation for the same email
+func (s *Store) CreateInvitation(projectID, projectName, email, role, invitedBy string, ttl time.Duration) (*Invitation, error) {
+	now := time.Now().UTC()
+
+	var id string
+	err := s.conn.QueryRow(`
+		INSERT INTO team_invitations (id, project_id, project_name, email, role, invited_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(project_id, email) DO UPDATE SET
+			project_name = excluded.project_name,
+			role = excluded.role,
+			invited_by = excluded.invited_by,
+			expires_at = excluded.expires_at,
+			created_at = excluded.created_at
+		RETURNING id
+	`, uuid.New().String(), projectID, projectName, email, role, invitedBy, now.Add(ttl), now).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return s.GetInvitation(id)
+}
+
+// GetInvitation returns an invitation by ID
+func (s *Store) GetInvitation(id string) (*Invitation, error) {
+	row := s.conn.QueryRow(`
+		SELECT `+invitationColumns+`
+		FROM team_invitations i
+		LEFT JOIN users u ON u.id = i.invited_by
+		WHERE i.id = ?
+	`, id)
+
+	invitation, err := scanInvitation(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// ListInvitations returns a project's invitations, including expired ones
+func (s *Store) ListInvitations(projectID string) ([]*Invitation, error) {
+	return s.queryInvitations(`WHERE i.project_id = ?`, projectID)
+}
+
+// ListInvitationsForEmail returns the unexpired invitations sent to an email
+func (s *Store) ListInvitationsForEmail(email string) ([]*Invitation, error) {
+	return s.queryInvitations(`WHERE i.email = ? AND i.expires_at > ?`, email, time.Now().UTC())
+}
+
+// queryInvitations lists invitations matching a WHERE clause
+func (s *Store) queryInvitations(where string, args ...interface{}) ([]*Invitation, error) {
+	rows, err := s.conn.Query(`
+		SELECT `+invitationColumns+`
+		FROM team_invitations i
+		LEFT JOIN users u ON u.id = i.invited_by
+		`+where+`
+		ORDER BY i.created_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// DeleteInvitation removes one of a project's invitations and reports
+// whether it existed
+func (s *Store) DeleteInvitation(projectID, id string) (bool, error) {
+	result, err := s.conn.Exec(`DELETE FROM team_invitations WHERE project_id = ? AND id = ?`, projectID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// AcceptInvitation makes userID a member of the invitation's project with
+// the invited role and removes the invitation
+func (s *Store) AcceptInvitation(invitation *Invitation, userID string) (string, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	memberID := uuid.New().String()
+	err = tx.QueryRow(`
+		INSERT INTO team_members (id, project_id, user_id, role, invited_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(project_id, user_id) DO UPDATE SET role = excluded.role
+		RETURNING id
+	`, memberID, invitation.ProjectID, userID, invitation.Role, invitation.InvitedBy, time.Now().UTC()).Scan(&memberID)
+	if err != nil {
+		return "", fmt.Errorf("failed to add member: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM team_invitations WHERE id = ?`, invitation.ID); err != nil {
+		return "", fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	return memberID, nil
+}
+
+// scanInvitation reads a row selected with invitationColumns
+func scanInvitation(row interface{ Scan(...interface{}) error }) (*Invitation, error) {
+	var invitation Invitation
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.ProjectID,
+		&invitation.ProjectName,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.InvitedByEmail,
+		&invitation.ExpiresAt,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// PushBlob stores the next version of a project's blob along with its
+// per-environment blobs
+func (s *Store) PushBlob(projectID, encryptedData, checksum, userID string, environments []EnvironmentBlob) (*Blob, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	blob := &Blob{
+		ID:            uuid.New().String(),
+		EncryptedData: encryptedData,
+		Checksum:      checksum,
+		UploadedAt:    time.Now().UTC(),
+	}
+
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) + 1 FROM encrypted_blobs WHERE project_id = ?`, projectID).
+		Scan(&blob.Version); err != nil {
+		return nil, fmt.Errorf("failed to get next version: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO encrypted_blobs (id, project_id, version, encrypted_data, checksum, uploaded_by, uploaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, blob.ID, projectID, blob.Version, encryptedData, checksum, userID, blob.UploadedAt); err != nil {
+		return nil, fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	for _, env := range environments {
+		if _, err := tx.Exec(`
+			INSERT INTO encrypted_environment_blobs (blob_id, environment, encrypted_data, checksum)
+			VALUES (?, ?, ?, ?)
+		`, blob.ID, env.Environment, env.EncryptedData, env.Checksum); err != nil {
+			return nil, fmt.Errorf("failed to store blob for %s: %w", env.Environment, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit blob: %w", err)
+	}
+
+	return blob, nil
+}
+
+// PullBlob returns the newest blob for a project, or nil if there is none
+// newer than sinceVersion
+func (s *Store) PullBlob(projectID string, sinceVersion *int) (*Blob, error) {
+	since := 0
+	if sinceVersion != nil {
+		since = *sinceVersion
+	}
+
+	var blob Blob
+	err := s.conn.QueryRow(`
+		SELECT id, version, encrypted_data, checksum, uploaded_at
+		FROM encrypted_blobs
+		WHERE project_id = ? AND version > ?
+		ORDER BY version DESC
+		LIMIT 1
+	`, projectID, since).Scan(&blob.ID, &blob.Version, &blob.EncryptedData, &blob.Checksum, &blob.UploadedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	return &blob, nil
+}
+
+// ListEnvironmentBlobs returns the per-environment blobs stored with a blob.
+// Blobs pushed by older CLIs have none.
+func (s *Store) ListEnvironmentBlobs(blobID string) ([]EnvironmentBlob, error) {
+	rows, err := s.conn.Query(`
+		SELECT environment, encrypted_data, checksum
+		FROM encrypted_environment_blobs
+		WHERE blob_id = ?
+		ORDER BY environment
+	`, blobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environment blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var environments []EnvironmentBlob
+	for rows.Next() {
+		var env EnvironmentBlob
+		if err := rows.Scan(&env.Environment, &env.EncryptedData, &env.Checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan environment blob: %w", err)
+		}
+		environments = append(environments, env)
+	}
+
+	return environments, rows.Err()
+}
+
+// CreateAuditLog records an action
+func (s *Store) CreateAuditLog(projectID, userID, action, metadata string) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO audit_logs (id, project_id, user_id, action, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), projectID, userID, action, metadata, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// hashToken returns the stored form of a token
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
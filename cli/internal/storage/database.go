@@ -88,8 +88,14 @@ func (db *DB) initSchema() error {
 
 // CreateProject creates a new project
 func (db *DB) CreateProject(name, description, ownerID string) (*models.Project, error) {
+	return db.CreateProjectWithID(uuid.New().String(), name, description, ownerID)
+}
+
+// CreateProjectWithID creates a project with a known ID, e.g. one cloned
+// from a remote
+func (db *DB) CreateProjectWithID(id, name, description, ownerID string) (*models.Project, error) {
 	project := &models.Project{
-		ID:          uuid.New().String(),
+		ID:          id,
 		Name:        name,
 		Description: description,
 		OwnerID:     ownerID,
@@ -1,6 +1,10 @@
 package storage
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
 
 // DeleteEnvironment deletes an environment and all its secrets
 func (db *DB) DeleteEnvironment(id string) error {
@@ -21,3 +25,38 @@ func (db *DB) DeleteEnvironment(id string) error {
 
 	return nil
 }
+
+// SecretsChecksum returns a checksum of an environment's secrets that
+// changes whenever one is set or deleted. Every write encrypts the value
+// with a fresh nonce, so setting a secret to the same value changes it too.
+func (db *DB) SecretsChecksum(environmentID string) (string, error) {
+	query := `
+		SELECT key, encrypted_value
+		FROM secrets
+		WHERE environment_id = ?
+		ORDER BY key
+	`
+
+	rows, err := db.conn.Query(query, environmentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	hash := sha256.New()
+	for rows.Next() {
+		var key string
+		var encryptedValue []byte
+		if err := rows.Scan(&key, &encryptedValue); err != nil {
+			return "", fmt.Errorf("failed to scan secret: %w", err)
+		}
+
+		fmt.Fprintf(hash, "%s\x00%d\x00", key, len(encryptedValue))
+		hash.Write(encryptedValue)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/models"
+)
+
+// GetProjectRole returns the cached role of a user in a project on a remote,
+// or nil if it has never been fetched
+func (db *DB) GetProjectRole(projectID, remote, userID string) (*models.ProjectRole, error) {
+	query := `
+		SELECT project_id, remote, user_id, role, fetched_at
+		FROM project_roles
+		WHERE project_id = ? AND remote = ? AND user_id = ?
+	`
+
+	var role models.ProjectRole
+	err := db.conn.QueryRow(query, projectID, remote, userID).Scan(
+		&role.ProjectID,
+		&role.Remote,
+		&role.UserID,
+		&role.Role,
+		&role.FetchedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project role: %w", err)
+	}
+
+	return &role, nil
+}
+
+// SetProjectRole caches the role of a user in a project on a remote
+func (db *DB) SetProjectRole(projectID, remote, userID, role string) error {
+	query := `
+		INSERT INTO project_roles (project_id, remote, user_id, role, fetched_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(project_id, remote, user_id) DO UPDATE SET
+			role = excluded.role,
+			fetched_at = excluded.fetched_at
+	`
+
+	if _, err := db.conn.Exec(query, projectID, remote, userID, role, time.Now()); err != nil {
+		return fmt.Errorf("failed to cache project role: %w", err)
+	}
+
+	return nil
+}
@@ -85,6 +85,42 @@ CREATE TABLE IF NOT EXISTS sync_metadata (
     FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
 );
 
+-- Sync state per named remote (sync_metadata holds the pre-remotes state)
+CREATE TABLE IF NOT EXISTS remote_sync_state (
+    project_id TEXT NOT NULL,
+    remote TEXT NOT NULL,
+    last_sync_at DATETIME,
+    version INTEGER NOT NULL DEFAULT 0,
+    checksum TEXT,
+    PRIMARY KEY (project_id, remote),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+-- Roles reported by API remotes, cached for offline permission checks
+CREATE TABLE IF NOT EXISTS project_roles (
+    project_id TEXT NOT NULL,
+    remote TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    PRIMARY KEY (project_id, remote, user_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+-- Sync outbox (local changes waiting to be pushed, replayed in id order)
+CREATE TABLE IF NOT EXISTS sync_outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id TEXT NOT NULL,
+    operation TEXT NOT NULL,
+    environment TEXT NOT NULL,
+    key TEXT,
+    encrypted_value BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_outbox_project ON sync_outbox(project_id, id);
+
 -- Triggers for updated_at timestamps
 CREATE TRIGGER IF NOT EXISTS update_projects_timestamp
     AFTER UPDATE ON projects
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dj-pearson/envault/internal/models"
+)
+
+// SetProjectSyncEnabled marks a project as synced (or local only)
+func (db *DB) SetProjectSyncEnabled(projectID string, enabled bool) error {
+	query := `UPDATE projects SET sync_enabled = ? WHERE id = ?`
+	if _, err := db.conn.Exec(query, boolToInt(enabled), projectID); err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+	return nil
+}
+
+// EnqueueOutbox records a local change that still needs to be pushed
+func (db *DB) EnqueueOutbox(projectID, operation, environment, key string, encryptedValue []byte) error {
+	query := `
+		INSERT INTO sync_outbox (project_id, operation, environment, key, encrypted_value, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := db.conn.Exec(query, projectID, operation, environment, key, encryptedValue, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to queue change: %w", err)
+	}
+
+	return nil
+}
+
+// ListOutbox lists pending changes for a project in the order they were made
+func (db *DB) ListOutbox(projectID string) ([]*models.OutboxEntry, error) {
+	query := `
+		SELECT id, project_id, operation, environment, key, encrypted_value, created_at
+		FROM sync_outbox
+		WHERE project_id = ?
+		ORDER BY id
+	`
+
+	rows, err := db.conn.Query(query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending changes: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		var key sql.NullString
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ProjectID,
+			&entry.Operation,
+			&entry.Environment,
+			&key,
+			&entry.EncryptedValue,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending change: %w", err)
+		}
+
+		if key.Valid {
+			entry.Key = key.String
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// CountOutbox returns the number of pending changes for a project
+func (db *DB) CountOutbox(projectID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM sync_outbox WHERE project_id = ?`
+	if err := db.conn.QueryRow(query, projectID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending changes: %w", err)
+	}
+	return count, nil
+}
+
+// ClearOutbox removes pending changes up to and including lastID once they
+// have been pushed. Changes queued after lastID are kept.
+func (db *DB) ClearOutbox(projectID string, lastID int64) error {
+	query := `DELETE FROM sync_outbox WHERE project_id = ? AND id <= ?`
+	if _, err := db.conn.Exec(query, projectID, lastID); err != nil {
+		return fmt.Errorf("failed to clear pending changes: %w", err)
+	}
+	return nil
+}
+
+// GetSyncMetadata returns a project's sync state for a remote, or nil if the
+// project has never been synced with it. Projects without named remotes use
+// the empty remote name, which also picks up state recorded by versions of
+// envault that predate remotes.
+func (db *DB) GetSyncMetadata(projectID, remote string) (*models.SyncMetadata, error) {
+	query := `
+		SELECT project_id, remote, last_sync_at, version, checksum
+		FROM remote_sync_state
+		WHERE project_id = ? AND remote = ?
+	`
+
+	meta, err := scanSyncMetadata(db.conn.QueryRow(query, projectID, remote))
+	if err != nil || meta != nil || remote != "" {
+		return meta, err
+	}
+
+	legacy := `
+		SELECT project_id, '', last_sync_at, version, checksum
+		FROM sync_metadata
+		WHERE project_id = ?
+	`
+
+	return scanSyncMetadata(db.conn.QueryRow(legacy, projectID))
+}
+
+// scanSyncMetadata scans a sync state row, returning nil if there is none
+func scanSyncMetadata(row *sql.Row) (*models.SyncMetadata, error) {
+	var meta models.SyncMetadata
+	var lastSyncAt sql.NullTime
+	var checksum sql.NullString
+
+	err := row.Scan(
+		&meta.ProjectID,
+		&meta.Remote,
+		&lastSyncAt,
+		&meta.Version,
+		&checksum,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync metadata: %w", err)
+	}
+
+	if lastSyncAt.Valid {
+		meta.LastSyncAt = &lastSyncAt.Time
+	}
+	if checksum.Valid {
+		meta.Checksum = checksum.String
+	}
+
+	return &meta, nil
+}
+
+// UpdateSyncMetadata records the blob version a project was last synced with
+// on a remote
+func (db *DB) UpdateSyncMetadata(projectID, remote string, version int, checksum string) error {
+	query := `
+		INSERT INTO remote_sync_state (project_id, remote, last_sync_at, version, checksum)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(project_id, remote) DO UPDATE SET
+			last_sync_at = excluded.last_sync_at,
+			version = excluded.version,
+			checksum = excluded.checksum
+	`
+
+	_, err := db.conn.Exec(query, projectID, remote, time.Now(), version, checksum)
+	if err != nil {
+		return fmt.Errorf("failed to update sync metadata: %w", err)
+	}
+
+	return nil
+}
+
+// ResetSyncMetadata forgets the last synced version for a remote so the next
+// pull fetches the latest blob, e.g. after the remote's URL changed
+func (db *DB) ResetSyncMetadata(projectID, remote string) error {
+	if _, err := db.conn.Exec(`DELETE FROM remote_sync_state WHERE project_id = ? AND remote = ?`, projectID, remote); err != nil {
+		return fmt.Errorf("failed to reset sync metadata: %w", err)
+	}
+
+	if remote == "" {
+		if _, err := db.conn.Exec(`DELETE FROM sync_metadata WHERE project_id = ?`, projectID); err != nil {
+			return fmt.Errorf("failed to reset sync metadata: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package supervisor
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// outputDrainTimeout bounds how long output is still copied after the
+// process exits, in case something it started in the background keeps its
+// output open
+const outputDrainTimeout = 200 * time.Millisecond
+
+// FilterOutput passes the process's standard output and error through
+// filter, e.g. to redact secrets. Filters can be stacked; the first one
+// added sees the process's raw output. When envault's own output is a
+// terminal the process gets a pseudo-terminal instead of a pipe where the
+// platform allows, so it keeps its colours and line buffering.
+func (p *Process) FilterOutput(filter func(w io.Writer) io.WriteCloser) {
+	p.filters = append(p.filters, filter)
+}
+
+// setupOutput wires the filter between the process and its outputs before
+// it starts
+func (p *Process) setupOutput() {
+	if len(p.filters) == 0 {
+		return
+	}
+
+	if p.Cmd.Stdout == os.Stdout && isTerminal(os.Stdout) {
+		if master, slave, err := openPTY(); err == nil {
+			resizePTY(master, os.Stdout)
+			p.pty, p.ptySlave = master, slave
+			p.Cmd.Stdout = slave
+			if p.Cmd.Stderr == os.Stderr && isTerminal(os.Stderr) {
+				p.Cmd.Stderr = slave
+			}
+		}
+	}
+
+	if p.Cmd.Stdout != p.ptySlave {
+		p.Cmd.Stdout = p.wrap(p.Cmd.Stdout)
+	}
+	if p.Cmd.Stderr != p.ptySlave {
+		p.Cmd.Stderr = p.wrap(p.Cmd.Stderr)
+	}
+	p.Cmd.WaitDelay = outputDrainTimeout
+}
+
+// wrap filters w, remembering the filters so they can be flushed on exit,
+// outermost first
+func (p *Process) wrap(w io.Writer) io.Writer {
+	if w == nil {
+		return nil
+	}
+
+	chain := make([]io.Closer, 0, len(p.filters))
+	for i := len(p.filters) - 1; i >= 0; i-- {
+		filtered := p.filters[i](w)
+		chain = append(chain, filtered)
+		w = filtered
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		p.closers = append(p.closers, chain[i])
+	}
+	return w
+}
+
+// startOutput starts copying from the pseudo-terminal once the process has
+// started
+func (p *Process) startOutput() {
+	if p.pty == nil {
+		return
+	}
+
+	// Only the child should hold the terminal open now
+	p.ptySlave.Close()
+
+	out := p.wrap(os.Stdout)
+	stopResize := watchResize(p.pty, os.Stdout)
+
+	p.ptyDone = make(chan struct{})
+	go func() {
+		defer close(p.ptyDone)
+		defer stopResize()
+
+		// Reading fails with EIO once the child has closed the terminal
+		io.Copy(out, p.pty)
+	}()
+}
+
+// finishOutput drains and flushes the filtered output after the process
+// has exited
+func (p *Process) finishOutput() {
+	if p.pty != nil {
+		select {
+		case <-p.ptyDone:
+		case <-time.After(outputDrainTimeout):
+		}
+		p.pty.Close()
+		<-p.ptyDone
+	}
+
+	for _, closer := range p.closers {
+		closer.Close()
+	}
+}
+
+// closeOutput releases the pseudo-terminal if the process failed to start
+func (p *Process) closeOutput() {
+	if p.pty != nil {
+		p.ptySlave.Close()
+		p.pty.Close()
+	}
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd())
+}
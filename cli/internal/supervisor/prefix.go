@@ -0,0 +1,66 @@
+package supervisor
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixLines returns a filter for FilterOutput that starts every line of
+// output with prefix, so that several processes can share a terminal. An
+// unfinished last line is written when the process exits.
+func PrefixLines(prefix string) func(w io.Writer) io.WriteCloser {
+	return func(w io.Writer) io.WriteCloser {
+		return &prefixWriter{w: w, prefix: []byte(prefix)}
+	}
+}
+
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.pending = append(pw.pending, p...)
+
+	end := bytes.LastIndexByte(pw.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+
+	// Write complete lines in one go so they don't interleave with other
+	// processes' output
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(pw.pending[:end+1], []byte("\n")) {
+		if len(line) > 0 {
+			out.Write(pw.prefix)
+			out.Write(line)
+		}
+	}
+	pw.pending = append(pw.pending[:0], pw.pending[end+1:]...)
+
+	if _, err := pw.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (pw *prefixWriter) Close() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if len(pw.pending) == 0 {
+		return nil
+	}
+
+	line := append(append(append([]byte(nil), pw.prefix...), pw.pending...), '\n')
+	pw.pending = nil
+	_, err := pw.w.Write(line)
+	return err
+}
@@ -0,0 +1,58 @@
+//go:build darwin
+// +build darwin
+
+package supervisor
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a pseudo-terminal pair (macOS)
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fd := master.Fd()
+	var name [128]byte
+	for _, req := range []struct {
+		op  uintptr
+		arg uintptr
+	}{
+		{unix.TIOCPTYGRANT, 0},
+		{unix.TIOCPTYUNLK, 0},
+		{unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))},
+	} {
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req.op, req.arg); errno != 0 {
+			master.Close()
+			return nil, nil, errno
+		}
+	}
+
+	path := string(name[:bytes.IndexByte(name[:], 0)])
+	slave, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	if err := disableNewlineTranslation(slave); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// Termios requests (macOS)
+const (
+	ioctlReadTermios  = unix.TIOCGETA
+	ioctlWriteTermios = unix.TIOCSETA
+)
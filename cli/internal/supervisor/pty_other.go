@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+)
+
+// openPTY reports that pseudo-terminals are not supported here; output is
+// filtered through pipes instead
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("pseudo-terminals are not supported on this platform")
+}
+
+func foregroundProcessGroup() (int, error) {
+	return 0, errors.New("terminal process groups are not supported on this platform")
+}
+
+func resizePTY(pty, term *os.File) {}
+
+func watchResize(pty, term *os.File) func() {
+	return func() {}
+}
@@ -0,0 +1,69 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package supervisor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// disableNewlineTranslation stops the pseudo-terminal from turning \n into
+// \r\n; envault's own terminal does that when the output reaches it
+func disableNewlineTranslation(tty *os.File) error {
+	termios, err := unix.IoctlGetTermios(int(tty.Fd()), ioctlReadTermios)
+	if err != nil {
+		return err
+	}
+
+	termios.Oflag &^= unix.ONLCR
+	return unix.IoctlSetTermios(int(tty.Fd()), ioctlWriteTermios, termios)
+}
+
+// foregroundProcessGroup returns the foreground process group of envault's
+// controlling terminal
+func foregroundProcessGroup() (int, error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return 0, err
+	}
+	defer tty.Close()
+
+	return unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+}
+
+// resizePTY gives the pseudo-terminal the size of the terminal term
+func resizePTY(pty, term *os.File) {
+	size, err := unix.IoctlGetWinsize(int(term.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return
+	}
+	unix.IoctlSetWinsize(int(pty.Fd()), unix.TIOCSWINSZ, size)
+}
+
+// watchResize keeps the pseudo-terminal's size in step with term until the
+// returned function is called. The kernel then signals the child itself.
+func watchResize(pty, term *os.File) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				resizePTY(pty, term)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(quit)
+	}
+}
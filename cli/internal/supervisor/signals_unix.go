@@ -0,0 +1,91 @@
+//go:build !windows
+// +build !windows
+
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// forwardedSignals are relayed to supervised processes. Job control signals
+// are left alone so that Ctrl-Z suspends envault and the child together.
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+}
+
+// terminalSignals are sent by the terminal to its whole foreground process
+// group when the user types Ctrl-C or Ctrl-\
+var terminalSignals = map[os.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGQUIT: true,
+}
+
+// alreadyDelivered reports whether sig most likely came from the terminal
+// and so has reached p already: envault is in the terminal's foreground
+// process group and p is in envault's group. Relaying it as well would make
+// programs that treat a second Ctrl-C as "force quit" skip their shutdown.
+func alreadyDelivered(p *Process, sig os.Signal) bool {
+	if !terminalSignals[sig] {
+		return false
+	}
+
+	if attr := p.Cmd.SysProcAttr; attr != nil && (attr.Setpgid || attr.Setsid) {
+		return false
+	}
+
+	foreground, err := foregroundProcessGroup()
+	return err == nil && foreground == syscall.Getpgrp()
+}
+
+// signalNames are the signals ParseSignal accepts by name
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses a signal given as SIGTERM, TERM or 15
+func ParseSignal(name string) (os.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+
+	return nil, fmt.Errorf("unknown signal %q (use e.g. SIGTERM, SIGINT or SIGHUP)", name)
+}
+
+// relay sends sig to a process (Unix)
+func relay(process *os.Process, sig os.Signal) error {
+	return process.Signal(sig)
+}
+
+// ExitCode returns a process's exit code, using the shell convention of
+// 128+N for a process killed by signal N
+func ExitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
@@ -0,0 +1,46 @@
+//go:build windows
+// +build windows
+
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// forwardedSignals are caught so that Ctrl-C does not end envault before
+// the child. The console delivers Ctrl-C to the child itself.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// alreadyDelivered reports whether sig has reached p already. The console
+// delivers Ctrl-C itself, and relay cannot send it anyway.
+func alreadyDelivered(p *Process, sig os.Signal) bool {
+	return sig == os.Interrupt
+}
+
+// ParseSignal parses a signal name. Windows can only kill processes, so
+// only SIGKILL is accepted.
+func ParseSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "KILL", "9":
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("signal %q is not supported on Windows; use SIGKILL", name)
+}
+
+// relay sends sig to a process (Windows). Only os.Kill can be delivered.
+func relay(process *os.Process, sig os.Signal) error {
+	if sig == os.Kill {
+		return process.Kill()
+	}
+	return fmt.Errorf("signal %v is not supported on Windows", sig)
+}
+
+// ExitCode returns a process's exit code
+func ExitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	return state.ExitCode()
+}
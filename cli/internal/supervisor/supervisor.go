@@ -0,0 +1,200 @@
+// Package supervisor runs child processes on behalf of envault: it starts
+// them with os/exec, relays the signals envault receives, reports how they
+// exited and runs cleanup hooks once they are gone.
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// Process is a supervised child process
+type Process struct {
+	// Cmd is the underlying command. Its standard streams default to
+	// envault's own and may be replaced before Start.
+	Cmd *exec.Cmd
+
+	hooks    []func(code int)
+	hookOnce sync.Once
+	done     chan struct{}
+	code     int
+	err      error
+
+	// Output filtering, see FilterOutput
+	filters  []func(w io.Writer) io.WriteCloser
+	closers  []io.Closer
+	pty      *os.File
+	ptySlave *os.File
+	ptyDone  chan struct{}
+}
+
+// New prepares a process that runs path with args (args[0] is the program
+// name) and the given environment
+func New(path string, args []string, env []string) *Process {
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Env:    env,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	return &Process{Cmd: cmd, done: make(chan struct{})}
+}
+
+// AfterExit registers a hook that runs once the process has exited, with its
+// exit code. Hooks run in reverse order of registration, like deferred
+// calls, and also run if the process fails to start (with code -1).
+func (p *Process) AfterExit(hook func(code int)) {
+	p.hooks = append(p.hooks, hook)
+}
+
+// Start starts the process without waiting for it
+func (p *Process) Start() error {
+	p.setupOutput()
+
+	if err := p.Cmd.Start(); err != nil {
+		p.closeOutput()
+		p.code, p.err = -1, err
+		close(p.done)
+		p.runHooks()
+		return err
+	}
+
+	p.startOutput()
+
+	go func() {
+		err := p.Cmd.Wait()
+		p.code = ExitCode(p.Cmd.ProcessState)
+		p.finishOutput()
+
+		// A non-zero exit is reported through the code, not as an error,
+		// and output left open by background processes is not an error
+		// either
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+			p.err = err
+		}
+
+		close(p.done)
+	}()
+
+	return nil
+}
+
+// Done is closed when the process has exited
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the process to exit, runs the exit hooks and returns the
+// exit code. An error is only returned if waiting itself failed.
+func (p *Process) Wait() (int, error) {
+	<-p.done
+	p.runHooks()
+	return p.code, p.err
+}
+
+// Signal sends sig to the process if it is still running
+func (p *Process) Signal(sig os.Signal) error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	if p.Cmd.Process == nil {
+		return fmt.Errorf("process not started")
+	}
+
+	return relay(p.Cmd.Process, sig)
+}
+
+// Stop asks the process to exit with sig and kills it if it is still running
+// after grace. It returns once the process has exited.
+func (p *Process) Stop(sig os.Signal, grace time.Duration) {
+	if p.Cmd.Process == nil {
+		return
+	}
+
+	if err := p.Signal(sig); err != nil {
+		p.Cmd.Process.Kill()
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+	case <-timer.C:
+		p.Cmd.Process.Kill()
+		<-p.done
+	}
+}
+
+// Run starts the process, relays the signals envault receives to it until
+// it exits and then returns as Wait does
+func (p *Process) Run() (int, error) {
+	if err := p.Start(); err != nil {
+		return -1, err
+	}
+
+	stop := Forward(p)
+	defer stop()
+
+	return p.Wait()
+}
+
+// Forward relays the signals envault receives to the given processes until
+// the returned function is called. Signals that only make sense for the
+// controlling terminal are relayed too, so interactive children keep
+// working. Ctrl-C and Ctrl-\ typed at the terminal already reach children
+// in envault's foreground process group and are not sent a second time; a
+// SIGINT or SIGQUIT sent to envault with kill while it is in the foreground
+// is therefore not relayed either.
+func Forward(processes ...*Process) func() {
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, forwardedSignals...)
+
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case sig := <-signals:
+				for _, p := range processes {
+					if !alreadyDelivered(p, sig) {
+						p.Signal(sig)
+					}
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(quit)
+		wg.Wait()
+	}
+}
+
+// runHooks runs the exit hooks once
+func (p *Process) runHooks() {
+	p.hookOnce.Do(func() {
+		for i := len(p.hooks) - 1; i >= 0; i-- {
+			p.hooks[i](p.code)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MinRedactLength is the shortest value a Redactor masks. Shorter values
+// such as "1" or "dev" would mangle unrelated output.
+const MinRedactLength = 4
+
+// redactFlushDelay is how long a Redactor waits for more output before
+// writing the complete lines it holds back that cannot be part of a secret
+const redactFlushDelay = 50 * time.Millisecond
+
+// Redactor replaces secret values in output with ***KEY***. Besides the
+// raw value it matches the value's base64 (standard and URL alphabets,
+// with or without padding) and URL-encoded forms.
+type Redactor struct {
+	patterns []redactPattern
+	maxLen   int
+}
+
+type redactPattern struct {
+	match       []byte
+	replacement []byte
+}
+
+// NewRedactor creates a Redactor for the given KEY=value secrets. Values
+// shorter than MinRedactLength are not masked.
+func NewRedactor(secrets map[string]string) *Redactor {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	r := &Redactor{}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		value := secrets[key]
+		if len(value) < MinRedactLength {
+			continue
+		}
+
+		replacement := []byte("***" + key + "***")
+		for _, form := range encodedForms(value) {
+			if seen[form] {
+				continue
+			}
+			seen[form] = true
+
+			r.patterns = append(r.patterns, redactPattern{match: []byte(form), replacement: replacement})
+			if len(form) > r.maxLen {
+				r.maxLen = len(form)
+			}
+		}
+	}
+
+	// Prefer the longest match where several start at the same place
+	sort.SliceStable(r.patterns, func(i, j int) bool {
+		return len(r.patterns[i].match) > len(r.patterns[j].match)
+	})
+
+	return r
+}
+
+// encodedForms returns the ways a value is likely to show up in output
+func encodedForms(value string) []string {
+	data := []byte(value)
+	forms := []string{
+		value,
+		base64.StdEncoding.EncodeToString(data),
+		base64.RawStdEncoding.EncodeToString(data),
+		base64.URLEncoding.EncodeToString(data),
+		base64.RawURLEncoding.EncodeToString(data),
+		url.QueryEscape(value),
+		url.PathEscape(value),
+	}
+
+	// Terminals and Windows programs write \r\n
+	if strings.Contains(value, "\n") {
+		forms = append(forms, strings.ReplaceAll(value, "\n", "\r\n"))
+	}
+
+	return forms
+}
+
+// Empty reports whether there is nothing to redact
+func (r *Redactor) Empty() bool {
+	return len(r.patterns) == 0
+}
+
+// Redact masks every secret in s
+func (r *Redactor) Redact(s string) string {
+	out, rest := r.scan([]byte(s), true)
+	return string(append(out, rest...))
+}
+
+// scan masks the secrets in buf. Unless final, it holds back a tail that
+// could be the start of a secret completed by later output.
+func (r *Redactor) scan(buf []byte, final bool) ([]byte, []byte) {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(buf) {
+		index, pattern := r.nextMatch(buf[i:])
+		if pattern == nil {
+			break
+		}
+
+		start := i + index
+		if !final && r.longerPrefix(buf[start:], len(pattern.match)) {
+			out.Write(buf[i:start])
+			return out.Bytes(), buf[start:]
+		}
+
+		out.Write(buf[i:start])
+		out.Write(pattern.replacement)
+		i = start + len(pattern.match)
+	}
+
+	rest := buf[i:]
+	if final {
+		out.Write(rest)
+		return out.Bytes(), nil
+	}
+
+	keep := r.partialSuffix(rest)
+	out.Write(rest[:len(rest)-keep])
+	return out.Bytes(), rest[len(rest)-keep:]
+}
+
+// nextMatch finds the earliest, longest secret in buf
+func (r *Redactor) nextMatch(buf []byte) (int, *redactPattern) {
+	best := -1
+	var match *redactPattern
+	for i := range r.patterns {
+		index := bytes.Index(buf, r.patterns[i].match)
+		if index >= 0 && (best < 0 || index < best) {
+			best, match = index, &r.patterns[i]
+		}
+	}
+	return best, match
+}
+
+// longerPrefix reports whether tail could still grow into a secret longer
+// than n bytes
+func (r *Redactor) longerPrefix(tail []byte, n int) bool {
+	for _, pattern := range r.patterns {
+		if len(pattern.match) > n && len(pattern.match) > len(tail) && bytes.HasPrefix(pattern.match, tail) {
+			return true
+		}
+	}
+	return false
+}
+
+// partialSuffix returns the length of the longest suffix of buf that is the
+// start of a secret
+func (r *Redactor) partialSuffix(buf []byte) int {
+	longest := r.maxLen - 1
+	if len(buf) < longest {
+		longest = len(buf)
+	}
+
+	for k := longest; k > 0; k-- {
+		suffix := buf[len(buf)-k:]
+		for _, pattern := range r.patterns {
+			if bytes.HasPrefix(pattern.match, suffix) {
+				return k
+			}
+		}
+	}
+	return 0
+}
+
+// Writer returns a writer that masks secrets in everything written to it
+// before passing it on to w. Output that might be the start of a secret is
+// held back until the next write. If none comes within a few milliseconds
+// the complete lines before it are written, but output that may be part of
+// a secret, even several lines of one, stays held back however long the
+// program takes to finish it. Close flushes what is left.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	return &redactWriter{redactor: r, w: w}
+}
+
+type redactWriter struct {
+	redactor *Redactor
+	w        io.Writer
+
+	mu      sync.Mutex
+	pending []byte
+	timer   *time.Timer
+	err     error
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	if rw.timer != nil {
+		rw.timer.Stop()
+	}
+
+	buf := append(rw.pending, p...)
+	out, keep := rw.redactor.scan(buf, false)
+	rw.pending = append([]byte(nil), keep...)
+
+	if len(out) > 0 {
+		if _, err := rw.w.Write(out); err != nil {
+			rw.err = err
+			return 0, err
+		}
+	}
+
+	if len(rw.pending) > 0 {
+		rw.timer = time.AfterFunc(redactFlushDelay, rw.flushLines)
+	}
+
+	return len(p), nil
+}
+
+// flushLines writes out the held-back lines that end before anything that
+// could still grow into a secret. From there on, including whole lines of
+// a multi-line secret such as a PEM key, output may be the start of a
+// secret a slow program has not finished writing, and is kept until the
+// next write or Close.
+func (rw *redactWriter) flushLines() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	start := len(rw.pending) - rw.redactor.partialSuffix(rw.pending)
+	end := bytes.LastIndexByte(rw.pending[:start], '\n') + 1
+	if end == 0 || rw.err != nil {
+		return
+	}
+
+	out, _ := rw.redactor.scan(rw.pending[:end], true)
+	_, rw.err = rw.w.Write(out)
+	rw.pending = append([]byte(nil), rw.pending[end:]...)
+}
+
+// flush writes out all held-back output, masking any secret it completes
+func (rw *redactWriter) flush() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if len(rw.pending) == 0 || rw.err != nil {
+		return
+	}
+
+	out, _ := rw.redactor.scan(rw.pending, true)
+	_, rw.err = rw.w.Write(out)
+	rw.pending = nil
+}
+
+func (rw *redactWriter) Close() error {
+	rw.mu.Lock()
+	if rw.timer != nil {
+		rw.timer.Stop()
+	}
+	rw.mu.Unlock()
+
+	rw.flush()
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.err
+}
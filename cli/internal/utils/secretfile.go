@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// SecretDir is a private directory holding secrets for programs that only
+// read them from files, such as GOOGLE_APPLICATION_CREDENTIALS
+type SecretDir struct {
+	path  string
+	files []string
+}
+
+// NewSecretDir creates a 0700 directory for secret files, in memory-backed
+// storage where the system has it
+func NewSecretDir() (*SecretDir, error) {
+	path, err := os.MkdirTemp(secretDirBase(), "envault-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret directory: %w", err)
+	}
+
+	if err := EnsureSecureDirPermissions(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &SecretDir{path: path}, nil
+}
+
+// secretDirBase returns where secret directories are created: the user's
+// runtime directory or /dev/shm on Linux, both tmpfs, and the temporary
+// directory elsewhere
+func secretDirBase() string {
+	if runtime.GOOS == "linux" {
+		for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+			if dir == "" {
+				continue
+			}
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return dir
+			}
+		}
+	}
+	return os.TempDir()
+}
+
+// Path returns the directory's path
+func (d *SecretDir) Path() string {
+	return d.path
+}
+
+// WriteFile writes value to a 0600 file called name and returns its path
+func (d *SecretDir) WriteFile(name, value string) (string, error) {
+	path := filepath.Join(d.path, name)
+
+	file, err := CreateSecureFile(path)
+	if err != nil {
+		return "", err
+	}
+	d.files = append(d.files, path)
+
+	if _, err := file.WriteString(value); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return path, nil
+}
+
+// Remove securely deletes the files and removes the directory. Files that
+// are already gone are skipped.
+func (d *SecretDir) Remove() error {
+	var firstErr error
+	for _, path := range d.files {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := SecureDelete(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	d.files = nil
+
+	if err := os.RemoveAll(d.path); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("failed to remove secret directory: %w", err)
+	}
+
+	return firstErr
+}
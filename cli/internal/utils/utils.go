@@ -6,13 +6,36 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dj-pearson/envault/internal/envfile"
 )
 
 // ProjectContext holds the current project information
 type ProjectContext struct {
-	ProjectID   string
-	ProjectName string
+	ProjectID     string
+	ProjectName   string
+	Remotes       map[string]string
+	DefaultRemote string
+	// Account is the login profile pinned for this project, if any
+	Account string
+}
+
+// RemoteKeyPrefix prefixes remote entries in the .envault file, which are
+// stored as remote.NAME=URL
+const RemoteKeyPrefix = "remote."
+
+// RemoteNames returns the project's remote names in sorted order
+func (ctx *ProjectContext) RemoteNames() []string {
+	names := make([]string, 0, len(ctx.Remotes))
+	for name := range ctx.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // LoadProjectContext loads project context from .envault file
@@ -28,7 +51,7 @@ func LoadProjectContext() (*ProjectContext, error) {
 	}
 
 	// Parse .envault file
-	ctx := &ProjectContext{}
+	ctx := &ProjectContext{Remotes: make(map[string]string)}
 	file, err := os.Open(envaultFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open .envault: %w", err)
@@ -55,6 +78,19 @@ func LoadProjectContext() (*ProjectContext, error) {
 			ctx.ProjectID = value
 		case "project_name":
 			ctx.ProjectName = value
+		case "default_remote":
+			ctx.DefaultRemote = value
+		case "account":
+			ctx.Account = value
+		case "sync_backend":
+			// Written by older versions before named remotes existed
+			if _, ok := ctx.Remotes["origin"]; !ok && value != "" {
+				ctx.Remotes["origin"] = value
+			}
+		default:
+			if name := strings.TrimPrefix(key, RemoteKeyPrefix); name != key && name != "" {
+				ctx.Remotes[name] = value
+			}
 		}
 	}
 
@@ -62,9 +98,97 @@ func LoadProjectContext() (*ProjectContext, error) {
 		return nil, fmt.Errorf("invalid .envault file: missing project_id")
 	}
 
+	if ctx.DefaultRemote == "" && len(ctx.Remotes) == 1 {
+		for name := range ctx.Remotes {
+			ctx.DefaultRemote = name
+		}
+	}
+
 	return ctx, nil
 }
 
+// WriteProjectContext creates the .envault file of the current directory
+// for ctx
+func WriteProjectContext(ctx *ProjectContext) error {
+	entries := [][2]string{
+		{"project_id", ctx.ProjectID},
+		{"project_name", ctx.ProjectName},
+	}
+	for _, name := range ctx.RemoteNames() {
+		entries = append(entries, [2]string{RemoteKeyPrefix + name, ctx.Remotes[name]})
+	}
+	entries = append(entries, [2]string{"default_remote", ctx.DefaultRemote}, [2]string{"account", ctx.Account})
+
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry[1] == "" {
+			continue
+		}
+		if err := checkProjectEntry(entry[0], entry[1]); err != nil {
+			return err
+		}
+		b.WriteString(entry[0] + "=" + entry[1] + "\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(".", ".envault"), []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write .envault: %w", err)
+	}
+	return nil
+}
+
+// checkProjectEntry rejects keys and values that would not read back as
+// one KEY=value line of the .envault file
+func checkProjectEntry(key, value string) error {
+	if key == "" || strings.ContainsAny(key, "=#") || strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid .envault key %q", key)
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 || strings.TrimSpace(value) != value {
+		return fmt.Errorf("invalid .envault value for %s: %q", key, value)
+	}
+	return nil
+}
+
+// SetProjectValue sets a key in the .envault file of the current directory,
+// replacing an existing entry or appending a new one. An empty value removes
+// the key.
+func SetProjectValue(key, value string) error {
+	if err := checkProjectEntry(key, value); err != nil {
+		return err
+	}
+
+	envaultFile := filepath.Join(".", ".envault")
+
+	data, err := os.ReadFile(envaultFile)
+	if err != nil {
+		return fmt.Errorf("failed to read .envault: %w", err)
+	}
+
+	var lines []string
+	found := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			if found || value == "" {
+				continue
+			}
+			line = key + "=" + value
+			found = true
+		}
+		lines = append(lines, line)
+	}
+
+	if !found && value != "" {
+		lines = append(lines, key+"="+value)
+	}
+
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(envaultFile, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write .envault: %w", err)
+	}
+
+	return nil
+}
+
 // ValidateEnvKey validates an environment variable key
 func ValidateEnvKey(key string) error {
 	if key == "" {
@@ -132,27 +256,14 @@ func MaskSecret(value string) string {
 	return value[:4] + strings.Repeat("*", len(value)-4)
 }
 
-// FormatEnvVar formats an environment variable for export
-func FormatEnvVar(key, value string, format string) string {
-	switch format {
-	case "export":
-		return fmt.Sprintf("export %s=\"%s\"", key, value)
-	case "json":
-		return fmt.Sprintf(`"%s": "%s"`, key, escapeJSON(value))
-	case "yaml":
-		return fmt.Sprintf("%s: %s", key, value)
-	default:
-		return fmt.Sprintf("%s=%s", key, value)
+// FormatEnvVar formats an environment variable in one of the envfile
+// formats, quoting the value as the format requires. "plain" gives a dotenv
+// line.
+func FormatEnvVar(key, value string, format string) (string, error) {
+	if format == "" || format == "plain" {
+		format = "dotenv"
 	}
-}
-
-func escapeJSON(s string) string {
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	s = strings.ReplaceAll(s, "\n", "\\n")
-	s = strings.ReplaceAll(s, "\r", "\\r")
-	s = strings.ReplaceAll(s, "\t", "\\t")
-	return s
+	return envfile.FormatVar(format, key, value)
 }
 
 // ConfirmDangerousAction prompts user to confirm a dangerous action
@@ -198,3 +309,22 @@ func AddToGitignore(entry string) error {
 
 	return nil
 }
+
+// ParseTTL parses a token lifetime such as "90d", "12h" or "0" (no expiry)
+func ParseTTL(value string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	var days int
+	if _, err := fmt.Sscanf(value, "%dd", &days); err == nil && fmt.Sprintf("%dd", days) == value {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expiry %q (use e.g. 90d, 12h or 0 for no expiry)", value)
+	}
+
+	return ttl, nil
+}
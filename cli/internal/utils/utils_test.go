@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWriteProjectContext(t *testing.T) {
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	want := &ProjectContext{
+		ProjectID:     "7d1c4a2e-0000-4000-8000-000000000001",
+		ProjectName:   "My App = v2",
+		Remotes:       map[string]string{"origin": "https://envault.example.com", "backup": "file:///mnt/share"},
+		DefaultRemote: "origin",
+	}
+	if err := WriteProjectContext(want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadProjectContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []*ProjectContext{
+		{ProjectID: "id", ProjectName: "app\ndefault_remote=evil"},
+		{ProjectID: "id", Remotes: map[string]string{"origin": "https://a\r\nremote.evil=file:///"}},
+		{ProjectID: "id", Remotes: map[string]string{"a=b": "https://example.com"}},
+		{ProjectID: "id", ProjectName: " padded"},
+	} {
+		if err := WriteProjectContext(bad); err == nil {
+			t.Errorf("WriteProjectContext(%+v) succeeded, want an error", bad)
+		}
+	}
+
+	if err := SetProjectValue("project_name", "x\ndefault_remote=evil"); err == nil {
+		t.Error("SetProjectValue accepted a value with a line break")
+	}
+}
@@ -26,6 +26,8 @@ import Team from "./pages/Team";
 import ProjectDetail from "./pages/ProjectDetail";
 import Settings from "./pages/Settings";
 import Admin from "./pages/Admin";
+import CliAuthorize from "./pages/CliAuthorize";
+import CliDevice from "./pages/CliDevice";
 import Accessibility from "./pages/Accessibility";
 import NotFound from "./pages/NotFound";
 
@@ -61,6 +63,8 @@ const App = () => (
               <Route path="/dashboard/team" element={<ProtectedRoute><Team /></ProtectedRoute>} />
               <Route path="/dashboard/projects/:id" element={<ProtectedRoute><ProjectDetail /></ProtectedRoute>} />
               <Route path="/dashboard/settings" element={<ProtectedRoute><Settings /></ProtectedRoute>} />
+              <Route path="/cli/authorize" element={<ProtectedRoute><CliAuthorize /></ProtectedRoute>} />
+              <Route path="/cli/device" element={<ProtectedRoute><CliDevice /></ProtectedRoute>} />
               <Route path="/admin" element={<AdminRoute><Admin /></AdminRoute>} />
               {/* ADD ALL CUSTOM ROUTES ABOVE THE CATCH-ALL "*" ROUTE */}
               <Route path="*" element={<NotFound />} />
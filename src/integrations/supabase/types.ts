@@ -979,6 +979,14 @@ export type Database = {
       [_ in never]: never
     }
     Functions: {
+      accept_team_invitation: {
+        Args: { p_invitation_id: string }
+        Returns: Json
+      }
+      approve_device_authorization: {
+        Args: { p_approve?: boolean; p_user_code: string }
+        Returns: Json
+      }
       check_plan_limits: { Args: { user_uuid: string }; Returns: Json }
       check_rate_limit: {
         Args: {
@@ -993,19 +1001,51 @@ export type Database = {
         Args: { p_source_env_id: string; p_target_env_id: string }
         Returns: number
       }
+      create_cli_auth_code: {
+        Args: {
+          p_client_name?: string
+          p_code_challenge: string
+          p_redirect_uri: string
+        }
+        Returns: string
+      }
       create_environment: {
         Args: { p_name: string; p_project_id: string }
         Returns: string
       }
+      create_machine_token: {
+        Args: {
+          p_environments?: string[]
+          p_expires_in_seconds?: number
+          p_name: string
+          p_project_id: string
+        }
+        Returns: Json
+      }
+      create_team_invitation: {
+        Args: {
+          p_email: string
+          p_expires_in_seconds?: number
+          p_project_id: string
+          p_project_name?: string
+          p_role?: string
+        }
+        Returns: Json
+      }
       delete_environment: {
         Args: { p_environment_id: string }
         Returns: boolean
       }
       delete_secret: { Args: { p_secret_id: string }; Returns: boolean }
+      exchange_cli_auth_code: {
+        Args: { p_code: string; p_code_verifier: string }
+        Returns: Json
+      }
       generate_cli_token: {
         Args: { p_expires_in_days?: number; p_name: string }
         Returns: Json
       }
+      get_current_user: { Args: never; Returns: Json }
       get_environment_secrets: {
         Args: { p_environment_id: string }
         Returns: {
@@ -1018,6 +1058,7 @@ export type Database = {
           value: string
         }[]
       }
+      get_machine_token_scope: { Args: never; Returns: Json }
       get_plan_limits: {
         Args: { plan_type: Database["public"]["Enums"]["subscription_plan"] }
         Returns: Json
@@ -1045,6 +1086,19 @@ export type Database = {
         Args: { article_slug: string }
         Returns: undefined
       }
+      issue_cli_session: {
+        Args: { p_name: string; p_user_id: string }
+        Returns: Json
+      }
+      leave_project: { Args: { p_project_id: string }; Returns: boolean }
+      list_machine_tokens: { Args: { p_project_id: string }; Returns: Json }
+      list_my_invitations: { Args: never; Returns: Json }
+      list_team_invitations: { Args: { p_project_id: string }; Returns: Json }
+      list_team_members: { Args: { p_project_id: string }; Returns: Json }
+      poll_device_authorization: {
+        Args: { p_device_code: string }
+        Returns: Json
+      }
       pull_encrypted_blob: {
         Args: { p_project_id: string; p_since_version?: number }
         Returns: Json
@@ -1057,7 +1111,27 @@ export type Database = {
         }
         Returns: Json
       }
+      refresh_cli_session: {
+        Args: { p_refresh_token: string }
+        Returns: Json
+      }
       revoke_cli_token: { Args: { p_token_id: string }; Returns: boolean }
+      revoke_machine_token: {
+        Args: { p_project_id: string; p_token_id: string }
+        Returns: boolean
+      }
+      revoke_team_invitation: {
+        Args: { p_invitation_id: string; p_project_id: string }
+        Returns: boolean
+      }
+      start_device_authorization: {
+        Args: { p_client_name?: string }
+        Returns: Json
+      }
+      update_team_member_role: {
+        Args: { p_project_id: string; p_role: string; p_user_id: string }
+        Returns: boolean
+      }
       upsert_secret:
         | {
             Args: {
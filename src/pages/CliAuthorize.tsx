@@ -0,0 +1,105 @@
+import { useState } from 'react';
+import { useSearchParams } from 'react-router-dom';
+import { Navigation } from '@/components/Navigation';
+import { Button } from '@/components/ui/button';
+import { Card } from '@/components/ui/card';
+import { Terminal, ShieldCheck } from 'lucide-react';
+import { supabase } from '@/integrations/supabase/client';
+import { useAuth } from '@/contexts/AuthContext';
+import { toast } from '@/hooks/use-toast';
+
+// The CLI only listens on loopback addresses
+const isLoopbackRedirect = (uri: string) => /^http:\/\/(127\.0\.0\.1|localhost):\d+\//.test(uri);
+
+export default function CliAuthorize() {
+  const { user } = useAuth();
+  const [params] = useSearchParams();
+  const [loading, setLoading] = useState(false);
+  const [done, setDone] = useState(false);
+
+  const redirectUri = params.get('redirect_uri') ?? '';
+  const state = params.get('state') ?? '';
+  const codeChallenge = params.get('code_challenge') ?? '';
+  const clientName = params.get('client_name') || 'EnvVault CLI';
+
+  const valid = isLoopbackRedirect(redirectUri) && state !== '' && codeChallenge !== '' &&
+    params.get('code_challenge_method') === 'S256';
+
+  const redirect = (query: Record<string, string>) => {
+    const url = new URL(redirectUri);
+    Object.entries({ ...query, state }).forEach(([key, value]) => url.searchParams.set(key, value));
+    window.location.assign(url.toString());
+  };
+
+  const handleApprove = async () => {
+    setLoading(true);
+    try {
+      const { data, error } = await supabase.rpc('create_cli_auth_code', {
+        p_code_challenge: codeChallenge,
+        p_redirect_uri: redirectUri,
+        p_client_name: clientName,
+      });
+
+      if (error) throw error;
+      setDone(true);
+      redirect({ code: data as string });
+    } catch (error: any) {
+      toast({
+        title: 'Login failed',
+        description: error.message,
+        variant: 'destructive',
+      });
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleDeny = () => {
+    setDone(true);
+    redirect({ error: 'access_denied', error_description: 'The login was denied in the browser' });
+  };
+
+  return (
+    <div className="min-h-screen bg-background">
+      <Navigation />
+
+      <main className="container mx-auto px-4 pt-32 pb-20 max-w-lg">
+        <Card className="p-8 space-y-6">
+          <div className="flex items-center gap-3">
+            <Terminal className="h-6 w-6 text-primary" />
+            <h1 className="text-2xl font-bold">Authorize CLI login</h1>
+          </div>
+
+          {!valid ? (
+            <p className="text-muted-foreground">
+              This login link is invalid. Run <code>envault login</code> again from your terminal.
+            </p>
+          ) : done ? (
+            <p className="text-muted-foreground">
+              Returning to the CLI. You can close this tab once your terminal confirms the login.
+            </p>
+          ) : (
+            <>
+              <p className="text-muted-foreground">
+                <span className="font-medium text-foreground">{clientName}</span> is requesting
+                access to your EnvVault account as <span className="font-medium text-foreground">{user?.email}</span>.
+              </p>
+              <p className="text-sm text-muted-foreground flex items-start gap-2">
+                <ShieldCheck className="h-4 w-4 mt-0.5 shrink-0" />
+                Only approve if you just ran <code>envault login</code> on this computer.
+              </p>
+              <div className="flex gap-3">
+                <Button onClick={handleApprove} disabled={loading} className="flex-1">
+                  {loading ? 'Approving...' : 'Approve'}
+                </Button>
+                <Button variant="outline" onClick={handleDeny} disabled={loading} className="flex-1">
+                  Deny
+                </Button>
+              </div>
+            </>
+          )}
+        </Card>
+      </main>
+    </div>
+  );
+}
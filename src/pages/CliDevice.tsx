@@ -0,0 +1,97 @@
+import { useState } from 'react';
+import { useSearchParams } from 'react-router-dom';
+import { Navigation } from '@/components/Navigation';
+import { Button } from '@/components/ui/button';
+import { Card } from '@/components/ui/card';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import { Terminal, CheckCircle2 } from 'lucide-react';
+import { supabase } from '@/integrations/supabase/client';
+import { toast } from '@/hooks/use-toast';
+
+export default function CliDevice() {
+  const [params] = useSearchParams();
+  const [code, setCode] = useState(params.get('code') ?? '');
+  const [loading, setLoading] = useState(false);
+  const [result, setResult] = useState<{ clientName: string; approved: boolean } | null>(null);
+
+  const submit = async (approve: boolean) => {
+    setLoading(true);
+    try {
+      const { data, error } = await supabase.rpc('approve_device_authorization', {
+        p_user_code: code,
+        p_approve: approve,
+      });
+
+      if (error) throw error;
+      const response = data as { client_name: string; approved: boolean };
+      setResult({ clientName: response.client_name, approved: response.approved });
+    } catch (error: any) {
+      toast({
+        title: 'Could not verify code',
+        description: error.message,
+        variant: 'destructive',
+      });
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-background">
+      <Navigation />
+
+      <main className="container mx-auto px-4 pt-32 pb-20 max-w-lg">
+        <Card className="p-8 space-y-6">
+          <div className="flex items-center gap-3">
+            <Terminal className="h-6 w-6 text-primary" />
+            <h1 className="text-2xl font-bold">Connect a device</h1>
+          </div>
+
+          {result ? (
+            <p className="text-muted-foreground flex items-start gap-2">
+              <CheckCircle2 className="h-5 w-5 text-primary shrink-0" />
+              {result.approved
+                ? `${result.clientName} is now logged in. You can return to your terminal.`
+                : `The login from ${result.clientName} was denied.`}
+            </p>
+          ) : (
+            <form
+              onSubmit={(e) => {
+                e.preventDefault();
+                submit(true);
+              }}
+              className="space-y-6"
+            >
+              <div className="space-y-2">
+                <Label htmlFor="user-code">Code shown by envault login</Label>
+                <Input
+                  id="user-code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value.toUpperCase())}
+                  placeholder="XXXX-XXXX"
+                  autoComplete="off"
+                  className="font-mono text-lg tracking-widest"
+                />
+              </div>
+              <div className="flex gap-3">
+                <Button type="submit" disabled={loading || code.trim() === ''} className="flex-1">
+                  {loading ? 'Verifying...' : 'Approve'}
+                </Button>
+                <Button
+                  type="button"
+                  variant="outline"
+                  disabled={loading || code.trim() === ''}
+                  onClick={() => submit(false)}
+                  className="flex-1"
+                >
+                  Deny
+                </Button>
+              </div>
+            </form>
+          )}
+        </Card>
+      </main>
+    </div>
+  );
+}
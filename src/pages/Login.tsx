@@ -4,7 +4,7 @@ import { Card } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { Lock, Terminal } from "lucide-react";
-import { Link, useNavigate } from "react-router-dom";
+import { Link, useLocation, useNavigate } from "react-router-dom";
 import { TerminalWindow, TerminalLine } from "@/components/TerminalWindow";
 import { useAuth } from "@/contexts/AuthContext";
 import { useState, useEffect } from "react";
@@ -12,15 +12,18 @@ import { useState, useEffect } from "react";
 export default function Login() {
   const { signIn, user } = useAuth();
   const navigate = useNavigate();
+  const location = useLocation();
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [loading, setLoading] = useState(false);
 
   useEffect(() => {
     if (user) {
-      navigate('/dashboard');
+      // Return to the page that required login, e.g. a CLI login approval
+      const from = (location.state as { from?: { pathname: string; search: string } } | null)?.from;
+      navigate(from ? from.pathname + from.search : '/dashboard', { replace: !!from });
     }
-  }, [user, navigate]);
+  }, [user, navigate, location.state]);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
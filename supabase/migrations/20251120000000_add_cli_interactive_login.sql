@@ -0,0 +1,369 @@
+-- ============================================================================
+-- CLI INTERACTIVE LOGIN
+-- Browser login with PKCE, device-code login and refreshable CLI sessions.
+-- ============================================================================
+
+-- Refreshable sessions reuse cli_tokens: the access token is short-lived and
+-- rotated together with its refresh token
+ALTER TABLE public.cli_tokens
+  ADD COLUMN IF NOT EXISTS refresh_token_hash TEXT UNIQUE,
+  ADD COLUMN IF NOT EXISTS refresh_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- One-time codes handed to the CLI's localhost callback after a browser login
+CREATE TABLE IF NOT EXISTS public.cli_auth_codes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL UNIQUE,
+  code_challenge TEXT NOT NULL,
+  redirect_uri TEXT NOT NULL,
+  client_name TEXT NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Pending device-code logins
+CREATE TABLE IF NOT EXISTS public.cli_device_authorizations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  device_code_hash TEXT NOT NULL UNIQUE,
+  user_code TEXT NOT NULL UNIQUE,
+  client_name TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'denied', 'consumed')),
+  user_id UUID REFERENCES auth.users(id) ON DELETE CASCADE,
+  poll_interval INTEGER NOT NULL DEFAULT 5,
+  last_polled_at TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Only the SECURITY DEFINER functions below touch these tables
+ALTER TABLE public.cli_auth_codes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.cli_device_authorizations ENABLE ROW LEVEL SECURITY;
+
+CREATE INDEX IF NOT EXISTS idx_cli_auth_codes_expires_at ON public.cli_auth_codes(expires_at);
+CREATE INDEX IF NOT EXISTS idx_cli_device_authorizations_expires_at ON public.cli_device_authorizations(expires_at);
+
+-- Issue a refreshable CLI session for a user (internal helper, not exposed)
+CREATE OR REPLACE FUNCTION public.issue_cli_session(
+  p_user_id UUID,
+  p_name TEXT
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_access_token TEXT;
+  v_refresh_token TEXT;
+  v_expires_at TIMESTAMP WITH TIME ZONE;
+BEGIN
+  v_access_token := 'envt_' || encode(gen_random_bytes(32), 'hex');
+  v_refresh_token := 'envr_' || encode(gen_random_bytes(32), 'hex');
+  v_expires_at := now() + interval '1 hour';
+
+  INSERT INTO public.cli_tokens (user_id, token_hash, name, expires_at, refresh_token_hash, refresh_expires_at)
+  VALUES (
+    p_user_id,
+    encode(digest(v_access_token, 'sha256'), 'hex'),
+    p_name || ' (' || to_char(now(), 'YYYY-MM-DD HH24:MI') || ')',
+    v_expires_at,
+    encode(digest(v_refresh_token, 'sha256'), 'hex'),
+    now() + interval '30 days'
+  );
+
+  RETURN json_build_object(
+    'user_id', p_user_id,
+    'access_token', v_access_token,
+    'refresh_token', v_refresh_token,
+    'expires_at', v_expires_at
+  );
+END;
+$$;
+
+REVOKE ALL ON FUNCTION public.issue_cli_session FROM PUBLIC;
+
+-- Called by the dashboard once the user approves a browser login
+CREATE OR REPLACE FUNCTION public.create_cli_auth_code(
+  p_code_challenge TEXT,
+  p_redirect_uri TEXT,
+  p_client_name TEXT DEFAULT 'EnvVault CLI'
+)
+RETURNS TEXT
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_code TEXT;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  -- Codes may only be delivered to a loopback callback
+  IF p_redirect_uri !~ '^http://(127\.0\.0\.1|localhost):[0-9]+/' THEN
+    RAISE EXCEPTION 'Invalid redirect URI';
+  END IF;
+
+  IF length(p_code_challenge) < 43 THEN
+    RAISE EXCEPTION 'Invalid code challenge';
+  END IF;
+
+  v_code := encode(gen_random_bytes(32), 'hex');
+
+  INSERT INTO public.cli_auth_codes (user_id, code_hash, code_challenge, redirect_uri, client_name, expires_at)
+  VALUES (
+    auth.uid(),
+    encode(digest(v_code, 'sha256'), 'hex'),
+    p_code_challenge,
+    p_redirect_uri,
+    left(p_client_name, 100),
+    now() + interval '5 minutes'
+  );
+
+  RETURN v_code;
+END;
+$$;
+
+-- Called by the CLI with the code from the callback and its PKCE verifier
+CREATE OR REPLACE FUNCTION public.exchange_cli_auth_code(
+  p_code TEXT,
+  p_code_verifier TEXT
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_row public.cli_auth_codes%ROWTYPE;
+  v_challenge TEXT;
+BEGIN
+  UPDATE public.cli_auth_codes
+  SET used_at = now()
+  WHERE code_hash = encode(digest(p_code, 'sha256'), 'hex')
+    AND used_at IS NULL
+    AND expires_at > now()
+  RETURNING * INTO v_row;
+
+  IF v_row.id IS NULL THEN
+    RAISE EXCEPTION 'Invalid or expired token';
+  END IF;
+
+  -- S256: base64url(sha256(verifier)) without padding
+  v_challenge := rtrim(translate(encode(digest(p_code_verifier, 'sha256'), 'base64'), '+/', '-_'), '=');
+  IF v_challenge <> v_row.code_challenge THEN
+    RAISE EXCEPTION 'Invalid or expired token';
+  END IF;
+
+  RETURN issue_cli_session(v_row.user_id, v_row.client_name);
+END;
+$$;
+
+-- Rotate a session's access and refresh tokens
+CREATE OR REPLACE FUNCTION public.refresh_cli_session(
+  p_refresh_token TEXT
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_token_id UUID;
+  v_user_id UUID;
+  v_access_token TEXT;
+  v_refresh_token TEXT;
+  v_expires_at TIMESTAMP WITH TIME ZONE;
+BEGIN
+  SELECT id, user_id INTO v_token_id, v_user_id
+  FROM public.cli_tokens
+  WHERE refresh_token_hash = encode(digest(p_refresh_token, 'sha256'), 'hex')
+    AND refresh_expires_at > now()
+  FOR UPDATE;
+
+  IF v_token_id IS NULL THEN
+    RAISE EXCEPTION 'Invalid or expired token';
+  END IF;
+
+  v_access_token := 'envt_' || encode(gen_random_bytes(32), 'hex');
+  v_refresh_token := 'envr_' || encode(gen_random_bytes(32), 'hex');
+  v_expires_at := now() + interval '1 hour';
+
+  UPDATE public.cli_tokens
+  SET token_hash = encode(digest(v_access_token, 'sha256'), 'hex'),
+      refresh_token_hash = encode(digest(v_refresh_token, 'sha256'), 'hex'),
+      expires_at = v_expires_at,
+      refresh_expires_at = now() + interval '30 days',
+      last_used_at = now()
+  WHERE id = v_token_id;
+
+  RETURN json_build_object(
+    'user_id', v_user_id,
+    'access_token', v_access_token,
+    'refresh_token', v_refresh_token,
+    'expires_at', v_expires_at
+  );
+END;
+$$;
+
+-- Start a device-code login; the user approves it on /cli/device
+CREATE OR REPLACE FUNCTION public.start_device_authorization(
+  p_client_name TEXT DEFAULT 'EnvVault CLI'
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_device_code TEXT;
+  v_user_code TEXT;
+  v_alphabet TEXT := 'BCDFGHJKLMNPQRSTVWXZ';
+  v_bytes BYTEA;
+BEGIN
+  v_device_code := encode(gen_random_bytes(32), 'hex');
+
+  -- Eight consonants, shown as XXXX-XXXX, avoid ambiguous characters and words
+  LOOP
+    v_bytes := gen_random_bytes(8);
+    v_user_code := '';
+    FOR i IN 0..7 LOOP
+      v_user_code := v_user_code || substr(v_alphabet, (get_byte(v_bytes, i) % 20) + 1, 1);
+      IF i = 3 THEN
+        v_user_code := v_user_code || '-';
+      END IF;
+    END LOOP;
+
+    EXIT WHEN NOT EXISTS (SELECT 1 FROM public.cli_device_authorizations WHERE user_code = v_user_code);
+  END LOOP;
+
+  DELETE FROM public.cli_device_authorizations WHERE expires_at < now() - interval '1 day';
+
+  INSERT INTO public.cli_device_authorizations (device_code_hash, user_code, client_name, expires_at)
+  VALUES (
+    encode(digest(v_device_code, 'sha256'), 'hex'),
+    v_user_code,
+    left(p_client_name, 100),
+    now() + interval '15 minutes'
+  );
+
+  RETURN json_build_object(
+    'device_code', v_device_code,
+    'user_code', v_user_code,
+    'expires_in', 900,
+    'interval', 5
+  );
+END;
+$$;
+
+-- Called by the dashboard when the signed-in user approves or denies a code
+CREATE OR REPLACE FUNCTION public.approve_device_authorization(
+  p_user_code TEXT,
+  p_approve BOOLEAN DEFAULT true
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_client_name TEXT;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  UPDATE public.cli_device_authorizations
+  SET status = CASE WHEN p_approve THEN 'approved' ELSE 'denied' END,
+      user_id = auth.uid()
+  WHERE user_code = upper(trim(p_user_code))
+    AND status = 'pending'
+    AND expires_at > now()
+  RETURNING client_name INTO v_client_name;
+
+  IF v_client_name IS NULL THEN
+    RAISE EXCEPTION 'Code not found or expired';
+  END IF;
+
+  RETURN json_build_object('client_name', v_client_name, 'approved', p_approve);
+END;
+$$;
+
+-- Polled by the CLI until the code is approved, denied or expires
+CREATE OR REPLACE FUNCTION public.poll_device_authorization(
+  p_device_code TEXT
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_row public.cli_device_authorizations%ROWTYPE;
+  v_session JSON;
+BEGIN
+  SELECT * INTO v_row
+  FROM public.cli_device_authorizations
+  WHERE device_code_hash = encode(digest(p_device_code, 'sha256'), 'hex')
+  FOR UPDATE;
+
+  IF v_row.id IS NULL OR v_row.expires_at < now() OR v_row.status = 'consumed' THEN
+    RETURN json_build_object('status', 'expired');
+  END IF;
+
+  IF v_row.status = 'denied' THEN
+    RETURN json_build_object('status', 'denied');
+  END IF;
+
+  IF v_row.last_polled_at IS NOT NULL
+     AND v_row.last_polled_at > now() - make_interval(secs => v_row.poll_interval) THEN
+    UPDATE public.cli_device_authorizations SET last_polled_at = now() WHERE id = v_row.id;
+    RETURN json_build_object('status', 'slow_down');
+  END IF;
+
+  IF v_row.status = 'pending' THEN
+    UPDATE public.cli_device_authorizations SET last_polled_at = now() WHERE id = v_row.id;
+    RETURN json_build_object('status', 'pending');
+  END IF;
+
+  -- Approved: hand out the session exactly once
+  UPDATE public.cli_device_authorizations SET status = 'consumed' WHERE id = v_row.id;
+  v_session := issue_cli_session(v_row.user_id, v_row.client_name);
+
+  RETURN (v_session::jsonb || jsonb_build_object('status', 'approved'))::json;
+END;
+$$;
+
+-- Profile of the calling user
+CREATE OR REPLACE FUNCTION public.get_current_user()
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_result JSON;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  SELECT json_build_object('id', u.id, 'email', u.email, 'name', p.full_name)
+  INTO v_result
+  FROM auth.users u
+  LEFT JOIN public.profiles p ON p.id = u.id
+  WHERE u.id = auth.uid();
+
+  RETURN v_result;
+END;
+$$;
+
+GRANT EXECUTE ON FUNCTION public.create_cli_auth_code TO authenticated;
+GRANT EXECUTE ON FUNCTION public.approve_device_authorization TO authenticated;
+GRANT EXECUTE ON FUNCTION public.get_current_user TO authenticated;
+GRANT EXECUTE ON FUNCTION public.exchange_cli_auth_code TO anon, authenticated;
+GRANT EXECUTE ON FUNCTION public.refresh_cli_session TO anon, authenticated;
+GRANT EXECUTE ON FUNCTION public.start_device_authorization TO anon, authenticated;
+GRANT EXECUTE ON FUNCTION public.poll_device_authorization TO anon, authenticated;
@@ -0,0 +1,306 @@
+-- ============================================================================
+-- MACHINE TOKENS
+-- Read-only tokens for CI, scoped to one project and optionally some of its
+-- environments. They are sent as bearer tokens with the envm_ prefix.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS public.machine_tokens (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  project_id UUID NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  environments TEXT[] NOT NULL DEFAULT '{}',
+  created_by UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  last_used_at TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  UNIQUE(project_id, name)
+);
+
+-- Only the SECURITY DEFINER functions below touch this table
+ALTER TABLE public.machine_tokens ENABLE ROW LEVEL SECURITY;
+
+CREATE INDEX IF NOT EXISTS idx_machine_tokens_project ON public.machine_tokens(project_id);
+
+-- Whether a user administers a project. Only the functions below call it;
+-- clients must not be able to ask about other users.
+CREATE OR REPLACE FUNCTION public.is_project_admin(p_project_id UUID, p_user_id UUID)
+RETURNS BOOLEAN
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  SELECT EXISTS (
+    SELECT 1 FROM public.projects p
+    WHERE p.id = p_project_id
+      AND (
+        p.owner_id = p_user_id OR
+        EXISTS (
+          SELECT 1 FROM public.team_members tm
+          WHERE tm.project_id = p.id AND tm.user_id = p_user_id AND tm.role = 'admin'
+        )
+      )
+  );
+$$;
+
+-- Supabase also grants new functions to anon and authenticated directly
+REVOKE ALL ON FUNCTION public.is_project_admin(UUID, UUID) FROM PUBLIC, anon, authenticated;
+
+-- The machine token of the current request, if it carries a valid one.
+-- Tokens stop working once they expire or their creator leaves the project.
+CREATE OR REPLACE FUNCTION public.current_machine_token()
+RETURNS public.machine_tokens
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_header TEXT;
+  v_token public.machine_tokens;
+BEGIN
+  v_header := current_setting('request.headers', true)::json->>'authorization';
+  IF v_header IS NULL OR v_header NOT LIKE 'Bearer envm\_%' THEN
+    RETURN NULL;
+  END IF;
+
+  SELECT mt.* INTO v_token
+  FROM public.machine_tokens mt
+  JOIN public.projects p ON p.id = mt.project_id
+  WHERE mt.token_hash = encode(digest(substring(v_header FROM 8), 'sha256'), 'hex')
+    AND mt.expires_at > now()
+    AND (
+      p.owner_id = mt.created_by OR
+      EXISTS (
+        SELECT 1 FROM public.team_members tm
+        WHERE tm.project_id = p.id AND tm.user_id = mt.created_by
+      )
+    );
+
+  IF v_token.id IS NOT NULL THEN
+    UPDATE public.machine_tokens SET last_used_at = now() WHERE id = v_token.id;
+  END IF;
+
+  RETURN v_token;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION public.current_machine_token() FROM PUBLIC, anon, authenticated;
+
+CREATE OR REPLACE FUNCTION public.create_machine_token(
+  p_project_id UUID,
+  p_name TEXT,
+  p_environments TEXT[] DEFAULT '{}',
+  p_expires_in_seconds INTEGER DEFAULT 7776000
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_token TEXT;
+  v_row public.machine_tokens;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  IF coalesce(trim(p_name), '') = '' THEN
+    RAISE EXCEPTION 'p_name is required';
+  END IF;
+
+  IF p_expires_in_seconds IS NULL OR p_expires_in_seconds <= 0 OR p_expires_in_seconds > 31536000 THEN
+    RAISE EXCEPTION 'Machine tokens must expire within 365 days';
+  END IF;
+
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  v_token := 'envm_' || encode(gen_random_bytes(32), 'hex');
+
+  INSERT INTO public.machine_tokens (project_id, token_hash, name, environments, created_by, expires_at)
+  VALUES (
+    p_project_id,
+    encode(digest(v_token, 'sha256'), 'hex'),
+    trim(p_name),
+    coalesce(p_environments, '{}'),
+    auth.uid(),
+    now() + make_interval(secs => p_expires_in_seconds)
+  )
+  RETURNING * INTO v_row;
+
+  INSERT INTO public.audit_logs (project_id, user_id, action, resource_type, resource_id, metadata)
+  VALUES (p_project_id, auth.uid(), 'machine_token_created', 'machine_token', v_row.id,
+          jsonb_build_object('name', v_row.name, 'environments', v_row.environments, 'expires_at', v_row.expires_at));
+
+  RETURN json_build_object(
+    'id', v_row.id,
+    'token', v_token,
+    'name', v_row.name,
+    'project_id', v_row.project_id,
+    'environments', v_row.environments,
+    'created_by', v_row.created_by,
+    'expires_at', v_row.expires_at,
+    'created_at', v_row.created_at
+  );
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.list_machine_tokens(p_project_id UUID)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+BEGIN
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  RETURN coalesce((
+    SELECT json_agg(json_build_object(
+      'id', mt.id,
+      'name', mt.name,
+      'project_id', mt.project_id,
+      'environments', mt.environments,
+      'created_by', mt.created_by,
+      'last_used_at', mt.last_used_at,
+      'expires_at', mt.expires_at,
+      'created_at', mt.created_at
+    ) ORDER BY mt.created_at)
+    FROM public.machine_tokens mt
+    WHERE mt.project_id = p_project_id
+  ), '[]'::json);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.revoke_machine_token(p_project_id UUID, p_token_id UUID)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_deleted INTEGER;
+BEGIN
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  DELETE FROM public.machine_tokens WHERE project_id = p_project_id AND id = p_token_id;
+  GET DIAGNOSTICS v_deleted = ROW_COUNT;
+
+  IF v_deleted > 0 THEN
+    INSERT INTO public.audit_logs (project_id, user_id, action, resource_type, resource_id)
+    VALUES (p_project_id, auth.uid(), 'machine_token_revoked', 'machine_token', p_token_id);
+  END IF;
+
+  RETURN v_deleted > 0;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_machine_token_scope()
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_token public.machine_tokens;
+BEGIN
+  v_token := public.current_machine_token();
+  IF v_token.id IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated with a machine token';
+  END IF;
+
+  RETURN json_build_object(
+    'id', v_token.id,
+    'name', v_token.name,
+    'project_id', v_token.project_id,
+    'environments', v_token.environments,
+    'created_by', v_token.created_by,
+    'expires_at', v_token.expires_at,
+    'created_at', v_token.created_at
+  );
+END;
+$$;
+
+-- pull_encrypted_blob also accepts a machine token for its project
+CREATE OR REPLACE FUNCTION public.pull_encrypted_blob(
+  p_project_id UUID,
+  p_since_version INTEGER DEFAULT NULL
+) RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+AS $$
+DECLARE
+  v_blob RECORD;
+  v_machine public.machine_tokens;
+BEGIN
+  -- Rate limit: 20 requests per minute
+  IF NOT check_rate_limit('pull_encrypted_blob', 20, 60) THEN
+    RAISE EXCEPTION 'Rate limit exceeded. Please try again in a few moments.';
+  END IF;
+
+  v_machine := public.current_machine_token();
+
+  -- Verify project access
+  IF v_machine.id IS NOT NULL THEN
+    IF v_machine.project_id <> p_project_id THEN
+      RAISE EXCEPTION 'Access denied or project not found';
+    END IF;
+  ELSIF NOT EXISTS (
+    SELECT 1 FROM public.projects p
+    WHERE p.id = p_project_id
+      AND (
+        p.owner_id = auth.uid() OR
+        EXISTS (
+          SELECT 1 FROM public.team_members tm
+          WHERE tm.project_id = p.id AND tm.user_id = auth.uid()
+        )
+      )
+  ) THEN
+    RAISE EXCEPTION 'Access denied or project not found';
+  END IF;
+
+  -- Get latest blob
+  SELECT * INTO v_blob
+  FROM public.encrypted_blobs
+  WHERE project_id = p_project_id
+    AND (p_since_version IS NULL OR version > p_since_version)
+  ORDER BY version DESC
+  LIMIT 1;
+
+  IF v_blob.id IS NULL THEN
+    RETURN json_build_object(
+      'version', NULL,
+      'encrypted_data', NULL,
+      'checksum', NULL,
+      'has_updates', FALSE
+    );
+  END IF;
+
+  IF v_machine.id IS NOT NULL THEN
+    INSERT INTO public.audit_logs (project_id, user_id, action, resource_type, resource_id, metadata)
+    VALUES (p_project_id, NULL, 'machine_token_pulled', 'machine_token', v_machine.id,
+            jsonb_build_object('name', v_machine.name, 'version', v_blob.version));
+  END IF;
+
+  RETURN json_build_object(
+    'version', v_blob.version,
+    'encrypted_data', v_blob.encrypted_data,
+    'checksum', v_blob.checksum,
+    'has_updates', TRUE,
+    'uploaded_at', v_blob.created_at,
+    'uploaded_by', v_blob.uploaded_by
+  );
+END;
+$$;
+
+GRANT EXECUTE ON FUNCTION public.create_machine_token TO authenticated;
+GRANT EXECUTE ON FUNCTION public.list_machine_tokens TO authenticated;
+GRANT EXECUTE ON FUNCTION public.revoke_machine_token TO authenticated;
+GRANT EXECUTE ON FUNCTION public.get_machine_token_scope TO anon, authenticated;
+GRANT EXECUTE ON FUNCTION public.pull_encrypted_blob TO anon, authenticated;
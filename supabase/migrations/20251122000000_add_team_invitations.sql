@@ -0,0 +1,345 @@
+-- ============================================================================
+-- TEAM INVITATIONS AND MEMBERSHIP LIFECYCLE
+-- Invitations are addressed to an email and accepted by the invitee, so they
+-- can be sent before the invitee has an account. Members can change roles
+-- and leave projects.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS public.team_invitations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  project_id UUID NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  role app_role NOT NULL DEFAULT 'viewer',
+  invited_by UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_invitations_project_email
+  ON public.team_invitations(project_id, lower(email));
+CREATE INDEX IF NOT EXISTS idx_team_invitations_email ON public.team_invitations(lower(email));
+
+-- Only the SECURITY DEFINER functions below touch this table
+ALTER TABLE public.team_invitations ENABLE ROW LEVEL SECURITY;
+
+-- The CLI calls the middle role "developer"; it is stored as "member"
+CREATE OR REPLACE FUNCTION public.parse_app_role(p_role TEXT)
+RETURNS app_role
+LANGUAGE plpgsql
+IMMUTABLE
+AS $$
+BEGIN
+  CASE lower(coalesce(p_role, 'viewer'))
+    WHEN 'viewer' THEN RETURN 'viewer';
+    WHEN 'developer' THEN RETURN 'member';
+    WHEN 'member' THEN RETURN 'member';
+    WHEN 'admin' THEN RETURN 'admin';
+    ELSE RAISE EXCEPTION 'Invalid role: % (expected viewer, developer or admin)', p_role;
+  END CASE;
+END;
+$$;
+
+-- The JSON form of an invitation returned to the CLI
+CREATE OR REPLACE FUNCTION public.invitation_json(p_invitation public.team_invitations)
+RETURNS JSON
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  SELECT json_build_object(
+    'id', p_invitation.id,
+    'project_id', p_invitation.project_id,
+    'project_name', (SELECT name FROM public.projects WHERE id = p_invitation.project_id),
+    'email', p_invitation.email,
+    'role', p_invitation.role,
+    'invited_by', p_invitation.invited_by,
+    'invited_by_email', (SELECT email FROM auth.users WHERE id = p_invitation.invited_by),
+    'expires_at', p_invitation.expires_at,
+    'created_at', p_invitation.created_at
+  );
+$$;
+
+REVOKE ALL ON FUNCTION public.invitation_json FROM PUBLIC;
+
+CREATE OR REPLACE FUNCTION public.list_team_members(p_project_id UUID)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+BEGIN
+  IF NOT public.has_project_access(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied or project not found';
+  END IF;
+
+  RETURN coalesce((
+    SELECT json_agg(json_build_object(
+      'id', tm.id,
+      'email', u.email,
+      'role', tm.role,
+      'created_at', tm.created_at,
+      'user_id', tm.user_id
+    ) ORDER BY tm.created_at)
+    FROM public.team_members tm
+    JOIN auth.users u ON u.id = tm.user_id
+    WHERE tm.project_id = p_project_id
+  ), '[]'::json);
+END;
+$$;
+
+-- p_project_name is only used by envault-server, which does not store
+-- project names; here the project's own name is returned instead
+CREATE OR REPLACE FUNCTION public.create_team_invitation(
+  p_project_id UUID,
+  p_email TEXT,
+  p_role TEXT DEFAULT 'viewer',
+  p_project_name TEXT DEFAULT NULL,
+  p_expires_in_seconds INTEGER DEFAULT 604800
+)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_role app_role;
+  v_row public.team_invitations;
+BEGIN
+  IF coalesce(p_email, '') NOT LIKE '%@%' THEN
+    RAISE EXCEPTION 'Invalid email address: %', p_email;
+  END IF;
+
+  v_role := public.parse_app_role(p_role);
+
+  IF p_expires_in_seconds IS NULL OR p_expires_in_seconds = 0 THEN
+    p_expires_in_seconds := 604800;
+  END IF;
+  IF p_expires_in_seconds < 0 OR p_expires_in_seconds > 2592000 THEN
+    RAISE EXCEPTION 'Invitations must expire within 30 days';
+  END IF;
+
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  IF EXISTS (
+    SELECT 1 FROM public.team_members tm
+    JOIN auth.users u ON u.id = tm.user_id
+    WHERE tm.project_id = p_project_id AND lower(u.email) = lower(trim(p_email))
+  ) THEN
+    RAISE EXCEPTION '% is already a member of this project', p_email USING ERRCODE = '23505';
+  END IF;
+
+  -- Inviting the same email again replaces the earlier invitation
+  DELETE FROM public.team_invitations
+  WHERE project_id = p_project_id AND lower(email) = lower(trim(p_email));
+
+  INSERT INTO public.team_invitations (project_id, email, role, invited_by, expires_at)
+  VALUES (p_project_id, trim(p_email), v_role, auth.uid(), now() + make_interval(secs => p_expires_in_seconds))
+  RETURNING * INTO v_row;
+
+  PERFORM log_audit_event(
+    p_project_id,
+    'invitation_created',
+    'team_invitation',
+    v_row.id,
+    jsonb_build_object('email', v_row.email, 'role', v_row.role, 'expires_at', v_row.expires_at)
+  );
+
+  RETURN public.invitation_json(v_row);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.list_team_invitations(p_project_id UUID)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+BEGIN
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  RETURN coalesce((
+    SELECT json_agg(public.invitation_json(ti) ORDER BY ti.created_at)
+    FROM public.team_invitations ti
+    WHERE ti.project_id = p_project_id
+  ), '[]'::json);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.revoke_team_invitation(p_project_id UUID, p_invitation_id UUID)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_deleted INTEGER;
+BEGIN
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  DELETE FROM public.team_invitations WHERE project_id = p_project_id AND id = p_invitation_id;
+  GET DIAGNOSTICS v_deleted = ROW_COUNT;
+
+  IF v_deleted > 0 THEN
+    PERFORM log_audit_event(p_project_id, 'invitation_revoked', 'team_invitation', p_invitation_id, NULL);
+  END IF;
+
+  RETURN v_deleted > 0;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.list_my_invitations()
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  RETURN coalesce((
+    SELECT json_agg(public.invitation_json(ti) ORDER BY ti.created_at)
+    FROM public.team_invitations ti
+    WHERE lower(ti.email) = (SELECT lower(email) FROM auth.users WHERE id = auth.uid())
+      AND ti.expires_at > now()
+  ), '[]'::json);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.accept_team_invitation(p_invitation_id UUID)
+RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_invitation public.team_invitations;
+  v_member_id UUID;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'Not authenticated';
+  END IF;
+
+  -- Other people's invitations are reported as missing
+  SELECT ti.* INTO v_invitation
+  FROM public.team_invitations ti
+  WHERE ti.id = p_invitation_id
+    AND lower(ti.email) = (SELECT lower(email) FROM auth.users WHERE id = auth.uid());
+
+  IF v_invitation.id IS NULL THEN
+    RAISE EXCEPTION 'Invitation not found';
+  END IF;
+
+  IF v_invitation.expires_at <= now() THEN
+    RAISE EXCEPTION 'Invitation has expired; ask a project admin to invite you again';
+  END IF;
+
+  INSERT INTO public.team_members (project_id, user_id, role, invited_by)
+  VALUES (v_invitation.project_id, auth.uid(), v_invitation.role, v_invitation.invited_by)
+  ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+  RETURNING id INTO v_member_id;
+
+  DELETE FROM public.team_invitations WHERE id = v_invitation.id;
+
+  PERFORM log_audit_event(
+    v_invitation.project_id,
+    'invitation_accepted',
+    'team_member',
+    v_member_id,
+    jsonb_build_object('invitation_id', v_invitation.id, 'role', v_invitation.role)
+  );
+
+  RETURN json_build_object(
+    'member_id', v_member_id,
+    'project_id', v_invitation.project_id,
+    'project_name', (SELECT name FROM public.projects WHERE id = v_invitation.project_id),
+    'role', v_invitation.role
+  );
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.update_team_member_role(
+  p_project_id UUID,
+  p_user_id UUID,
+  p_role TEXT
+)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_role app_role;
+  v_updated INTEGER;
+BEGIN
+  v_role := public.parse_app_role(p_role);
+
+  IF NOT public.is_project_admin(p_project_id, auth.uid()) THEN
+    RAISE EXCEPTION 'Access denied: requires admin role' USING ERRCODE = '42501';
+  END IF;
+
+  UPDATE public.team_members SET role = v_role
+  WHERE project_id = p_project_id AND user_id = p_user_id;
+  GET DIAGNOSTICS v_updated = ROW_COUNT;
+
+  IF v_updated > 0 THEN
+    PERFORM log_audit_event(
+      p_project_id,
+      'member_role_changed',
+      'team_member',
+      NULL,
+      jsonb_build_object('user_id', p_user_id, 'role', v_role)
+    );
+  END IF;
+
+  RETURN v_updated > 0;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.leave_project(p_project_id UUID)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_deleted INTEGER;
+BEGIN
+  IF EXISTS (SELECT 1 FROM public.projects WHERE id = p_project_id AND owner_id = auth.uid()) THEN
+    RAISE EXCEPTION 'The project owner cannot leave the project';
+  END IF;
+
+  DELETE FROM public.team_members
+  WHERE project_id = p_project_id AND user_id = auth.uid();
+  GET DIAGNOSTICS v_deleted = ROW_COUNT;
+
+  IF v_deleted > 0 THEN
+    PERFORM log_audit_event(
+      p_project_id,
+      'member_left',
+      'team_member',
+      NULL,
+      jsonb_build_object('user_id', auth.uid())
+    );
+  END IF;
+
+  RETURN v_deleted > 0;
+END;
+$$;
+
+GRANT EXECUTE ON FUNCTION public.list_team_members TO authenticated;
+GRANT EXECUTE ON FUNCTION public.create_team_invitation TO authenticated;
+GRANT EXECUTE ON FUNCTION public.list_team_invitations TO authenticated;
+GRANT EXECUTE ON FUNCTION public.revoke_team_invitation TO authenticated;
+GRANT EXECUTE ON FUNCTION public.list_my_invitations TO authenticated;
+GRANT EXECUTE ON FUNCTION public.accept_team_invitation TO authenticated;
+GRANT EXECUTE ON FUNCTION public.update_team_member_role TO authenticated;
+GRANT EXECUTE ON FUNCTION public.leave_project TO authenticated;
@@ -0,0 +1,77 @@
+-- ============================================================================
+-- PUSH METADATA
+-- The CLI sends the project's name and environment names with each push so
+-- that envault-server can list them before a project is cloned. Here the
+-- projects already have names, so only environments the dashboard doesn't
+-- know yet are added. Environments are never removed by a push, since the
+-- dashboard keeps its own secrets in them.
+-- ============================================================================
+
+-- The new parameters would otherwise make calls with three arguments
+-- ambiguous
+DROP FUNCTION IF EXISTS public.push_encrypted_blob(UUID, TEXT, TEXT);
+
+CREATE OR REPLACE FUNCTION public.push_encrypted_blob(
+  p_project_id UUID,
+  p_encrypted_data TEXT,
+  p_checksum TEXT,
+  p_project_name TEXT DEFAULT NULL,
+  p_environments TEXT[] DEFAULT NULL
+) RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_version INTEGER;
+  v_blob_id UUID;
+BEGIN
+  -- Rate limit: 10 requests per minute (sync is expensive)
+  IF NOT check_rate_limit('push_encrypted_blob', 10, 60) THEN
+    RAISE EXCEPTION 'Rate limit exceeded. Please try again in a few moments.';
+  END IF;
+
+  -- Verify project access
+  IF NOT EXISTS (
+    SELECT 1 FROM public.projects p
+    WHERE p.id = p_project_id
+      AND (
+        p.owner_id = auth.uid() OR
+        EXISTS (
+          SELECT 1 FROM public.team_members tm
+          WHERE tm.project_id = p.id
+            AND tm.user_id = auth.uid()
+            AND tm.role IN ('admin', 'developer')
+        )
+      )
+  ) THEN
+    RAISE EXCEPTION 'Access denied or project not found';
+  END IF;
+
+  -- Get next version
+  SELECT COALESCE(MAX(version), 0) + 1 INTO v_version
+  FROM public.encrypted_blobs
+  WHERE project_id = p_project_id;
+
+  -- Insert blob
+  INSERT INTO public.encrypted_blobs (project_id, version, encrypted_data, checksum, uploaded_by)
+  VALUES (p_project_id, v_version, p_encrypted_data, p_checksum, auth.uid())
+  RETURNING id INTO v_blob_id;
+
+  IF p_environments IS NOT NULL THEN
+    INSERT INTO public.environments (project_id, name)
+    SELECT p_project_id, env_name
+    FROM unnest(p_environments) AS env_name
+    WHERE coalesce(trim(env_name), '') <> ''
+    ON CONFLICT (project_id, name) DO NOTHING;
+  END IF;
+
+  RETURN json_build_object(
+    'blob_id', v_blob_id,
+    'version', v_version,
+    'checksum', p_checksum
+  );
+END;
+$$;
+
+GRANT EXECUTE ON FUNCTION public.push_encrypted_blob TO authenticated;
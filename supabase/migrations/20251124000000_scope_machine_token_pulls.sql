@@ -0,0 +1,201 @@
+-- ============================================================================
+-- ENVIRONMENT-SCOPED MACHINE TOKEN PULLS
+-- The CLI also pushes each environment encrypted on its own. Machine tokens
+-- limited to some environments are given only those, never the whole blob.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS public.encrypted_environment_blobs (
+  blob_id UUID NOT NULL REFERENCES public.encrypted_blobs(id) ON DELETE CASCADE,
+  environment TEXT NOT NULL,
+  encrypted_data TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  PRIMARY KEY (blob_id, environment)
+);
+
+-- Only the SECURITY DEFINER functions below touch this table
+ALTER TABLE public.encrypted_environment_blobs ENABLE ROW LEVEL SECURITY;
+
+-- The new parameter would otherwise make calls without it ambiguous
+DROP FUNCTION IF EXISTS public.push_encrypted_blob(UUID, TEXT, TEXT, TEXT, TEXT[]);
+
+CREATE OR REPLACE FUNCTION public.push_encrypted_blob(
+  p_project_id UUID,
+  p_encrypted_data TEXT,
+  p_checksum TEXT,
+  p_project_name TEXT DEFAULT NULL,
+  p_environments TEXT[] DEFAULT NULL,
+  p_environment_blobs JSONB DEFAULT NULL
+) RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_version INTEGER;
+  v_blob_id UUID;
+BEGIN
+  -- Rate limit: 10 requests per minute (sync is expensive)
+  IF NOT check_rate_limit('push_encrypted_blob', 10, 60) THEN
+    RAISE EXCEPTION 'Rate limit exceeded. Please try again in a few moments.';
+  END IF;
+
+  -- Verify project access
+  IF NOT EXISTS (
+    SELECT 1 FROM public.projects p
+    WHERE p.id = p_project_id
+      AND (
+        p.owner_id = auth.uid() OR
+        EXISTS (
+          SELECT 1 FROM public.team_members tm
+          WHERE tm.project_id = p.id
+            AND tm.user_id = auth.uid()
+            AND tm.role IN ('admin', 'developer')
+        )
+      )
+  ) THEN
+    RAISE EXCEPTION 'Access denied or project not found';
+  END IF;
+
+  IF p_environment_blobs IS NOT NULL AND EXISTS (
+    SELECT 1 FROM jsonb_array_elements(p_environment_blobs) AS e
+    WHERE coalesce(e->>'environment', '') = ''
+       OR coalesce(e->>'encrypted_data', '') = ''
+       OR coalesce(e->>'checksum', '') = ''
+  ) THEN
+    RAISE EXCEPTION 'every p_environment_blobs entry needs environment, encrypted_data and checksum';
+  END IF;
+
+  -- Get next version
+  SELECT COALESCE(MAX(version), 0) + 1 INTO v_version
+  FROM public.encrypted_blobs
+  WHERE project_id = p_project_id;
+
+  -- Insert blob
+  INSERT INTO public.encrypted_blobs (project_id, version, encrypted_data, checksum, uploaded_by)
+  VALUES (p_project_id, v_version, p_encrypted_data, p_checksum, auth.uid())
+  RETURNING id INTO v_blob_id;
+
+  IF p_environment_blobs IS NOT NULL THEN
+    INSERT INTO public.encrypted_environment_blobs (blob_id, environment, encrypted_data, checksum)
+    SELECT v_blob_id, e->>'environment', e->>'encrypted_data', e->>'checksum'
+    FROM jsonb_array_elements(p_environment_blobs) AS e;
+  END IF;
+
+  IF p_environments IS NOT NULL THEN
+    INSERT INTO public.environments (project_id, name)
+    SELECT p_project_id, env_name
+    FROM unnest(p_environments) AS env_name
+    WHERE coalesce(trim(env_name), '') <> ''
+    ON CONFLICT (project_id, name) DO NOTHING;
+  END IF;
+
+  RETURN json_build_object(
+    'blob_id', v_blob_id,
+    'version', v_version,
+    'checksum', p_checksum
+  );
+END;
+$$;
+
+-- pull_encrypted_blob gives machine tokens limited to some environments
+-- only those environments
+CREATE OR REPLACE FUNCTION public.pull_encrypted_blob(
+  p_project_id UUID,
+  p_since_version INTEGER DEFAULT NULL
+) RETURNS JSON
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_blob RECORD;
+  v_machine public.machine_tokens;
+  v_environments JSON;
+BEGIN
+  -- Rate limit: 20 requests per minute
+  IF NOT check_rate_limit('pull_encrypted_blob', 20, 60) THEN
+    RAISE EXCEPTION 'Rate limit exceeded. Please try again in a few moments.';
+  END IF;
+
+  v_machine := public.current_machine_token();
+
+  -- Verify project access
+  IF v_machine.id IS NOT NULL THEN
+    IF v_machine.project_id <> p_project_id THEN
+      RAISE EXCEPTION 'Access denied or project not found';
+    END IF;
+  ELSIF NOT EXISTS (
+    SELECT 1 FROM public.projects p
+    WHERE p.id = p_project_id
+      AND (
+        p.owner_id = auth.uid() OR
+        EXISTS (
+          SELECT 1 FROM public.team_members tm
+          WHERE tm.project_id = p.id AND tm.user_id = auth.uid()
+        )
+      )
+  ) THEN
+    RAISE EXCEPTION 'Access denied or project not found';
+  END IF;
+
+  -- Get latest blob
+  SELECT * INTO v_blob
+  FROM public.encrypted_blobs
+  WHERE project_id = p_project_id
+    AND (p_since_version IS NULL OR version > p_since_version)
+  ORDER BY version DESC
+  LIMIT 1;
+
+  IF v_blob.id IS NULL THEN
+    RETURN json_build_object('has_update', FALSE);
+  END IF;
+
+  IF v_machine.id IS NOT NULL AND cardinality(v_machine.environments) > 0 THEN
+    IF NOT EXISTS (SELECT 1 FROM public.encrypted_environment_blobs WHERE blob_id = v_blob.id) THEN
+      RAISE EXCEPTION 'Version % was pushed by an older EnvVault CLI that does not support tokens limited to some environments; push the project again', v_blob.version;
+    END IF;
+
+    SELECT coalesce(json_agg(json_build_object(
+      'environment', eb.environment,
+      'encrypted_data', eb.encrypted_data,
+      'checksum', eb.checksum
+    ) ORDER BY eb.environment), '[]'::json)
+    INTO v_environments
+    FROM public.encrypted_environment_blobs eb
+    WHERE eb.blob_id = v_blob.id
+      AND eb.environment = ANY(v_machine.environments);
+
+    INSERT INTO public.audit_logs (project_id, user_id, action, resource_type, resource_id, metadata)
+    VALUES (p_project_id, NULL, 'machine_token_pulled', 'machine_token', v_machine.id,
+            jsonb_build_object('name', v_machine.name, 'version', v_blob.version,
+                               'environments', v_machine.environments));
+
+    RETURN json_build_object(
+      'has_update', TRUE,
+      'id', v_blob.id,
+      'version', v_blob.version,
+      'environments', v_environments,
+      'uploaded_at', v_blob.uploaded_at
+    );
+  END IF;
+
+  IF v_machine.id IS NOT NULL THEN
+    INSERT INTO public.audit_logs (project_id, user_id, action, resource_type, resource_id, metadata)
+    VALUES (p_project_id, NULL, 'machine_token_pulled', 'machine_token', v_machine.id,
+            jsonb_build_object('name', v_machine.name, 'version', v_blob.version));
+  END IF;
+
+  RETURN json_build_object(
+    'has_update', TRUE,
+    'id', v_blob.id,
+    'version', v_blob.version,
+    'encrypted_data', v_blob.encrypted_data,
+    'checksum', v_blob.checksum,
+    'uploaded_at', v_blob.uploaded_at,
+    'uploaded_by', v_blob.uploaded_by
+  );
+END;
+$$;
+
+GRANT EXECUTE ON FUNCTION public.push_encrypted_blob TO authenticated;
+GRANT EXECUTE ON FUNCTION public.pull_encrypted_blob TO anon, authenticated;